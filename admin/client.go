@@ -4,41 +4,324 @@
 package admin
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
 
-	adminapi "github.com/ava-labs/avalanchego/api/admin"
+	avajson "github.com/ava-labs/avalanchego/utils/json"
 )
 
-var _ Client = &client{}
+const (
+	adminPath  = "/ext/admin"
+	infoPath   = "/ext/info"
+	healthPath = "/ext/health"
+
+	// retryDelay is how long to wait before the first retry. Each retry waits
+	// longer than the last.
+	retryDelay = 500 * time.Millisecond
+)
+
+var (
+	_ Client = &client{}
+
+	errNoCertificates = errors.New("no certificates found")
+)
 
 type Client interface {
-	LoadVMs() error
-	WhitelistSubnet(subnetID string) error
+	// LoadVMs asks the node to load virtual machines added to its plugin
+	// directory.
+	LoadVMs() (LoadedVMs, error)
+	// GetVMs returns the ids of the virtual machines registered with the node
+	// and their aliases.
+	GetVMs() (map[string][]string, error)
+	GetNodeVersion() (NodeVersion, error)
+	// GetChainAliases returns the aliases of the chain.
+	GetChainAliases(chainID string) ([]string, error)
+	// AliasChain gives the chain an alias.
+	AliasChain(chainID string, alias string) error
+	// Healthy returns whether the node reports itself as healthy.
+	Healthy() (bool, error)
+}
+
+// LoadedVMs is the result of loading virtual machines, keyed by vm id.
+type LoadedVMs struct {
+	// NewVMs are the virtual machines that were loaded and their aliases.
+	NewVMs map[string][]string `json:"newVMs"`
+	// FailedVMs are the virtual machines that failed to load and why.
+	FailedVMs map[string]string `json:"failedVMs"`
+}
+
+// NodeVersion is the version information reported by a node's info api.
+type NodeVersion struct {
+	// Version is the application version (e.g avalanche/1.7.14).
+	Version string `json:"version"`
+	// RPCProtocolVersion is the rpcchainvm protocol version supported by the
+	// node. Older nodes don't report this, in which case it's zero. It's
+	// encoded as a quoted string.
+	RPCProtocolVersion avajson.Uint32 `json:"rpcProtocolVersion"`
+}
+
+type Config struct {
+	// Endpoint is the admin api endpoint (e.g 127.0.0.1:9650/ext/admin). If
+	// it doesn't have a scheme, http is used.
+	Endpoint string
+	// CAFile is a PEM encoded certificate bundle to trust in addition to the
+	// system's for https endpoints.
+	CAFile string
+	// BearerToken is sent in the Authorization header. It takes precedence
+	// over Username and Password.
+	BearerToken string
+	// Username and Password are sent using basic auth.
+	Username string
+	Password string
+	// Timeout is how long each request can take. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is how many times requests that time out or fail with a server
+	// error are retried.
+	Retries int
+}
+
+// StatusError is returned when the node responds with an unsuccessful status
+// code.
+type StatusError struct {
+	Code int
+}
+
+func (s StatusError) Error() string {
+	return fmt.Sprintf("received status code %d", s.Code)
+}
+
+// RPCError is an error returned by the api.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (r RPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", r.Message, r.Code)
 }
 
 type client struct {
-	client adminapi.Client
+	http          *http.Client
+	uri           string
+	authorization string
+	timeout       time.Duration
+	retries       int
 }
 
-func NewClient(url string) Client {
+func NewClient(config Config) (Client, error) {
+	endpoint := config.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = fmt.Sprintf("http://%s", endpoint)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CAFile != "" {
+		pool, err := certPool(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	authorization := ""
+	switch {
+	case config.BearerToken != "":
+		authorization = fmt.Sprintf("Bearer %s", config.BearerToken)
+	case config.Username != "" || config.Password != "":
+		credentials := fmt.Sprintf("%s:%s", config.Username, config.Password)
+		authorization = fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
 	return &client{
-		client: adminapi.NewClient(url),
+		http: &http.Client{Transport: transport},
+		// The apis are all served relative to the node's base uri.
+		uri:           strings.TrimSuffix(strings.TrimSuffix(endpoint, "/"), adminPath),
+		authorization: authorization,
+		timeout:       config.Timeout,
+		retries:       config.Retries,
+	}, nil
+}
+
+// certPool returns the system's certificates along with the ones in path.
+func certPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("failed to load %s: %w", path, errNoCertificates)
+	}
+
+	return pool, nil
+}
+
+func (c *client) LoadVMs() (LoadedVMs, error) {
+	reply := LoadedVMs{}
+	err := c.call(adminPath, "admin.loadVMs", struct{}{}, &reply)
+
+	return reply, err
+}
+
+func (c *client) GetVMs() (map[string][]string, error) {
+	reply := struct {
+		VMs map[string][]string `json:"vms"`
+	}{}
+	err := c.call(infoPath, "info.getVMs", struct{}{}, &reply)
+
+	return reply.VMs, err
 }
 
-func (c *client) LoadVMs() error {
-	_, _, err := c.client.LoadVMs(context.Background())
+func (c *client) GetNodeVersion() (NodeVersion, error) {
+	// We use our own reply type since newer nodes report fields that aren't in
+	// the info client we depend on.
+	reply := NodeVersion{}
+	err := c.call(infoPath, "info.getNodeVersion", struct{}{}, &reply)
 
-	return err
+	return reply, err
 }
 
-func (c *client) WhitelistSubnet(subnetID string) error {
-	// id, err := ids.FromString(subnetID)
-	// if err != nil {
-	// 	return err
-	// }
-	//
-	// _, err = c.client.WhitelistSubnet(context.Background(), id)
-	// return err
-	return nil
+func (c *client) GetChainAliases(chainID string) ([]string, error) {
+	args := struct {
+		Chain string `json:"chain"`
+	}{
+		Chain: chainID,
+	}
+	reply := struct {
+		Aliases []string `json:"aliases"`
+	}{}
+	err := c.call(adminPath, "admin.getChainAliases", args, &reply)
+
+	return reply.Aliases, err
+}
+
+func (c *client) AliasChain(chainID string, alias string) error {
+	args := struct {
+		Chain string `json:"chain"`
+		Alias string `json:"alias"`
+	}{
+		Chain: chainID,
+		Alias: alias,
+	}
+
+	return c.call(adminPath, "admin.aliasChain", args, &struct{}{})
+}
+
+func (c *client) Healthy() (bool, error) {
+	reply := struct {
+		Healthy bool `json:"healthy"`
+	}{}
+	err := c.call(healthPath, "health.health", struct{}{}, &reply)
+
+	return reply.Healthy, err
+}
+
+// call makes a json rpc request to the api at path, retrying if the node
+// timed out or failed with a server error.
+func (c *client) call(path string, method string, args interface{}, reply interface{}) error {
+	body, err := json.Marshal(struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params"`
+		ID      int         `json:"id"`
+	}{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  args,
+		ID:      1,
+	})
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = c.send(path, body, reply)
+		if attempt >= c.retries || !retryable(err) {
+			return err
+		}
+
+		time.Sleep(time.Duration(attempt+1) * retryDelay)
+	}
+}
+
+func (c *client) send(path string, body []byte, reply interface{}) error {
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.uri+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if c.authorization != "" {
+		request.Header.Set("Authorization", c.authorization)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return StatusError{Code: response.StatusCode}
+	}
+
+	result := struct {
+		Result json.RawMessage `json:"result"`
+		Error  *RPCError       `json:"error"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Error != nil {
+		return *result.Error
+	}
+
+	return json.Unmarshal(result.Result, reply)
+}
+
+// retryable returns whether a request that failed with err might succeed if
+// it's made again. An offline node isn't retried, so callers can tell it apart
+// quickly.
+func retryable(err error) bool {
+	if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+		return false
+	}
+
+	statusErr := StatusError{}
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= http.StatusInternalServerError
+	}
+
+	netErr := net.Error(nil)
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNRESET)
 }
@@ -0,0 +1,281 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package admin
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// handler replies to every request with result after failing the first
+// failures requests with status.
+func handler(t *testing.T, calls *int32, failures int32, status int, result interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"result":  result,
+		}))
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int32
+		status    int
+		retries   int
+		wantCalls int32
+		wantErr   assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "success",
+			retries:   2,
+			wantCalls: 1,
+			wantErr:   assert.NoError,
+		},
+		{
+			name:      "server error retried",
+			failures:  1,
+			status:    http.StatusBadGateway,
+			retries:   2,
+			wantCalls: 2,
+			wantErr:   assert.NoError,
+		},
+		{
+			name:      "out of retries",
+			failures:  3,
+			status:    http.StatusServiceUnavailable,
+			retries:   1,
+			wantCalls: 2,
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, StatusError{Code: http.StatusServiceUnavailable}, err)
+			},
+		},
+		{
+			name:      "client error not retried",
+			failures:  1,
+			status:    http.StatusUnauthorized,
+			retries:   2,
+			wantCalls: 1,
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, StatusError{Code: http.StatusUnauthorized}, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := int32(0)
+			server := httptest.NewServer(handler(t, &calls, test.failures, test.status, map[string]interface{}{
+				"newVMs": map[string][]string{"vmID": {"vm"}},
+			}))
+			defer server.Close()
+
+			c, err := NewClient(Config{
+				Endpoint: server.URL + adminPath,
+				Retries:  test.retries,
+			})
+			require.NoError(t, err)
+
+			loaded, err := c.LoadVMs()
+			test.wantErr(t, err)
+			assert.Equal(t, test.wantCalls, atomic.LoadInt32(&calls))
+			if err == nil {
+				assert.Equal(t, map[string][]string{"vmID": {"vm"}}, loaded.NewVMs)
+			}
+		})
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:   "bearer",
+			config: Config{BearerToken: "token"},
+			want:   "Bearer token",
+		},
+		{
+			name:   "basic",
+			config: Config{Username: "user", Password: "pass"},
+			want:   "Basic dXNlcjpwYXNz",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ""
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Authorization")
+				assert.Equal(t, infoPath, r.URL.Path)
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"version":"avalanche/1.7.14"}}`))
+			}))
+			defer server.Close()
+
+			test.config.Endpoint = server.URL + adminPath
+			c, err := NewClient(test.config)
+			require.NoError(t, err)
+
+			version, err := c.GetNodeVersion()
+			require.NoError(t, err)
+			assert.Equal(t, "avalanche/1.7.14", version.Version)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"vms":{"vmID":["vm"]}}}`))
+	}))
+	defer server.Close()
+
+	// Without the server's certificate, it isn't trusted.
+	c, err := NewClient(Config{Endpoint: server.URL + adminPath})
+	require.NoError(t, err)
+	_, err = c.GetVMs()
+	assert.Error(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0o600))
+
+	c, err = NewClient(Config{Endpoint: server.URL + adminPath, CAFile: caFile})
+	require.NoError(t, err)
+	vms, err := c.GetVMs()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"vmID": {"vm"}}, vms)
+
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+	_, err = NewClient(Config{Endpoint: server.URL, CAFile: caFile})
+	assert.ErrorIs(t, err, errNoCertificates)
+}
+
+func TestClientTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	c, err := NewClient(Config{Endpoint: server.URL, Timeout: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	_, err = c.GetVMs()
+	assert.True(t, retryable(err))
+}
+
+func TestClientOffline(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	// Endpoints without a scheme use http.
+	c, err := NewClient(Config{Endpoint: endpoint, Retries: 5})
+	require.NoError(t, err)
+
+	_, err = c.LoadVMs()
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+}
+
+func TestClientGetNodeVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, infoPath, r.URL.Path)
+		// avalanchego quotes numbers in its responses
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"version":"avalanche/1.7.14","rpcProtocolVersion":"15"}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(Config{Endpoint: server.URL + adminPath})
+	require.NoError(t, err)
+
+	version, err := c.GetNodeVersion()
+	require.NoError(t, err)
+	assert.Equal(t, NodeVersion{Version: "avalanche/1.7.14", RPCProtocolVersion: 15}, version)
+}
+
+func TestClientRPCError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"plugin directory not found"}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(Config{Endpoint: server.URL})
+	require.NoError(t, err)
+
+	_, err = c.LoadVMs()
+	assert.Equal(t, RPCError{Code: -32000, Message: "plugin directory not found"}, err)
+}
+
+func TestClientHealthy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, healthPath, r.URL.Path)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"checks":{},"healthy":true}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(Config{Endpoint: server.URL + adminPath})
+	require.NoError(t, err)
+
+	healthy, err := c.Healthy()
+	require.NoError(t, err)
+	assert.True(t, healthy)
+}
+
+func TestClientChainAliases(t *testing.T) {
+	type request struct {
+		Method string            `json:"method"`
+		Params map[string]string `json:"params"`
+	}
+	requests := make([]request, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, adminPath, r.URL.Path)
+		req := request{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"aliases":["alias"]}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(Config{Endpoint: server.URL + adminPath})
+	require.NoError(t, err)
+
+	aliases, err := c.GetChainAliases("chainID")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alias"}, aliases)
+
+	require.NoError(t, c.AliasChain("chainID", "alias"))
+
+	assert.Equal(t, []request{
+		{Method: "admin.getChainAliases", Params: map[string]string{"chain": "chainID"}},
+		{Method: "admin.aliasChain", Params: map[string]string{"chain": "chainID", "alias": "alias"}},
+	}, requests)
+}
@@ -36,30 +36,91 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
-// LoadVMs mocks base method.
-func (m *MockClient) LoadVMs() error {
+// AliasChain mocks base method.
+func (m *MockClient) AliasChain(chainID, alias string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LoadVMs")
+	ret := m.ctrl.Call(m, "AliasChain", chainID, alias)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// LoadVMs indicates an expected call of LoadVMs.
-func (mr *MockClientMockRecorder) LoadVMs() *gomock.Call {
+// AliasChain indicates an expected call of AliasChain.
+func (mr *MockClientMockRecorder) AliasChain(chainID, alias interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadVMs", reflect.TypeOf((*MockClient)(nil).LoadVMs))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AliasChain", reflect.TypeOf((*MockClient)(nil).AliasChain), chainID, alias)
 }
 
-// WhitelistSubnet mocks base method.
-func (m *MockClient) WhitelistSubnet(subnetID string) error {
+// GetChainAliases mocks base method.
+func (m *MockClient) GetChainAliases(chainID string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WhitelistSubnet", subnetID)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "GetChainAliases", chainID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetChainAliases indicates an expected call of GetChainAliases.
+func (mr *MockClientMockRecorder) GetChainAliases(chainID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetChainAliases", reflect.TypeOf((*MockClient)(nil).GetChainAliases), chainID)
+}
+
+// GetNodeVersion mocks base method.
+func (m *MockClient) GetNodeVersion() (NodeVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNodeVersion")
+	ret0, _ := ret[0].(NodeVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNodeVersion indicates an expected call of GetNodeVersion.
+func (mr *MockClientMockRecorder) GetNodeVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeVersion", reflect.TypeOf((*MockClient)(nil).GetNodeVersion))
+}
+
+// GetVMs mocks base method.
+func (m *MockClient) GetVMs() (map[string][]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVMs")
+	ret0, _ := ret[0].(map[string][]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVMs indicates an expected call of GetVMs.
+func (mr *MockClientMockRecorder) GetVMs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVMs", reflect.TypeOf((*MockClient)(nil).GetVMs))
+}
+
+// Healthy mocks base method.
+func (m *MockClient) Healthy() (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Healthy")
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Healthy indicates an expected call of Healthy.
+func (mr *MockClientMockRecorder) Healthy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Healthy", reflect.TypeOf((*MockClient)(nil).Healthy))
 }
 
-// WhitelistSubnet indicates an expected call of WhitelistSubnet.
-func (mr *MockClientMockRecorder) WhitelistSubnet(subnetID interface{}) *gomock.Call {
+// LoadVMs mocks base method.
+func (m *MockClient) LoadVMs() (LoadedVMs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadVMs")
+	ret0, _ := ret[0].(LoadedVMs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadVMs indicates an expected call of LoadVMs.
+func (mr *MockClientMockRecorder) LoadVMs() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WhitelistSubnet", reflect.TypeOf((*MockClient)(nil).WhitelistSubnet), subnetID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadVMs", reflect.TypeOf((*MockClient)(nil).LoadVMs))
 }
@@ -4,13 +4,16 @@
 package apm
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"syscall"
 	"text/tabwriter"
+	"time"
 
 	"github.com/ava-labs/avalanchego/utils/perms"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -19,10 +22,17 @@ import (
 	"github.com/spf13/afero"
 
 	"github.com/ava-labs/apm/admin"
+	"github.com/ava-labs/apm/audit"
 	"github.com/ava-labs/apm/constant"
+	"github.com/ava-labs/apm/dependency"
 	"github.com/ava-labs/apm/engine"
 	"github.com/ava-labs/apm/git"
+	"github.com/ava-labs/apm/hooks"
+	"github.com/ava-labs/apm/lockfile"
+	"github.com/ava-labs/apm/metrics"
+	"github.com/ava-labs/apm/node"
 	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
 	"github.com/ava-labs/apm/url"
 	"github.com/ava-labs/apm/util"
 	"github.com/ava-labs/apm/workflow"
@@ -31,16 +41,67 @@ import (
 const (
 	repositoryDir = "repositories"
 	tmpDir        = "tmp"
+	backupDir     = "backups"
 	lockFile      = "apm.lock"
 )
 
 type Config struct {
-	Directory        string
-	Auth             http.BasicAuth
-	AdminAPIEndpoint string
-	PluginDir        string
-	Fs               afero.Fs
-	StateFile        state.File
+	Directory string
+	Auth      http.BasicAuth
+	// AdminAPI configures how the node's apis are reached.
+	AdminAPI  admin.Config
+	PluginDir string
+	Fs        afero.Fs
+	StateFile state.File
+	// AvalancheGoVersion overrides the version reported by the node when
+	// checking compatibility.
+	AvalancheGoVersion string
+	// RPCChainVMProtocol overrides the protocol version reported by the node
+	// when checking compatibility.
+	RPCChainVMProtocol uint
+	// IgnoreCompatibility downgrades compatibility failures to warnings.
+	IgnoreCompatibility bool
+	// NodeConfigFile is the avalanchego config file the apm manages tracked
+	// subnets in. If empty, operators are told how to update their node
+	// instead.
+	NodeConfigFile string
+	// NodeConfigDir is the avalanchego configs directory subnet and chain
+	// configs are written to.
+	NodeConfigDir string
+	// Network is the network subnets are joined on. This can be mainnet, fuji,
+	// local or a custom network id.
+	Network string
+	// Profile is the name of the node profile to manage. Each profile has its
+	// own installation registry and joined subnets.
+	Profile string
+	// LockFile, if set, restricts installs to the artifacts recorded in it.
+	LockFile *lockfile.File
+	// Wait blocks until the lock is available if another apm process holds
+	// it, instead of failing.
+	Wait bool
+	// LockTimeout is the longest to wait for the lock. Zero waits forever. A
+	// non-zero timeout implies Wait.
+	LockTimeout time.Duration
+	// Reload asks the node to load virtual machines after they're installed
+	// or upgraded, and uninstalls new ones that fail to load.
+	Reload bool
+	// Restarter, if set, restarts the node after upgrade replaces binaries.
+	// The binaries are rolled back if the node doesn't become healthy within
+	// HealthTimeout.
+	Restarter     node.Restarter
+	HealthTimeout time.Duration
+	// DryRun plans workflows instead of executing them. The planned actions
+	// can be retrieved with WritePlan.
+	DryRun bool
+	// Metrics, if set, records installs, upgrades, downloads and repository
+	// syncs. Dry runs aren't recorded.
+	Metrics *metrics.Metrics
+	// Hooks, if set, are notified of events during workflows. Dry runs don't
+	// emit events.
+	Hooks *hooks.Hooks
+	// Command is the invocation recorded in the audit log. Secrets should
+	// already be redacted.
+	Command []string
 }
 
 type APM struct {
@@ -48,19 +109,37 @@ type APM struct {
 	git         git.Factory
 
 	executor workflow.Executor
+	dryRun   *engine.DryRunEngine
+	hooked   *hookedExecutor
 
 	auth http.BasicAuth
 
-	adminClient admin.Client
-	installer   workflow.Installer
+	adminClient   admin.Client
+	nodeConfig    node.Config
+	configDir     node.ConfigDir
+	installer     workflow.Installer
+	compatibility workflow.CompatibilityChecker
+	resolver      *dependency.Resolver
 
 	repositoriesPath string
 	tmpPath          string
 	pluginPath       string
 	adminAPIEndpoint string
+	network          string
 	fs               afero.Fs
 	stateFile        state.File
+	lockFile         *lockfile.File
 	lock             *fslock.Lock
+	lockPath         string
+	wait             bool
+	lockTimeout      time.Duration
+	reload           bool
+	restarter        node.Restarter
+	healthTimeout    time.Duration
+	metrics          *metrics.Metrics
+	auditLog         *audit.Log
+	// held is whether this process holds the lock.
+	held bool
 }
 
 func New(config Config) (*APM, error) {
@@ -71,37 +150,125 @@ func New(config Config) (*APM, error) {
 	if err != nil {
 		return nil, err
 	}
+	stateFile = stateFile.ForProfile(config.Profile)
+
+	network, err := util.ParseNetwork(config.Network)
+	if err != nil {
+		return nil, err
+	}
 
 	repositoriesPath := filepath.Join(config.Directory, repositoryDir)
+	auditLog := audit.NewLog(audit.LogConfig{
+		Fs:      config.Fs,
+		Path:    filepath.Join(config.Directory, audit.File),
+		User:    audit.CurrentUser(),
+		Command: config.Command,
+	})
+	adminClient, err := admin.NewClient(config.AdminAPI)
+	if err != nil {
+		return nil, err
+	}
+	compatibility := workflow.NewNodeCompatibility(
+		workflow.NodeCompatibilityConfig{
+			AdminClient:        adminClient,
+			AvalancheGoVersion: config.AvalancheGoVersion,
+			RPCChainVMProtocol: config.RPCChainVMProtocol,
+			Force:              config.IgnoreCompatibility,
+		},
+	)
 	a := &APM{
 		repoFactory: state.NewRepositoryFactory(repositoriesPath),
 		git:         git.RepositoryFactory{},
-		executor:    engine.NewWorkflowEngine(stateFile),
+		executor: engine.NewWorkflowEngine(engine.WorkflowEngineConfig{
+			StateFile: stateFile,
+			AuditLog:  auditLog,
+		}),
 		auth:        config.Auth,
-		adminClient: admin.NewClient(fmt.Sprintf("http://%s", config.AdminAPIEndpoint)),
+		adminClient: adminClient,
 		installer: workflow.NewVMInstaller(
 			workflow.VMInstallerConfig{
 				Fs:        config.Fs,
 				URLClient: url.NewClient(),
 			},
 		),
+		compatibility:    compatibility,
 		repositoriesPath: repositoriesPath,
 		tmpPath:          filepath.Join(config.Directory, tmpDir),
 		pluginPath:       config.PluginDir,
-		adminAPIEndpoint: config.AdminAPIEndpoint,
+		adminAPIEndpoint: config.AdminAPI.Endpoint,
+		network:          network,
 		fs:               config.Fs,
 		stateFile:        stateFile,
+		configDir:        node.NewFileConfigDir(config.Fs, config.NodeConfigDir),
+		lockFile:         config.LockFile,
 		lock:             fslock.New(filepath.Join(config.Directory, lockFile)),
+		lockPath:         filepath.Join(config.Directory, lockFile),
+		wait:             config.Wait || config.LockTimeout > 0,
+		lockTimeout:      config.LockTimeout,
+		reload:           config.Reload,
+		restarter:        config.Restarter,
+		healthTimeout:    config.HealthTimeout,
+		metrics:          config.Metrics,
+		auditLog:         auditLog,
+	}
+	if a.metrics != nil {
+		a.executor = &meteredExecutor{Executor: a.executor, metrics: a.metrics}
+		a.installer = &meteredInstaller{Installer: a.installer, metrics: a.metrics, fs: config.Fs}
+		a.git = &meteredGit{Factory: a.git, metrics: a.metrics, repositoriesPath: repositoriesPath}
+		a.recordUpdated()
+	}
+	if config.Hooks != nil {
+		a.hooked = &hookedExecutor{Executor: a.executor, hooks: config.Hooks, stateFile: stateFile}
+		a.executor = a.hooked
 	}
+	if config.NodeConfigFile != "" {
+		a.nodeConfig = node.NewFileConfig(config.Fs, config.NodeConfigFile, compatibility.NodeVersion)
+	} else {
+		a.nodeConfig = node.NewManualConfig(compatibility.NodeVersion)
+	}
+	a.resolver = dependency.NewResolver(dependency.ResolverConfig{
+		RepoFactory:   a.repoFactory,
+		StateFile:     a.stateFile,
+		Compatibility: a.compatibility,
+	})
 	if err := os.MkdirAll(a.repositoriesPath, perms.ReadWriteExecute); err != nil {
 		return nil, err
 	}
 
+	if config.DryRun {
+		a.dryRun = engine.NewDryRunEngine()
+		a.executor = a.dryRun
+	}
+
+	if err := a.bootstrap(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// bootstrap tracks and syncs the core repository if that hasn't happened yet.
+// Both steps happen under the same lock so other processes never see a
+// half-bootstrapped apm. Dry runs never bootstrap.
+func (a *APM) bootstrap() error {
+	source, ok := a.stateFile.Sources[constant.CoreAlias]
+	if ok && source.Commit != plumbing.ZeroHash.String() {
+		return nil
+	}
+
+	if a.dryRun != nil {
+		fmt.Println("Bootstrap not detected. Skipping bootstrap during a dry run.")
+		return nil
+	}
+
+	if err := a.acquireLock(); err != nil {
+		return err
+	}
+	defer a.releaseLock()
+
 	// Sync the core repository if it hasn't been bootstrapped yet.
 	if _, ok := a.stateFile.Sources[constant.CoreAlias]; !ok {
-		err := a.AddRepository(constant.CoreAlias, constant.CoreURL, constant.CoreBranch)
-		if err != nil {
-			return nil, err
+		if err := a.addRepository(constant.CoreAlias, constant.CoreURL, constant.CoreBranch); err != nil {
+			return err
 		}
 	}
 
@@ -110,14 +277,43 @@ func New(config Config) (*APM, error) {
 
 	if repoMetadata.Commit == plumbing.ZeroHash.String() {
 		fmt.Println("Bootstrap not detected. Bootstrapping...")
-		err := a.Update()
-		if err != nil {
-			return nil, err
+		if err := a.update(); err != nil {
+			return err
 		}
 
 		fmt.Println("Finished bootstrapping.")
 	}
-	return a, nil
+
+	return nil
+}
+
+// WritePlan writes the actions planned during a dry run to w in the provided
+// format ("text" or "json").
+func (a *APM) WritePlan(w io.Writer, format string) error {
+	if a.dryRun == nil {
+		return fmt.Errorf("the apm is not in dry-run mode")
+	}
+
+	actions := a.dryRun.Actions()
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(actions)
+	case "text":
+		if len(actions) == 0 {
+			_, err := fmt.Fprintln(w, "Nothing to do.")
+			return err
+		}
+		for i, action := range actions {
+			if _, err := fmt.Fprintf(w, "%d. %s\n", i+1, action); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown plan format %s", format)
+	}
 }
 
 func (a *APM) parseAndRun(
@@ -140,42 +336,463 @@ func (a *APM) Install(alias string) error {
 	return a.parseAndRun(alias, a.install)
 }
 
-func (a *APM) install(name string) error {
-	if err := a.lock.TryLock(); err != nil {
+func (a *APM) install(name string) (err error) {
+	if err := a.acquireLock(); err != nil {
+		return err
+	}
+	defer a.releaseLock()
+
+	if a.lockFile != nil {
+		var previous map[string]string
+		previous, err = a.checkoutLocked()
+		// Repositories go back to their tracked commits even if the install
+		// fails.
+		defer func() {
+			if checkoutErr := a.checkout(previous); err == nil {
+				err = checkoutErr
+			}
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	before := a.installedCommits()
+	if err := a.installLocked(name); err != nil {
 		return err
 	}
-	defer func() {
-		_ = a.lock.Unlock()
-	}()
 
-	_, ok := a.stateFile.InstallationRegistry[name]
-	if ok {
-		fmt.Printf("VM %s is already installed. Skipping.\n", name)
+	return a.reloadChanges(before, nil)
+}
+
+// installLocked installs the virtual machine and its dependencies. Callers must
+// hold the lock.
+func (a *APM) installLocked(name string) error {
+	plan, err := a.resolver.ResolveVM(name)
+	if err != nil {
+		return err
+	}
+
+	return a.installPlan(plan, name)
+}
+
+// installPlan installs every virtual machine in the plan that isn't installed
+// yet. explicit is the name of the virtual machine the user asked for, if any.
+// Callers must hold the lock.
+func (a *APM) installPlan(plan dependency.Plan, explicit string) error {
+	installs, err := a.installWorkflows(plan, explicit)
+	if err != nil {
+		return err
+	}
+
+	for _, wf := range installs {
+		if err := a.executor.Execute(wf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// installWorkflows returns an install workflow for each virtual machine in the
+// plan that isn't installed yet. Everything other than explicit is recorded as
+// a dependency.
+func (a *APM) installWorkflows(plan dependency.Plan, explicit string) ([]workflow.Workflow, error) {
+	if pending := plan.Pending(); len(pending) > 1 {
+		names := make([]string, 0, len(pending))
+		for _, step := range pending {
+			names = append(names, step.Name)
+		}
+		fmt.Printf("Resolved virtual machines to install: %s.\n", strings.Join(names, ", "))
+	}
+
+	if err := a.checkLockFile(plan); err != nil {
+		return nil, err
+	}
+
+	result := make([]workflow.Workflow, 0, len(plan.VMs))
+	for _, step := range plan.VMs {
+		if step.Installed {
+			fmt.Printf("VM %s is already installed. Skipping.\n", step.Name)
+			if step.Name == explicit {
+				if err := a.markExplicit(step.Name); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		reason := state.DependencyInstall
+		if step.Name == explicit {
+			reason = state.ExplicitInstall
+		}
+
+		install, err := a.newInstall(step.Name, reason)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, install)
+	}
+
+	return result, nil
+}
+
+// markExplicit records that the user asked for an installed virtual machine, so
+// it isn't autoremoved when nothing requires it anymore. Callers must hold the
+// lock.
+func (a *APM) markExplicit(name string) error {
+	if a.stateFile.InstallationRegistry[name].Reason == state.ExplicitInstall {
+		return nil
+	}
+
+	return a.executor.Execute(workflow.NewMarkExplicit(workflow.MarkExplicitConfig{
+		Name:      name,
+		StateFile: a.stateFile,
+	}))
+}
+
+// installedCommits returns the commit each installed virtual machine is at.
+func (a *APM) installedCommits() map[string]string {
+	result := make(map[string]string, len(a.stateFile.InstallationRegistry))
+	for name, installInfo := range a.stateFile.InstallationRegistry {
+		result[name] = installInfo.Commit
+	}
+
+	return result
+}
+
+// backupBinaries keeps the binaries of the installed virtual machines in case
+// the node fails to load or run the ones they're about to be upgraded to.
+// Nothing is kept if the apm can't tell, or during dry runs. Callers must hold
+// the lock and call removeBackups when they're done.
+func (a *APM) backupBinaries() (map[string]workflow.Backup, error) {
+	if (!a.reload && a.restarter == nil) || a.dryRun != nil {
+		return nil, nil
+	}
+
+	return workflow.BackupBinaries(a.fs, a.stateFile, a.pluginPath, filepath.Join(a.tmpPath, backupDir))
+}
+
+func (a *APM) removeBackups() {
+	_ = a.fs.RemoveAll(filepath.Join(a.tmpPath, backupDir))
+}
+
+// reloadChanges asks the node to load the virtual machines installed or
+// upgraded since before was taken, if the apm is configured to. Upgrades that
+// fail to load are restored from backups. Callers must hold the lock.
+func (a *APM) reloadChanges(before map[string]string, backups map[string]workflow.Backup) error {
+	if !a.reload {
+		return nil
+	}
+
+	installed, upgraded := a.changes(before)
+	return a.executor.Execute(workflow.NewReload(workflow.ReloadConfig{
+		Executor:         a.executor,
+		Installed:        installed,
+		Upgraded:         upgraded,
+		Backups:          backups,
+		StateFile:        a.stateFile,
+		AdminClient:      a.adminClient,
+		AdminAPIEndpoint: a.adminAPIEndpoint,
+		Fs:               a.fs,
+		PluginPath:       a.pluginPath,
+	}))
+}
+
+// restartChanges restarts the node if any virtual machine was upgraded since
+// before was taken and the apm has a way to restart the node. Callers must
+// hold the lock.
+func (a *APM) restartChanges(before map[string]string, backups map[string]workflow.Backup) error {
+	if a.restarter == nil {
 		return nil
 	}
 
+	_, upgraded := a.changes(before)
+	return a.executor.Execute(workflow.NewRestart(workflow.RestartConfig{
+		Restarter:     a.restarter,
+		AdminClient:   a.adminClient,
+		HealthTimeout: a.healthTimeout,
+		Upgraded:      upgraded,
+		Backups:       backups,
+		StateFile:     a.stateFile,
+		Fs:            a.fs,
+		PluginPath:    a.pluginPath,
+	}))
+}
+
+// settleUpgrades notifies hooks of the upgrades done since the last call, now
+// that the node has had the chance to roll them back. Callers must hold the
+// lock.
+func (a *APM) settleUpgrades(err error) {
+	if a.hooked != nil {
+		a.hooked.settle(err)
+	}
+}
+
+// changes returns the virtual machines that were installed and the ones that
+// were upgraded since before was taken.
+func (a *APM) changes(before map[string]string) (installed []string, upgraded []string) {
+	installed = make([]string, 0)
+	upgraded = make([]string, 0)
+	for _, name := range util.SortedKeys(a.stateFile.InstallationRegistry) {
+		commit, ok := before[name]
+		switch {
+		case !ok:
+			installed = append(installed, name)
+		case commit != a.stateFile.InstallationRegistry[name].Commit:
+			upgraded = append(upgraded, name)
+		}
+	}
+
+	return installed, upgraded
+}
+
+// newInstall returns a workflow that installs the virtual machine with the
+// provided fully qualified name.
+func (a *APM) newInstall(name string, reason state.InstallReason) (workflow.Workflow, error) {
 	repoAlias, plugin := util.ParseQualifiedName(name)
 	organization, repo := util.ParseAlias(repoAlias)
 
 	repository, err := a.repoFactory.GetRepository(repoAlias)
 	if err != nil {
+		return nil, err
+	}
+
+	return workflow.NewInstall(workflow.InstallConfig{
+		Name:          name,
+		Plugin:        plugin,
+		Organization:  organization,
+		Repo:          repo,
+		TmpPath:       a.tmpPath,
+		PluginPath:    a.pluginPath,
+		Reason:        reason,
+		StateFile:     a.stateFile,
+		Repository:    repository,
+		Fs:            a.fs,
+		Installer:     a.installer,
+		Compatibility: a.compatibility,
+	}), nil
+}
+
+// checkLockFile returns an error if the plan would install anything other than
+// what's in the lock file, which happens when the locked definitions can't be
+// reproduced from the locked repository commits. Does nothing if there's no
+// lock file.
+func (a *APM) checkLockFile(plan dependency.Plan) error {
+	if a.lockFile == nil {
+		return nil
+	}
+
+	for _, step := range plan.VMs {
+		if step.Installed {
+			if err := a.lockFile.CheckInstalled(step.Name, *a.stateFile.InstallationRegistry[step.Name]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := a.lockFile.CheckVM(step.Name, step.Definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkoutLocked checks out the locked commit of every tracked repository
+// that's at another one, so virtual machines are resolved and installed from
+// their locked definitions. Returns the commits the checked out repositories
+// were at, even if it fails. Callers must hold the lock.
+func (a *APM) checkoutLocked() (map[string]string, error) {
+	previous := make(map[string]string)
+	for _, alias := range util.SortedKeys(a.lockFile.Repositories) {
+		locked := a.lockFile.Repositories[alias]
+		source, ok := a.stateFile.Sources[alias]
+		if !ok || source.Commit == locked.Commit {
+			continue
+		}
+
+		if err := a.git.Checkout(filepath.Join(a.repositoriesPath, alias), locked.Commit, &a.auth); err != nil {
+			return previous, fmt.Errorf("failed to check out %s at its locked commit %s: %w", alias, locked.Commit, err)
+		}
+		previous[alias] = source.Commit
+	}
+
+	return previous, nil
+}
+
+// checkout checks out the commit of each repository. Callers must hold the
+// lock.
+func (a *APM) checkout(commits map[string]string) error {
+	for _, alias := range util.SortedKeys(commits) {
+		if err := a.git.Checkout(filepath.Join(a.repositoriesPath, alias), commits[alias], &a.auth); err != nil {
+			return fmt.Errorf("failed to check out %s at %s: %w", alias, commits[alias], err)
+		}
+	}
+
+	return nil
+}
+
+// LockFile returns a lock file recording the tracked repositories and the
+// installed virtual machines.
+func (a *APM) LockFile() (lockfile.File, error) {
+	if err := a.acquireLock(); err != nil {
+		return lockfile.File{}, err
+	}
+	defer a.releaseLock()
+
+	result := lockfile.New()
+	for alias, source := range a.stateFile.Sources {
+		result.Repositories[alias] = lockfile.Repository{
+			URL:    source.URL,
+			Branch: source.Branch.Short(),
+			Commit: source.Commit,
+		}
+	}
+
+	for name, installInfo := range a.stateFile.InstallationRegistry {
+		alias, plugin := util.ParseQualifiedName(name)
+		repository, err := a.repoFactory.GetRepository(alias)
+		if err != nil {
+			return lockfile.File{}, err
+		}
+
+		definition, err := repository.GetVM(plugin)
+		if err != nil {
+			return lockfile.File{}, err
+		}
+
+		// We only know the artifact of the definition we have, so it has to be
+		// the one that's installed.
+		if definition.Commit != installInfo.Commit {
+			return lockfile.File{}, fmt.Errorf("%s is installed at %s but its definition is at %s. Run upgrade before locking", name, installInfo.Commit, definition.Commit)
+		}
+
+		result.VMs[name] = lockfile.VM{
+			Commit: definition.Commit,
+			URL:    definition.Definition.URL,
+			SHA256: definition.Definition.SHA256,
+		}
+	}
+
+	return result, nil
+}
+
+// Verify checks installed binaries against the checksums recorded when they
+// were installed. If alias is empty, every installed virtual machine is
+// checked and untracked files in the plugin directory are reported. If repair
+// is set, virtual machines that fail verification are reinstalled.
+func (a *APM) Verify(alias string, repair bool) error {
+	if alias == "" {
+		return a.verify(nil, repair)
+	}
+
+	return a.parseAndRun(alias, func(name string) error {
+		return a.verify([]string{name}, repair)
+	})
+}
+
+func (a *APM) verify(names []string, repair bool) error {
+	if err := a.acquireLock(); err != nil {
 		return err
 	}
+	defer a.releaseLock()
 
-	workflow := workflow.NewInstall(workflow.InstallConfig{
-		Name:         name,
-		Plugin:       plugin,
-		Organization: organization,
-		Repo:         repo,
-		TmpPath:      a.tmpPath,
-		PluginPath:   a.pluginPath,
-		StateFile:    a.stateFile,
-		Repository:   repository,
-		Fs:           a.fs,
-		Installer:    a.installer,
+	wf := workflow.NewVerify(workflow.VerifyConfig{
+		Names:      names,
+		StateFile:  a.stateFile,
+		Fs:         a.fs,
+		PluginPath: a.pluginPath,
 	})
 
-	return a.executor.Execute(workflow)
+	err := a.executor.Execute(wf)
+	if !repair || (err != nil && !errors.Is(err, workflow.ErrVerificationFailed)) {
+		return err
+	}
+
+	// Check every virtual machine can be repaired before reinstalling any.
+	repairs := make([]string, 0, len(wf.Problems()))
+	for _, problem := range wf.Problems() {
+		if problem.Kind == workflow.UntrackedProblem {
+			continue
+		}
+
+		if err := a.checkRepairable(problem.Name); err != nil {
+			return err
+		}
+		repairs = append(repairs, problem.Name)
+	}
+
+	for _, name := range repairs {
+		fmt.Printf("Reinstalling %s...\n", name)
+		install, err := a.newInstall(name, a.stateFile.InstallationRegistry[name].Reason)
+		if err != nil {
+			return err
+		}
+		if err := a.executor.Execute(install); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkRepairable returns an error if the virtual machine can't be reinstalled
+// at the commit it was installed at. Definitions can only be installed at their
+// repository's current commit, so this is the case if the definition has
+// changed since.
+func (a *APM) checkRepairable(name string) error {
+	installInfo := a.stateFile.InstallationRegistry[name]
+
+	repoAlias, plugin := util.ParseQualifiedName(name)
+	repository, err := a.repoFactory.GetRepository(repoAlias)
+	if err != nil {
+		return err
+	}
+
+	definition, err := repository.GetVM(plugin)
+	if err != nil {
+		return err
+	}
+
+	if definition.Commit != installInfo.Commit {
+		return fmt.Errorf("can't repair %s: it was installed at %s but its definition is now at %s. Run upgrade to install the new version", name, installInfo.Commit, definition.Commit)
+	}
+
+	return nil
+}
+
+// Doctor checks for inconsistencies between the state file and the disk, and
+// for problems with the environment the apm runs in. If fix is set, the
+// problems that can be fixed automatically are reconciled.
+func (a *APM) Doctor(fix bool) error {
+	// Taking the lock replaces its holder, so look for a stale one first.
+	staleLockHolder := ""
+	if h, ok := a.readHolder(); ok && !h.alive() {
+		staleLockHolder = h.String()
+	}
+
+	if err := a.acquireLock(); errors.Is(err, fslock.ErrLocked) && staleLockHolder != "" {
+		fmt.Printf("Problem - %s is held by a process started by %s, which is no longer running. Stop the processes it started to release the lock.\n", lockFile, staleLockHolder)
+		return fmt.Errorf("1 %w", workflow.ErrUnhealthy)
+	} else if err != nil {
+		return err
+	}
+	defer a.releaseLock()
+
+	return a.executor.Execute(workflow.NewDoctor(workflow.DoctorConfig{
+		Executor:         a.executor,
+		StateFile:        a.stateFile,
+		Fs:               a.fs,
+		TmpPath:          a.tmpPath,
+		RepositoriesPath: a.repositoriesPath,
+		PluginPath:       a.pluginPath,
+		Git:              a.git,
+		Auth:             a.auth,
+		StaleLockHolder:  staleLockHolder,
+		Hooks:            a.installer,
+		Fix:              fix,
+	}))
 }
 
 func (a *APM) Uninstall(alias string) error {
@@ -183,13 +800,16 @@ func (a *APM) Uninstall(alias string) error {
 }
 
 func (a *APM) uninstall(name string) error {
-	if err := a.lock.TryLock(); err != nil {
+	if err := a.acquireLock(); err != nil {
 		return err
 	}
-	defer func() {
-		_ = a.lock.Unlock()
-	}()
+	defer a.releaseLock()
+
+	return a.uninstallLocked(name)
+}
 
+// uninstallLocked uninstalls the virtual machine. Callers must hold the lock.
+func (a *APM) uninstallLocked(name string) error {
 	alias, plugin := util.ParseQualifiedName(name)
 	wf := workflow.NewUninstall(
 		workflow.UninstallConfig{
@@ -199,55 +819,163 @@ func (a *APM) uninstall(name string) error {
 			StateFile:  a.stateFile,
 			Fs:         a.fs,
 			PluginPath: a.pluginPath,
+			Hooks:      a.installer,
 		},
 	)
 
 	return a.executor.Execute(wf)
 }
 
+// Autoremove uninstalls virtual machines that were installed as dependencies
+// but aren't required anymore. confirm is called with the virtual machines
+// before they're removed; if it's nil they're removed without confirmation.
+func (a *APM) Autoremove(confirm func(names []string) (bool, error)) error {
+	if err := a.acquireLock(); err != nil {
+		return err
+	}
+	defer a.releaseLock()
+
+	return a.autoremove(confirm)
+}
+
+// autoremove uninstalls orphaned virtual machines. Callers must hold the
+// lock.
+func (a *APM) autoremove(confirm func(names []string) (bool, error)) error {
+	return a.executor.Execute(workflow.NewAutoremove(workflow.AutoremoveConfig{
+		Executor:   a.executor,
+		StateFile:  a.stateFile,
+		Fs:         a.fs,
+		PluginPath: a.pluginPath,
+		Confirm:    confirm,
+	}))
+}
+
 func (a *APM) JoinSubnet(alias string) error {
 	return a.parseAndRun(alias, a.joinSubnet)
 }
 
 func (a *APM) joinSubnet(fullName string) error {
-	alias, plugin := util.ParseQualifiedName(fullName)
-	repo, err := a.repoFactory.GetRepository(alias)
+	if err := a.acquireLock(); err != nil {
+		return err
+	}
+	defer a.releaseLock()
+
+	return a.joinSubnetLocked(fullName)
+}
+
+// joinSubnetLocked joins the subnet. Callers must hold the lock.
+func (a *APM) joinSubnetLocked(fullName string) error {
+	subnet, subnetID, err := a.getSubnet(fullName)
 	if err != nil {
 		return err
 	}
 
-	definition, err := repo.GetSubnet(plugin)
+	// Resolve all dependencies before we start downloading anything.
+	plan, err := a.resolver.ResolveSubnet(fullName)
 	if err != nil {
 		return err
 	}
 
-	subnet := definition.Definition
-	subnetID, _ := subnet.GetID(constant.DefaultNetwork)
+	installs, err := a.installWorkflows(plan, "")
+	if err != nil {
+		return err
+	}
 
-	// TODO prompt user, add force flag
-	fmt.Printf("Installing virtual machines for subnet %s.\n", subnetID)
-	for _, vm := range subnet.VMs {
-		if err := a.Install(strings.Join([]string{alias, vm}, constant.QualifiedNameDelimiter)); err != nil {
-			return err
+	vms := make([]string, 0, len(plan.VMs))
+	for _, step := range plan.VMs {
+		vms = append(vms, step.Name)
+	}
+
+	return a.executor.Execute(workflow.NewJoinSubnet(workflow.JoinSubnetConfig{
+		Executor:         a.executor,
+		Name:             fullName,
+		SubnetID:         subnetID,
+		Network:          a.network,
+		Subnet:           subnet,
+		VMs:              vms,
+		Installs:         installs,
+		StateFile:        a.stateFile,
+		AdminClient:      a.adminClient,
+		AdminAPIEndpoint: a.adminAPIEndpoint,
+		NodeConfig:       a.nodeConfig,
+		ConfigDir:        a.configDir,
+	}))
+}
+
+func (a *APM) LeaveSubnet(alias string) error {
+	// The subnet's definition may have been removed since it was joined, so
+	// look for it in the joined subnets first.
+	if fullName, ok := a.joinedSubnet(alias); ok {
+		return a.leaveSubnet(fullName)
+	}
+
+	return a.parseAndRun(alias, a.leaveSubnet)
+}
+
+// joinedSubnet returns the fully qualified name of the only joined subnet
+// matching alias.
+func (a *APM) joinedSubnet(alias string) (string, bool) {
+	if qualifiedName(alias) {
+		_, ok := a.stateFile.Subnets[alias]
+		return alias, ok
+	}
+
+	matches := make([]string, 0, 1)
+	for name := range a.stateFile.Subnets {
+		if _, plugin := util.ParseQualifiedName(name); plugin == alias {
+			matches = append(matches, name)
 		}
 	}
+	if len(matches) != 1 {
+		return "", false
+	}
 
-	fmt.Printf("Updating virtual machines...\n")
-	if err := a.adminClient.LoadVMs(); errors.Is(err, syscall.ECONNREFUSED) {
-		fmt.Printf("Node at %s was offline. Virtual machines will be available upon node startup.\n", a.adminAPIEndpoint)
-	} else if err != nil {
+	return matches[0], true
+}
+
+func (a *APM) leaveSubnet(fullName string) error {
+	if err := a.acquireLock(); err != nil {
 		return err
 	}
+	defer a.releaseLock()
 
-	fmt.Printf("Whitelisting subnet %s...\n", subnetID)
-	if err := a.adminClient.WhitelistSubnet(subnetID); errors.Is(err, syscall.ECONNREFUSED) {
-		fmt.Printf("Node at %s was offline. You'll need to whitelist the subnet upon node restart.\n", a.adminAPIEndpoint)
-	} else if err != nil {
-		return err
+	return a.leaveSubnetLocked(fullName)
+}
+
+// leaveSubnetLocked leaves the subnet on the network it was joined on. Callers
+// must hold the lock.
+func (a *APM) leaveSubnetLocked(fullName string) error {
+	return a.executor.Execute(workflow.NewLeaveSubnet(workflow.LeaveSubnetConfig{
+		Executor:   a.executor,
+		Name:       fullName,
+		NodeConfig: a.nodeConfig,
+		ConfigDir:  a.configDir,
+		StateFile:  a.stateFile,
+		Fs:         a.fs,
+		PluginPath: a.pluginPath,
+	}))
+}
+
+// getSubnet returns the definition of the subnet with the provided fully
+// qualified name and its id on the apm's network.
+func (a *APM) getSubnet(fullName string) (types.Subnet, string, error) {
+	alias, plugin := util.ParseQualifiedName(fullName)
+	repo, err := a.repoFactory.GetRepository(alias)
+	if err != nil {
+		return types.Subnet{}, "", err
 	}
 
-	fmt.Printf("Finished installing virtual machines for subnet %s.\n", subnet.ID)
-	return nil
+	definition, err := repo.GetSubnet(plugin)
+	if err != nil {
+		return types.Subnet{}, "", err
+	}
+
+	subnetID, ok := definition.Definition.GetID(a.network)
+	if !ok {
+		return types.Subnet{}, "", fmt.Errorf("subnet %s is not available on %s (available on: %s)", fullName, a.network, strings.Join(definition.Definition.Networks(), ", "))
+	}
+
+	return definition.Definition, subnetID, nil
 }
 
 func (a *APM) Info(alias string) error {
@@ -268,13 +996,16 @@ func (a *APM) info(_ string) error {
 }
 
 func (a *APM) Update() error {
-	if err := a.lock.TryLock(); err != nil {
+	if err := a.acquireLock(); err != nil {
 		return err
 	}
-	defer func() {
-		_ = a.lock.Unlock()
-	}()
+	defer a.releaseLock()
+
+	return a.update()
+}
 
+// update syncs every tracked repository. Callers must hold the lock.
+func (a *APM) update() error {
 	workflow := workflow.NewUpdate(workflow.UpdateConfig{
 		Executor:         a.executor,
 		StateFile:        a.stateFile,
@@ -288,89 +1019,198 @@ func (a *APM) Update() error {
 		Git:              a.git,
 	})
 
-	if err := a.executor.Execute(workflow); err != nil {
+	err := a.executor.Execute(workflow)
+	a.recordUpdated()
+	return err
+}
+
+func (a *APM) Upgrade(alias string) (err error) {
+	if err := a.acquireLock(); err != nil {
 		return err
 	}
+	defer a.releaseLock()
+	defer func() { a.settleUpgrades(err) }()
+
+	return a.upgradeAndReload(func() error {
+		// If we have an alias specified, upgrade the specified VM. Otherwise,
+		// just upgrade everything.
+		if alias != "" {
+			return a.parseAndRun(alias, a.upgradeVM)
+		}
+		return a.upgrade()
+	})
+}
 
-	return nil
+// UpgradeVMs upgrades the virtual machines with the provided fully qualified
+// names, then reloads or restarts the node once for all of them. A virtual
+// machine that fails to upgrade doesn't stop the others. Returns why each
+// virtual machine that isn't running its upgrade failed, including the ones the
+// node rolled back, and the error reloading or restarting the node, if any.
+func (a *APM) UpgradeVMs(names []string) (failed map[string]error, err error) {
+	if err := a.acquireLock(); err != nil {
+		return nil, err
+	}
+	defer a.releaseLock()
+	defer func() { a.settleUpgrades(err) }()
+
+	failed = make(map[string]error)
+	before := a.installedCommits()
+	err = a.upgradeAndReload(func() error {
+		for _, name := range names {
+			if err := a.upgradeVM(name); err != nil && !errors.Is(err, workflow.ErrAlreadyUpdated) {
+				failed[name] = err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return failed, nil
+	}
+
+	for _, name := range names {
+		installInfo, installed := a.stateFile.InstallationRegistry[name]
+		if _, ok := failed[name]; !ok && installed && installInfo.Commit == before[name] {
+			failed[name] = err
+		}
+	}
+
+	return failed, err
 }
 
-func (a *APM) Upgrade(alias string) error {
-	if err := a.lock.TryLock(); err != nil {
+// upgradeAndReload runs upgrade, then reloads or restarts the node with the
+// virtual machines it upgraded. Upgrades the node fails to load or run are
+// rolled back. Callers must hold the lock.
+func (a *APM) upgradeAndReload(upgrade func() error) error {
+	before := a.installedCommits()
+
+	backups, err := a.backupBinaries()
+	if err != nil {
 		return err
 	}
-	defer func() {
-		_ = a.lock.Unlock()
-	}()
+	defer a.removeBackups()
 
-	// If we have an alias specified, upgrade the specified VM.
-	if alias != "" {
-		return a.parseAndRun(alias, a.upgradeVM)
+	if err := upgrade(); err != nil {
+		return err
 	}
 
-	// Otherwise, just upgrade everything.
+	// Upgrades that failed to load have already been rolled back, but the
+	// rest still need a restart to run.
+	reloadErr := a.reloadChanges(before, backups)
+	if reloadErr != nil && !errors.Is(reloadErr, workflow.ErrLoadFailed) {
+		return reloadErr
+	}
+
+	if err := a.restartChanges(before, backups); err != nil {
+		return err
+	}
+
+	return reloadErr
+}
+
+// upgrade upgrades every installed virtual machine. Callers must hold the
+// lock.
+func (a *APM) upgrade() error {
 	wf := workflow.NewUpgrade(workflow.UpgradeConfig{
-		Executor:    a.executor,
-		RepoFactory: a.repoFactory,
-		StateFile:   a.stateFile,
-		TmpPath:     a.tmpPath,
-		PluginPath:  a.pluginPath,
-		Installer:   a.installer,
-		Fs:          a.fs,
-		Git:         a.git,
+		Executor:      a.executor,
+		RepoFactory:   a.repoFactory,
+		StateFile:     a.stateFile,
+		TmpPath:       a.tmpPath,
+		PluginPath:    a.pluginPath,
+		Installer:     a.installer,
+		Compatibility: a.compatibility,
+		Fs:            a.fs,
+		Git:           a.git,
+		Dependencies:  a.installDependencies,
 	})
 
 	return a.executor.Execute(wf)
 }
 
+// upgradeVM upgrades the virtual machine. Callers must hold the lock.
 func (a *APM) upgradeVM(name string) error {
 	return a.executor.Execute(workflow.NewUpgradeVM(
 		workflow.UpgradeVMConfig{
-			Executor:    a.executor,
-			FullVMName:  name,
-			RepoFactory: a.repoFactory,
-			StateFile:   a.stateFile,
-			TmpPath:     a.tmpPath,
-			PluginPath:  a.pluginPath,
-			Installer:   a.installer,
-			Fs:          a.fs,
-			Git:         a.git,
+			Executor:      a.executor,
+			FullVMName:    name,
+			RepoFactory:   a.repoFactory,
+			StateFile:     a.stateFile,
+			TmpPath:       a.tmpPath,
+			PluginPath:    a.pluginPath,
+			Installer:     a.installer,
+			Compatibility: a.compatibility,
+			Fs:            a.fs,
+			Git:           a.git,
+			Dependencies:  a.installDependencies,
 		},
 	))
 }
 
+// installDependencies returns the workflows that install the dependencies of
+// the virtual machine that aren't installed yet. Callers must hold the lock.
+func (a *APM) installDependencies(name string) ([]workflow.Workflow, error) {
+	plan, err := a.resolver.ResolveVM(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]workflow.Workflow, 0)
+	for _, step := range plan.Pending() {
+		install, err := a.newInstall(step.Name, state.DependencyInstall)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, install)
+	}
+
+	return result, nil
+}
+
 func (a *APM) AddRepository(alias string, url string, branch string) error {
-	if err := a.lock.TryLock(); err != nil {
+	if err := a.acquireLock(); err != nil {
 		return err
 	}
-	defer func() {
-		_ = a.lock.Unlock()
-	}()
+	defer a.releaseLock()
+
+	return a.addRepository(alias, url, branch)
+}
 
+// addRepository tracks the repository. Callers must hold the lock.
+func (a *APM) addRepository(alias string, url string, branch string) error {
 	if !util.ValidAlias(alias) {
 		return fmt.Errorf("%s is not a valid alias (must be in the form of organization/repository)", alias)
 	}
 
 	wf := workflow.NewAddRepository(
 		workflow.AddRepositoryConfig{
-			SourcesList: a.stateFile.Sources,
-			Alias:       alias,
-			URL:         url,
-			Branch:      plumbing.NewBranchReferenceName(branch),
+			SourcesList:      a.stateFile.Sources,
+			Alias:            alias,
+			URL:              url,
+			Branch:           plumbing.NewBranchReferenceName(branch),
+			RepositoriesPath: a.repositoriesPath,
+			Auth:             a.auth,
+			Git:              a.git,
+			RepoFactory:      a.repoFactory,
+			Fs:               a.fs,
 		},
 	)
 
-	return a.executor.Execute(wf)
+	err := a.executor.Execute(wf)
+	a.recordUpdated()
+	return err
 }
 
 func (a *APM) RemoveRepository(alias string) error {
-	if err := a.lock.TryLock(); err != nil {
+	if err := a.acquireLock(); err != nil {
 		return err
 	}
-	defer func() {
-		_ = a.lock.Unlock()
-	}()
+	defer a.releaseLock()
+
+	return a.removeRepository(alias)
+}
 
+// removeRepository stops tracking the repository. Callers must hold the
+// lock.
+func (a *APM) removeRepository(alias string) error {
 	return a.executor.Execute(workflow.NewRemoveRepository(
 		workflow.RemoveRepositoryConfig{
 			SourcesList:      a.stateFile.Sources,
@@ -381,12 +1221,10 @@ func (a *APM) RemoveRepository(alias string) error {
 }
 
 func (a *APM) ListRepositories() error {
-	if err := a.lock.TryLock(); err != nil {
+	if err := a.acquireLock(); err != nil {
 		return err
 	}
-	defer func() {
-		_ = a.lock.Unlock()
-	}()
+	defer a.releaseLock()
 
 	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
 	fmt.Fprintln(w, "alias\turl\tbranch")
@@ -397,6 +1235,95 @@ func (a *APM) ListRepositories() error {
 	return nil
 }
 
+// AvailableUpgrade is an installed virtual machine whose definition has
+// changed since it was installed.
+type AvailableUpgrade struct {
+	Name      string `json:"name"`
+	Installed string `json:"installed"`
+	Latest    string `json:"latest"`
+}
+
+// AvailableUpgrades returns the installed virtual machines that upgrade would
+// reinstall, in a deterministic order.
+func (a *APM) AvailableUpgrades() ([]AvailableUpgrade, error) {
+	if err := a.acquireLock(); err != nil {
+		return nil, err
+	}
+	defer a.releaseLock()
+
+	result := make([]AvailableUpgrade, 0)
+	for _, name := range util.SortedKeys(a.stateFile.InstallationRegistry) {
+		alias, plugin := util.ParseQualifiedName(name)
+		// Like upgrade, skip virtual machines that are no longer in a tracked
+		// repository.
+		repository, err := a.repoFactory.GetRepository(alias)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if _, err := repository.GetVM(plugin); err != nil {
+			continue
+		}
+
+		latest, err := a.git.GetLastModified(repository.GetPath(), fmt.Sprintf("vms/%s.yaml", plugin))
+		if err != nil {
+			return nil, err
+		}
+
+		installed := a.stateFile.InstallationRegistry[name].Commit
+		if installed != latest {
+			result = append(result, AvailableUpgrade{
+				Name:      name,
+				Installed: installed,
+				Latest:    latest,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// ListSubnets writes the subnets in every tracked repository and the networks
+// they're available on to w.
+func (a *APM) ListSubnets(w io.Writer) error {
+	if err := a.acquireLock(); err != nil {
+		return err
+	}
+	defer a.releaseLock()
+
+	aliases := make([]string, 0, len(a.stateFile.Sources))
+	for alias := range a.stateFile.Sources {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintf(tw, "subnet\tnetworks\tavailable on %s\n", a.network)
+	for _, alias := range aliases {
+		repository, err := a.repoFactory.GetRepository(alias)
+		if err != nil {
+			return err
+		}
+
+		subnets, err := repository.ListSubnets()
+		if err != nil {
+			return err
+		}
+
+		for _, subnet := range subnets {
+			definition, err := repository.GetSubnet(subnet)
+			if err != nil {
+				return err
+			}
+
+			_, available := definition.Definition.GetID(a.network)
+			fmt.Fprintf(tw, "%s\t%s\t%t\n", util.QualifyName(alias, subnet), strings.Join(definition.Definition.Networks(), ","), available)
+		}
+	}
+	return tw.Flush()
+}
+
 func qualifiedName(name string) bool {
 	parsed := strings.Split(name, ":")
 	return len(parsed) > 1
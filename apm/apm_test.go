@@ -0,0 +1,395 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package apm
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/juju/fslock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/dependency"
+	"github.com/ava-labs/apm/engine"
+	"github.com/ava-labs/apm/git"
+	"github.com/ava-labs/apm/lockfile"
+	"github.com/ava-labs/apm/node"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+	"github.com/ava-labs/apm/workflow"
+)
+
+func TestInstallWorkflowsMarksExplicit(t *testing.T) {
+	const (
+		vm         = "organization/repository:vm"
+		requiredBy = "organization/repository:requiredBy"
+	)
+
+	tests := []struct {
+		name     string
+		explicit string
+		dryRun   bool
+		want     state.InstallReason
+		// committed is whether the promotion is committed.
+		committed bool
+	}{
+		{
+			name:      "explicit install of a dependency",
+			explicit:  vm,
+			want:      state.ExplicitInstall,
+			committed: true,
+		},
+		{
+			name:     "explicit install of a dependency during a dry run",
+			explicit: vm,
+			dryRun:   true,
+			want:     state.DependencyInstall,
+		},
+		{
+			name: "dependency of another install",
+			want: state.DependencyInstall,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			stateFile, err := state.New(dir)
+			require.NoError(t, err)
+			stateFile.InstallationRegistry[vm] = &state.InstallInfo{Reason: state.DependencyInstall, RequiredBy: []string{requiredBy}}
+
+			a := &APM{
+				stateFile: stateFile,
+				executor:  engine.NewWorkflowEngine(engine.WorkflowEngineConfig{StateFile: stateFile}),
+			}
+			if test.dryRun {
+				a.dryRun = engine.NewDryRunEngine()
+				a.executor = a.dryRun
+			}
+			installs, err := a.installWorkflows(dependency.Plan{VMs: []dependency.Step{{Name: vm, Installed: true}}}, test.explicit)
+			require.NoError(t, err)
+			assert.Empty(t, installs)
+
+			assert.Equal(t, test.want, a.stateFile.InstallationRegistry[vm].Reason)
+
+			committed, err := state.New(dir)
+			require.NoError(t, err)
+			assert.Equal(t, test.committed, committed.InstallationRegistry[vm] != nil)
+		})
+	}
+}
+
+func TestListSubnets(t *testing.T) {
+	subnet := state.Definition[types.Subnet]{
+		Definition: types.Subnet{
+			ID: map[string]string{
+				"mainnet": "mainnetID",
+				"1337":    "customID",
+			},
+		},
+	}
+
+	tests := []struct {
+		network string
+		want    string
+	}{
+		{
+			network: "mainnet",
+			want: "subnet                         networks     available on mainnet\n" +
+				"organization/repository:subnet 1337,mainnet true\n",
+		},
+		{
+			network: "fuji",
+			want: "subnet                         networks     available on fuji\n" +
+				"organization/repository:subnet 1337,mainnet false\n",
+		},
+		{
+			network: "network-1337",
+			want: "subnet                         networks     available on network-1337\n" +
+				"organization/repository:subnet 1337,mainnet true\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			stateFile, err := state.New(t.TempDir())
+			require.NoError(t, err)
+			stateFile.Sources["organization/repository"] = &state.SourceInfo{}
+
+			repository := state.NewMockRepository(ctrl)
+			repository.EXPECT().ListSubnets().Return([]string{"subnet"}, nil)
+			repository.EXPECT().GetSubnet("subnet").Return(subnet, nil)
+			repoFactory := state.NewMockRepositoryFactory(ctrl)
+			repoFactory.EXPECT().GetRepository("organization/repository").Return(repository, nil)
+
+			lockPath := filepath.Join(t.TempDir(), lockFile)
+			a := &APM{
+				stateFile:   stateFile,
+				repoFactory: repoFactory,
+				network:     test.network,
+				lock:        fslock.New(lockPath),
+				lockPath:    lockPath,
+			}
+
+			w := &bytes.Buffer{}
+			require.NoError(t, a.ListSubnets(w))
+			assert.Equal(t, test.want, w.String())
+		})
+	}
+}
+
+func TestCheckoutLocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stateFile, err := state.New(t.TempDir())
+	require.NoError(t, err)
+	stateFile.Sources["organization/updated"] = &state.SourceInfo{Commit: "updated"}
+	stateFile.Sources["organization/unchanged"] = &state.SourceInfo{Commit: "locked"}
+
+	lockFile := lockfile.New()
+	lockFile.Repositories["organization/updated"] = lockfile.Repository{Commit: "locked"}
+	lockFile.Repositories["organization/unchanged"] = lockfile.Repository{Commit: "locked"}
+	// Repositories that aren't tracked are left to the resolver.
+	lockFile.Repositories["organization/untracked"] = lockfile.Repository{Commit: "locked"}
+
+	gitFactory := git.NewMockFactory(ctrl)
+	a := &APM{stateFile: stateFile, lockFile: &lockFile, git: gitFactory, repositoriesPath: "repositories"}
+
+	path := filepath.Join("repositories", "organization", "updated")
+	gomock.InOrder(
+		gitFactory.EXPECT().Checkout(path, "locked", &a.auth).Return(nil),
+		gitFactory.EXPECT().Checkout(path, "updated", &a.auth).Return(nil),
+	)
+
+	previous, err := a.checkoutLocked()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"organization/updated": "updated"}, previous)
+	assert.NoError(t, a.checkout(previous))
+}
+
+func TestVerifyRepair(t *testing.T) {
+	const vm = "organization/repository:vm"
+
+	tests := []struct {
+		name       string
+		definition string
+		wantErr    bool
+	}{
+		{
+			name:       "definition unchanged",
+			definition: "installed",
+		},
+		{
+			name:       "definition moved",
+			definition: "updated",
+			wantErr:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			stateFile, err := state.New(t.TempDir())
+			require.NoError(t, err)
+			// the binary is missing from the plugin directory
+			stateFile.InstallationRegistry[vm] = &state.InstallInfo{ID: "id", Commit: "installed", SHA256: "sha256", Reason: state.ExplicitInstall}
+
+			repository := state.NewMockRepository(ctrl)
+			repository.EXPECT().GetVM("vm").Return(state.Definition[types.VM]{Commit: test.definition}, nil)
+			repoFactory := state.NewMockRepositoryFactory(ctrl)
+			repoFactory.EXPECT().GetRepository("organization/repository").Return(repository, nil).MinTimes(1)
+
+			executor := workflow.NewMockExecutor(ctrl)
+			executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.Verify{})).DoAndReturn(func(wf workflow.Workflow) error {
+				return wf.Execute()
+			})
+			if !test.wantErr {
+				executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.Install{})).Return(nil)
+			}
+
+			lockPath := filepath.Join(t.TempDir(), lockFile)
+			a := &APM{
+				stateFile:   stateFile,
+				repoFactory: repoFactory,
+				executor:    executor,
+				fs:          afero.NewMemMapFs(),
+				pluginPath:  "plugins",
+				lock:        fslock.New(lockPath),
+				lockPath:    lockPath,
+			}
+
+			err = a.verify([]string{vm}, true)
+			if test.wantErr {
+				assert.ErrorContains(t, err, "can't repair")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUpgradeRestartsAfterLoadFailure(t *testing.T) {
+	const (
+		failed   = "organization/repository:failed"
+		upgraded = "organization/repository:upgraded"
+	)
+	ctrl := gomock.NewController(t)
+
+	stateFile, err := state.New(t.TempDir())
+	require.NoError(t, err)
+	stateFile.InstallationRegistry[failed] = &state.InstallInfo{ID: "failedID", Commit: "old"}
+	stateFile.InstallationRegistry[upgraded] = &state.InstallInfo{ID: "upgradedID", Commit: "old"}
+
+	executor := workflow.NewMockExecutor(ctrl)
+	gomock.InOrder(
+		executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.Upgrade{})).DoAndReturn(func(workflow.Workflow) error {
+			stateFile.InstallationRegistry[failed].Commit = "new"
+			stateFile.InstallationRegistry[upgraded].Commit = "new"
+			return nil
+		}),
+		// the upgrade that failed to load is rolled back
+		executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.Reload{})).DoAndReturn(func(workflow.Workflow) error {
+			stateFile.InstallationRegistry[failed].Commit = "old"
+			return workflow.ErrLoadFailed
+		}),
+		executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.Restart{})).Return(nil),
+	)
+
+	lockPath := filepath.Join(t.TempDir(), lockFile)
+	a := &APM{
+		stateFile:  stateFile,
+		executor:   executor,
+		fs:         afero.NewMemMapFs(),
+		tmpPath:    "tmp",
+		pluginPath: "plugins",
+		reload:     true,
+		restarter:  node.NewMockRestarter(ctrl),
+		lock:       fslock.New(lockPath),
+		lockPath:   lockPath,
+	}
+
+	assert.ErrorIs(t, a.Upgrade(""), workflow.ErrLoadFailed)
+}
+
+func TestUpgradeVMs(t *testing.T) {
+	const (
+		broken   = "organization/repository:broken"
+		failed   = "organization/repository:failed"
+		upgraded = "organization/repository:upgraded"
+	)
+	errWrong := errors.New("something went wrong")
+	ctrl := gomock.NewController(t)
+
+	stateFile, err := state.New(t.TempDir())
+	require.NoError(t, err)
+	for _, name := range []string{broken, failed, upgraded} {
+		stateFile.InstallationRegistry[name] = &state.InstallInfo{ID: name, Commit: "old"}
+	}
+
+	// The node is only reloaded and restarted once for every upgrade.
+	executor := workflow.NewMockExecutor(ctrl)
+	gomock.InOrder(
+		executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.UpgradeVM{})).Return(errWrong),
+		executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.UpgradeVM{})).DoAndReturn(func(workflow.Workflow) error {
+			stateFile.InstallationRegistry[failed].Commit = "new"
+			return nil
+		}),
+		executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.UpgradeVM{})).DoAndReturn(func(workflow.Workflow) error {
+			stateFile.InstallationRegistry[upgraded].Commit = "new"
+			return nil
+		}),
+		executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.Reload{})).DoAndReturn(func(workflow.Workflow) error {
+			stateFile.InstallationRegistry[failed].Commit = "old"
+			return workflow.ErrLoadFailed
+		}),
+		executor.EXPECT().Execute(gomock.AssignableToTypeOf(&workflow.Restart{})).Return(nil),
+	)
+
+	lockPath := filepath.Join(t.TempDir(), lockFile)
+	a := &APM{
+		stateFile:  stateFile,
+		executor:   executor,
+		fs:         afero.NewMemMapFs(),
+		tmpPath:    "tmp",
+		pluginPath: "plugins",
+		reload:     true,
+		restarter:  node.NewMockRestarter(ctrl),
+		lock:       fslock.New(lockPath),
+		lockPath:   lockPath,
+	}
+
+	failures, err := a.UpgradeVMs([]string{broken, failed, upgraded})
+	assert.ErrorIs(t, err, workflow.ErrLoadFailed)
+	assert.Len(t, failures, 2)
+	assert.ErrorIs(t, failures[broken], errWrong)
+	assert.ErrorIs(t, failures[failed], workflow.ErrLoadFailed)
+}
+
+func TestLeaveSubnetJoined(t *testing.T) {
+	const subnet = "organization/repository:subnet"
+
+	tests := []struct {
+		name    string
+		alias   string
+		joined  bool
+		wantErr error
+	}{
+		{
+			name:   "alias",
+			alias:  "subnet",
+			joined: true,
+		},
+		{
+			name:   "fully qualified name",
+			alias:  subnet,
+			joined: true,
+		},
+		{
+			name:    "not joined",
+			alias:   subnet,
+			wantErr: workflow.ErrNotJoined,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			stateFile, err := state.New(t.TempDir())
+			require.NoError(t, err)
+			if test.joined {
+				stateFile.Subnets[subnet] = &state.SubnetInfo{ID: "subnetID", Network: "mainnet"}
+			}
+
+			// the subnet's definition isn't needed to leave it
+			nodeConfig := node.NewMockConfig(ctrl)
+			configDir := node.NewMockConfigDir(ctrl)
+			if test.joined {
+				nodeConfig.EXPECT().UntrackSubnet("subnetID").Return(true, nil)
+				configDir.EXPECT().RemoveSubnetConfig("subnetID").Return(false, nil)
+			}
+
+			lockPath := filepath.Join(t.TempDir(), lockFile)
+			a := &APM{
+				stateFile:   stateFile,
+				repoFactory: state.NewMockRepositoryFactory(ctrl),
+				executor:    engine.NewWorkflowEngine(engine.WorkflowEngineConfig{StateFile: stateFile}),
+				nodeConfig:  nodeConfig,
+				configDir:   configDir,
+				network:     "fuji",
+				lock:        fslock.New(lockPath),
+				lockPath:    lockPath,
+			}
+
+			err = a.LeaveSubnet(test.alias)
+			assert.ErrorIs(t, err, test.wantErr)
+			assert.NotContains(t, stateFile.Subnets, subnet)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package apm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/ava-labs/apm/hooks"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/util"
+	"github.com/ava-labs/apm/workflow"
+)
+
+var _ workflow.Executor = &hookedExecutor{}
+
+// hookedExecutor emits events for the workflows it executes.
+type hookedExecutor struct {
+	workflow.Executor
+	hooks     *hooks.Hooks
+	stateFile state.File
+
+	// upgrading is the upgrade that's running, if any. Upgrades reinstall the
+	// virtual machine, which is when the upgrade actually starts.
+	upgrading *workflow.UpgradeVM
+	// upgraded are the upgrades that finished but haven't been reported yet,
+	// since the node could still roll them back when it loads them.
+	upgraded []hooks.Event
+}
+
+func (h *hookedExecutor) Execute(wf workflow.Workflow) error {
+	switch wf := wf.(type) {
+	case *workflow.Install:
+		return h.install(wf)
+	case *workflow.UpgradeVM:
+		return h.upgrade(wf)
+	case *workflow.Update:
+		return h.update(wf)
+	case *workflow.JoinSubnet:
+		err := h.Executor.Execute(wf)
+		if err == nil {
+			h.hooks.Emit(hooks.Event{Type: hooks.SubnetJoined, Name: wf.Name()})
+		}
+		return err
+	default:
+		return h.Executor.Execute(wf)
+	}
+}
+
+func (h *hookedExecutor) install(wf *workflow.Install) error {
+	if h.upgrading != nil && h.upgrading.Name() == wf.Name() {
+		h.hooks.Emit(hooks.Event{
+			Type: hooks.UpgradeStarted,
+			Name: wf.Name(),
+			From: h.commit(wf.Name()),
+		})
+	}
+
+	err := h.Executor.Execute(wf)
+	if errors.Is(err, workflow.ErrChecksumMismatch) {
+		h.hooks.Emit(hooks.Event{
+			Type:  hooks.ChecksumMismatch,
+			Name:  wf.Name(),
+			Error: err.Error(),
+		})
+	}
+
+	return err
+}
+
+func (h *hookedExecutor) upgrade(wf *workflow.UpgradeVM) error {
+	from := h.commit(wf.Name())
+
+	h.upgrading = wf
+	err := h.Executor.Execute(wf)
+	h.upgrading = nil
+
+	switch {
+	case errors.Is(err, workflow.ErrAlreadyUpdated):
+	case err != nil:
+		h.hooks.Emit(hooks.Event{
+			Type:  hooks.UpgradeFailed,
+			Name:  wf.Name(),
+			From:  from,
+			Error: err.Error(),
+		})
+	case h.commit(wf.Name()) != from:
+		h.upgraded = append(h.upgraded, hooks.Event{
+			Name: wf.Name(),
+			From: from,
+			To:   h.commit(wf.Name()),
+		})
+	}
+
+	return err
+}
+
+// settle reports the upgrades that finished since the last call, once the node
+// has reloaded or restarted with them. Upgrades that were rolled back in the
+// meantime failed, with err as the reason if there's one.
+func (h *hookedExecutor) settle(err error) {
+	for _, event := range h.upgraded {
+		if h.commit(event.Name) == event.To {
+			event.Type = hooks.UpgradeSucceeded
+			h.hooks.Emit(event)
+			continue
+		}
+
+		event.Type = hooks.UpgradeFailed
+		event.Error = fmt.Sprintf("rolled back to %s", h.commit(event.Name))
+		if err != nil {
+			event.Error = fmt.Sprintf("%s: %s", err, event.Error)
+		}
+		h.hooks.Emit(event)
+	}
+
+	h.upgraded = nil
+}
+
+func (h *hookedExecutor) update(wf *workflow.Update) error {
+	before := make(map[string]string, len(h.stateFile.Sources))
+	for alias, source := range h.stateFile.Sources {
+		before[alias] = source.Commit
+	}
+
+	// Repositories updated before a failure are still reported.
+	err := h.Executor.Execute(wf)
+
+	for _, alias := range util.SortedKeys(h.stateFile.Sources) {
+		commit := h.stateFile.Sources[alias].Commit
+		// Repositories that were never synced don't have new commits.
+		previous, ok := before[alias]
+		if ok && previous != plumbing.ZeroHash.String() && previous != commit {
+			h.hooks.Emit(hooks.Event{
+				Type: hooks.RepositoryUpdated,
+				Name: alias,
+				From: previous,
+				To:   commit,
+			})
+		}
+	}
+
+	return err
+}
+
+// commit returns the installed commit of the virtual machine, if any.
+func (h *hookedExecutor) commit(name string) string {
+	installInfo, ok := h.stateFile.InstallationRegistry[name]
+	if !ok {
+		return ""
+	}
+
+	return installInfo.Commit
+}
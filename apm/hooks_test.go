@@ -0,0 +1,182 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package apm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/hooks"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/workflow"
+)
+
+func TestHookedExecutor(t *testing.T) {
+	const (
+		vm     = "organization/repository:vm"
+		subnet = "organization/repository:subnet"
+		alias  = "organization/repository"
+	)
+	errWrong := errors.New("something went wrong")
+
+	type mocks struct {
+		executor  *workflow.MockExecutor
+		hooked    *hookedExecutor
+		stateFile state.File
+	}
+	tests := []struct {
+		name       string
+		setup      func(mocks)
+		wantEvents []hooks.Event
+	}{
+		{
+			name: "upgrade succeeds",
+			setup: func(mocks mocks) {
+				install := workflow.NewInstall(workflow.InstallConfig{Name: vm})
+				upgrade := workflow.NewUpgradeVM(workflow.UpgradeVMConfig{FullVMName: vm})
+				mocks.stateFile.InstallationRegistry[vm] = &state.InstallInfo{Commit: "old"}
+
+				mocks.executor.EXPECT().Execute(upgrade).DoAndReturn(func(workflow.Workflow) error {
+					return mocks.hooked.Execute(install)
+				})
+				mocks.executor.EXPECT().Execute(install).DoAndReturn(func(workflow.Workflow) error {
+					mocks.stateFile.InstallationRegistry[vm].Commit = "new"
+					return nil
+				})
+				assert.NoError(t, mocks.hooked.Execute(upgrade))
+				mocks.hooked.settle(nil)
+				// Upgrades are only reported once.
+				mocks.hooked.settle(nil)
+			},
+			wantEvents: []hooks.Event{
+				{Type: hooks.UpgradeStarted, Name: vm, From: "old"},
+				{Type: hooks.UpgradeSucceeded, Name: vm, From: "old", To: "new"},
+			},
+		},
+		{
+			name: "upgrade rolled back",
+			setup: func(mocks mocks) {
+				upgrade := workflow.NewUpgradeVM(workflow.UpgradeVMConfig{FullVMName: vm})
+				mocks.stateFile.InstallationRegistry[vm] = &state.InstallInfo{Commit: "old"}
+
+				mocks.executor.EXPECT().Execute(upgrade).DoAndReturn(func(workflow.Workflow) error {
+					mocks.stateFile.InstallationRegistry[vm].Commit = "new"
+					return nil
+				})
+				assert.NoError(t, mocks.hooked.Execute(upgrade))
+
+				// The node failed to load the upgrade, so its backup was
+				// restored.
+				mocks.stateFile.InstallationRegistry[vm] = &state.InstallInfo{Commit: "old"}
+				mocks.hooked.settle(fmt.Errorf("1 %w", workflow.ErrLoadFailed))
+			},
+			wantEvents: []hooks.Event{
+				{Type: hooks.UpgradeFailed, Name: vm, From: "old", To: "new", Error: "1 virtual machines failed to load: rolled back to old"},
+			},
+		},
+		{
+			name: "upgrade checksum mismatch",
+			setup: func(mocks mocks) {
+				install := workflow.NewInstall(workflow.InstallConfig{Name: vm})
+				upgrade := workflow.NewUpgradeVM(workflow.UpgradeVMConfig{FullVMName: vm})
+				mocks.stateFile.InstallationRegistry[vm] = &state.InstallInfo{Commit: "old"}
+				err := fmt.Errorf("%w. Expected a but saw b", workflow.ErrChecksumMismatch)
+
+				mocks.executor.EXPECT().Execute(upgrade).DoAndReturn(func(workflow.Workflow) error {
+					return mocks.hooked.Execute(install)
+				})
+				mocks.executor.EXPECT().Execute(install).Return(err)
+				assert.ErrorIs(t, mocks.hooked.Execute(upgrade), workflow.ErrChecksumMismatch)
+			},
+			wantEvents: []hooks.Event{
+				{Type: hooks.UpgradeStarted, Name: vm, From: "old"},
+				{Type: hooks.ChecksumMismatch, Name: vm, Error: "checksums did not match. Expected a but saw b"},
+				{Type: hooks.UpgradeFailed, Name: vm, From: "old", Error: "checksums did not match. Expected a but saw b"},
+			},
+		},
+		{
+			name: "already up to date",
+			setup: func(mocks mocks) {
+				upgrade := workflow.NewUpgradeVM(workflow.UpgradeVMConfig{FullVMName: vm})
+				mocks.stateFile.InstallationRegistry[vm] = &state.InstallInfo{Commit: "old"}
+
+				mocks.executor.EXPECT().Execute(upgrade).Return(workflow.ErrAlreadyUpdated)
+				assert.ErrorIs(t, mocks.hooked.Execute(upgrade), workflow.ErrAlreadyUpdated)
+			},
+		},
+		{
+			name: "update finds new commits",
+			setup: func(mocks mocks) {
+				update := workflow.NewUpdate(workflow.UpdateConfig{})
+				mocks.stateFile.Sources[alias] = &state.SourceInfo{Commit: "old"}
+				mocks.stateFile.Sources["organization/unchanged"] = &state.SourceInfo{Commit: "same"}
+				mocks.stateFile.Sources["organization/bootstrapped"] = &state.SourceInfo{Commit: plumbing.ZeroHash.String()}
+
+				mocks.executor.EXPECT().Execute(update).DoAndReturn(func(workflow.Workflow) error {
+					mocks.stateFile.Sources[alias].Commit = "new"
+					mocks.stateFile.Sources["organization/bootstrapped"].Commit = "first"
+					return errWrong
+				})
+				assert.ErrorIs(t, mocks.hooked.Execute(update), errWrong)
+			},
+			wantEvents: []hooks.Event{
+				{Type: hooks.RepositoryUpdated, Name: alias, From: "old", To: "new"},
+			},
+		},
+		{
+			name: "subnet joined",
+			setup: func(mocks mocks) {
+				join := workflow.NewJoinSubnet(workflow.JoinSubnetConfig{Name: subnet})
+
+				mocks.executor.EXPECT().Execute(join).Return(nil)
+				assert.NoError(t, mocks.hooked.Execute(join))
+			},
+			wantEvents: []hooks.Event{
+				{Type: hooks.SubnetJoined, Name: subnet},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var events []hooks.Event
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				event := hooks.Event{}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+				// Events are timestamped when they're emitted.
+				assert.False(t, event.Time.IsZero())
+				event.Time = time.Time{}
+				events = append(events, event)
+			}))
+			defer server.Close()
+
+			h, err := hooks.New([]hooks.Config{{URL: server.URL}})
+			require.NoError(t, err)
+
+			stateFile, err := state.New(t.TempDir())
+			require.NoError(t, err)
+
+			executor := workflow.NewMockExecutor(ctrl)
+			test.setup(mocks{
+				executor:  executor,
+				hooked:    &hookedExecutor{Executor: executor, hooks: h, stateFile: stateFile},
+				stateFile: stateFile,
+			})
+
+			assert.Equal(t, test.wantEvents, events)
+		})
+	}
+}
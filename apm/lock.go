@@ -0,0 +1,142 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package apm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/juju/fslock"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ava-labs/apm/audit"
+)
+
+// errLockHeld is returned when an operation tries to take the lock while
+// another operation in this process holds it. Operations that run other
+// operations must use their unlocked variants.
+var errLockHeld = errors.New("the lock is already held by this process")
+
+// holder is the apm process holding the lock. It's written into the lock file
+// so other processes can tell who they're waiting on.
+type holder struct {
+	PID     int       `yaml:"pid"`
+	Command string    `yaml:"command"`
+	Started time.Time `yaml:"started"`
+}
+
+func (h holder) String() string {
+	return fmt.Sprintf("process %d (%s), running since %s", h.PID, h.Command, h.Started.Format(time.RFC3339))
+}
+
+// alive returns whether the holder's process is still running.
+func (h holder) alive() bool {
+	process, err := os.FindProcess(h.PID)
+	if err != nil {
+		return false
+	}
+
+	err = process.Signal(syscall.Signal(0))
+	// EPERM means the process exists but is owned by someone else.
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// acquireLock takes the lock. If another process holds it, this fails unless
+// the apm was configured to wait for it.
+func (a *APM) acquireLock() error {
+	// flock treats each open file separately, so taking the lock twice would
+	// wait on ourselves forever.
+	if a.held {
+		return errLockHeld
+	}
+
+	err := a.lock.TryLock()
+	if errors.Is(err, fslock.ErrLocked) {
+		err = a.waitForLock()
+	}
+	if err != nil {
+		return err
+	}
+
+	a.held = true
+	if err := a.writeHolder(); err != nil {
+		a.releaseLock()
+		return err
+	}
+
+	return nil
+}
+
+func (a *APM) waitForLock() error {
+	description := "another apm process"
+	if h, ok := a.readHolder(); ok && !h.alive() {
+		// The holder died, but a process it started inherited the lock, so it's
+		// still held. Only the holder recorded in the lock file is stale.
+		if err := os.Truncate(a.lockPath, 0); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		description = fmt.Sprintf("a process started by %s, which is no longer running", h)
+	} else if ok {
+		description = h.String()
+	}
+
+	if !a.wait {
+		return fmt.Errorf("%s is held by %s. Pass --wait to wait for it to finish: %w", lockFile, description, fslock.ErrLocked)
+	}
+
+	fmt.Printf("Waiting for %s held by %s...\n", lockFile, description)
+	if a.lockTimeout == 0 {
+		return a.lock.Lock()
+	}
+
+	if err := a.lock.LockWithTimeout(a.lockTimeout); errors.Is(err, fslock.ErrTimeout) {
+		return fmt.Errorf("timed out after %s waiting for %s held by %s: %w", a.lockTimeout, lockFile, description, err)
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// releaseLock releases the lock. Errors are ignored since the lock is released
+// when the process exits anyways.
+func (a *APM) releaseLock() {
+	a.held = false
+	_ = os.Truncate(a.lockPath, 0)
+	_ = a.lock.Unlock()
+}
+
+// readHolder returns the process recorded in the lock file, if there is one.
+func (a *APM) readHolder() (holder, bool) {
+	bytes, err := os.ReadFile(a.lockPath)
+	if err != nil || len(bytes) == 0 {
+		return holder{}, false
+	}
+
+	h := holder{}
+	if err := yaml.Unmarshal(bytes, &h); err != nil || h.PID == 0 {
+		return holder{}, false
+	}
+
+	return h, true
+}
+
+func (a *APM) writeHolder() error {
+	bytes, err := yaml.Marshal(holder{
+		PID:     os.Getpid(),
+		Command: strings.Join(audit.Redact(os.Args), " "),
+		Started: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(a.lockPath, bytes, perms.ReadWrite)
+}
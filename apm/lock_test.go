@@ -0,0 +1,190 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package apm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/juju/fslock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ava-labs/apm/workflow"
+)
+
+func newLockedAPM(path string, wait bool, timeout time.Duration) *APM {
+	return &APM{
+		lock:        fslock.New(path),
+		lockPath:    path,
+		wait:        wait,
+		lockTimeout: timeout,
+	}
+}
+
+// deadPID returns the pid of a process that has exited.
+func deadPID(t *testing.T) int {
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+
+	return cmd.Process.Pid
+}
+
+func TestAcquireLock(t *testing.T) {
+	tests := []struct {
+		name    string
+		wait    bool
+		timeout time.Duration
+		// holder, if set, replaces the holder recorded by the other process.
+		holder  func(t *testing.T) *holder
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "held",
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, fslock.ErrLocked) &&
+					assert.Contains(t, err.Error(), "--wait")
+			},
+		},
+		{
+			name:    "timeout",
+			wait:    true,
+			timeout: 10 * time.Millisecond,
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, fslock.ErrTimeout)
+			},
+		},
+		{
+			name: "held by a dead holder's child",
+			holder: func(t *testing.T) *holder {
+				return &holder{PID: deadPID(t), Command: "apm upgrade", Started: time.Now()}
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, fslock.ErrLocked) &&
+					assert.Contains(t, err.Error(), "no longer running")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), lockFile)
+
+			other := newLockedAPM(path, false, 0)
+			require.NoError(t, other.acquireLock())
+			defer other.releaseLock()
+
+			if test.holder != nil {
+				bytes, err := yaml.Marshal(test.holder(t))
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, bytes, 0o600))
+			}
+
+			a := newLockedAPM(path, test.wait, test.timeout)
+			err := a.acquireLock()
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			defer a.releaseLock()
+
+			h, ok := a.readHolder()
+			require.True(t, ok)
+			assert.Equal(t, os.Getpid(), h.PID)
+		})
+	}
+}
+
+func TestAcquireLockStaleHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFile)
+
+	// The holder died, but the lock is still held by a process it started.
+	other := newLockedAPM(path, false, 0)
+	require.NoError(t, other.acquireLock())
+	defer other.releaseLock()
+
+	bytes, err := yaml.Marshal(holder{PID: deadPID(t), Command: "apm upgrade", Started: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bytes, 0o600))
+
+	a := newLockedAPM(path, false, 0)
+	assert.ErrorIs(t, a.acquireLock(), fslock.ErrLocked)
+
+	// Only the stale holder is cleared. The lock file isn't replaced, so the
+	// lock is still held.
+	_, ok := a.readHolder()
+	assert.False(t, ok)
+	assert.ErrorIs(t, fslock.New(path).TryLock(), fslock.ErrLocked)
+}
+
+func TestAcquireLockAfterHolderDied(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFile)
+
+	// The holder died without releasing the lock, which releases it.
+	bytes, err := yaml.Marshal(holder{PID: deadPID(t), Command: "apm upgrade", Started: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bytes, 0o600))
+
+	a := newLockedAPM(path, false, 0)
+	require.NoError(t, a.acquireLock())
+	defer a.releaseLock()
+
+	h, ok := a.readHolder()
+	require.True(t, ok)
+	assert.Equal(t, os.Getpid(), h.PID)
+}
+
+func TestWriteHolderRedactsSecrets(t *testing.T) {
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"apm", "upgrade", "--admin-api-password", "hunter2"}
+
+	a := newLockedAPM(filepath.Join(t.TempDir(), lockFile), false, 0)
+	require.NoError(t, a.writeHolder())
+
+	h, ok := a.readHolder()
+	require.True(t, ok)
+	assert.Equal(t, "apm upgrade --admin-api-password <redacted>", h.Command)
+}
+
+func TestReleaseLockClearsHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFile)
+
+	a := newLockedAPM(path, false, 0)
+	require.NoError(t, a.acquireLock())
+	_, ok := a.readHolder()
+	require.True(t, ok)
+
+	a.releaseLock()
+	_, ok = a.readHolder()
+	assert.False(t, ok)
+}
+
+func TestAcquireLockReentrant(t *testing.T) {
+	a := newLockedAPM(filepath.Join(t.TempDir(), lockFile), true, 0)
+	require.NoError(t, a.acquireLock())
+	defer a.releaseLock()
+
+	assert.ErrorIs(t, a.acquireLock(), errLockHeld)
+}
+
+func TestDoctorStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFile)
+
+	// The holder died, but the lock is still held by a process it started.
+	other := newLockedAPM(path, false, 0)
+	require.NoError(t, other.acquireLock())
+	defer other.releaseLock()
+
+	bytes, err := yaml.Marshal(holder{PID: deadPID(t), Command: "apm upgrade", Started: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bytes, 0o600))
+
+	a := newLockedAPM(path, false, 0)
+	assert.ErrorIs(t, a.Doctor(false), workflow.ErrUnhealthy)
+}
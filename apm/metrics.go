@@ -0,0 +1,140 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package apm
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/spf13/afero"
+
+	"github.com/ava-labs/apm/git"
+	"github.com/ava-labs/apm/metrics"
+	"github.com/ava-labs/apm/workflow"
+)
+
+var (
+	_ workflow.Executor  = &meteredExecutor{}
+	_ workflow.Installer = &meteredInstaller{}
+	_ git.Factory        = &meteredGit{}
+)
+
+// meteredExecutor records the outcome of installs, upgrades and updates.
+type meteredExecutor struct {
+	workflow.Executor
+	metrics *metrics.Metrics
+
+	// upgrading is whether an upgrade is running. Upgrades reinstall the
+	// virtual machine, which shouldn't also be counted as an install.
+	upgrading bool
+}
+
+func (m *meteredExecutor) Execute(wf workflow.Workflow) error {
+	switch wf.(type) {
+	case *workflow.Install:
+		err := m.Executor.Execute(wf)
+		if m.upgrading {
+			return err
+		}
+
+		if errors.Is(err, workflow.ErrChecksumMismatch) {
+			m.metrics.ChecksumMismatch()
+		}
+		if err != nil {
+			m.metrics.Failure(metrics.InstallOperation)
+			return err
+		}
+		m.metrics.Install()
+		return nil
+	case *workflow.UpgradeVM:
+		m.upgrading = true
+		err := m.Executor.Execute(wf)
+		m.upgrading = false
+
+		switch {
+		case errors.Is(err, workflow.ErrAlreadyUpdated):
+		case err != nil:
+			if errors.Is(err, workflow.ErrChecksumMismatch) {
+				m.metrics.ChecksumMismatch()
+			}
+			m.metrics.Failure(metrics.UpgradeOperation)
+		default:
+			m.metrics.Upgrade()
+		}
+		return err
+	case *workflow.Update:
+		err := m.Executor.Execute(wf)
+		if err != nil {
+			m.metrics.Failure(metrics.UpdateOperation)
+		}
+		return err
+	default:
+		return m.Executor.Execute(wf)
+	}
+}
+
+// meteredInstaller records how many bytes were downloaded and how long it took.
+type meteredInstaller struct {
+	workflow.Installer
+	metrics *metrics.Metrics
+	fs      afero.Fs
+}
+
+func (m *meteredInstaller) Download(url string, path string) error {
+	start := time.Now()
+	if err := m.Installer.Download(url, path); err != nil {
+		return err
+	}
+	duration := time.Since(start)
+
+	info, err := m.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	m.metrics.Download(info.Size(), duration)
+	return nil
+}
+
+// meteredGit records how long it takes to sync each repository.
+type meteredGit struct {
+	git.Factory
+	metrics          *metrics.Metrics
+	repositoriesPath string
+}
+
+func (m *meteredGit) GetRepository(url string, path string, reference plumbing.ReferenceName, auth *http.BasicAuth) (string, error) {
+	start := time.Now()
+	commit, err := m.Factory.GetRepository(url, path, reference, auth)
+	if err != nil {
+		return "", err
+	}
+
+	// Repositories are stored at their alias relative to the repositories
+	// directory.
+	alias, relErr := filepath.Rel(m.repositoriesPath, path)
+	if relErr != nil {
+		alias = url
+	}
+	m.metrics.Sync(filepath.ToSlash(alias), time.Since(start))
+	return commit, nil
+}
+
+// recordUpdated reports when each repository was last updated.
+func (a *APM) recordUpdated() {
+	if a.metrics == nil {
+		return
+	}
+
+	updated := make(map[string]time.Time, len(a.stateFile.Sources))
+	for alias, source := range a.stateFile.Sources {
+		if !source.LastUpdated.IsZero() {
+			updated[alias] = source.LastUpdated
+		}
+	}
+	a.metrics.Updated(updated)
+}
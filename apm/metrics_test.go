@@ -0,0 +1,145 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package apm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/metrics"
+	"github.com/ava-labs/apm/workflow"
+)
+
+// metricsText returns the metrics in the prometheus text format.
+func metricsText(t *testing.T, m *metrics.Metrics) string {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, m.WriteTextfile(fs, "apm.prom"))
+
+	bytes, err := afero.ReadFile(fs, "apm.prom")
+	require.NoError(t, err)
+	return string(bytes)
+}
+
+func TestMeteredExecutor(t *testing.T) {
+	errWrong := errors.New("something went wrong")
+
+	install := workflow.NewInstall(workflow.InstallConfig{})
+	upgrade := workflow.NewUpgradeVM(workflow.UpgradeVMConfig{})
+	update := workflow.NewUpdate(workflow.UpdateConfig{})
+
+	tests := []struct {
+		name  string
+		setup func(executor *workflow.MockExecutor, metered *meteredExecutor)
+		want  []string
+	}{
+		{
+			name: "install",
+			setup: func(executor *workflow.MockExecutor, metered *meteredExecutor) {
+				executor.EXPECT().Execute(install).Return(nil)
+				assert.NoError(t, metered.Execute(install))
+			},
+			want: []string{"apm_installs_total 1\n"},
+		},
+		{
+			name: "install checksum mismatch",
+			setup: func(executor *workflow.MockExecutor, metered *meteredExecutor) {
+				err := fmt.Errorf("%w. Expected a but saw b", workflow.ErrChecksumMismatch)
+				executor.EXPECT().Execute(install).Return(err)
+				assert.ErrorIs(t, metered.Execute(install), workflow.ErrChecksumMismatch)
+			},
+			want: []string{
+				"apm_installs_total 0\n",
+				"apm_checksum_mismatches_total 1\n",
+				`apm_failures_total{operation="install"} 1`,
+			},
+		},
+		{
+			name: "upgrade",
+			setup: func(executor *workflow.MockExecutor, metered *meteredExecutor) {
+				// Upgrades reinstall the virtual machine through the executor.
+				executor.EXPECT().Execute(upgrade).DoAndReturn(func(workflow.Workflow) error {
+					return metered.Execute(install)
+				})
+				executor.EXPECT().Execute(install).Return(nil)
+				assert.NoError(t, metered.Execute(upgrade))
+			},
+			want: []string{
+				"apm_upgrades_total 1\n",
+				"apm_installs_total 0\n",
+			},
+		},
+		{
+			name: "already up to date",
+			setup: func(executor *workflow.MockExecutor, metered *meteredExecutor) {
+				executor.EXPECT().Execute(upgrade).Return(workflow.ErrAlreadyUpdated)
+				assert.ErrorIs(t, metered.Execute(upgrade), workflow.ErrAlreadyUpdated)
+			},
+			want: []string{"apm_upgrades_total 0\n"},
+		},
+		{
+			name: "upgrade fails",
+			setup: func(executor *workflow.MockExecutor, metered *meteredExecutor) {
+				executor.EXPECT().Execute(upgrade).Return(errWrong)
+				assert.ErrorIs(t, metered.Execute(upgrade), errWrong)
+			},
+			want: []string{
+				"apm_upgrades_total 0\n",
+				`apm_failures_total{operation="upgrade"} 1`,
+			},
+		},
+		{
+			name: "update fails",
+			setup: func(executor *workflow.MockExecutor, metered *meteredExecutor) {
+				executor.EXPECT().Execute(update).Return(errWrong)
+				assert.ErrorIs(t, metered.Execute(update), errWrong)
+			},
+			want: []string{`apm_failures_total{operation="update"} 1`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m, err := metrics.New()
+			require.NoError(t, err)
+
+			executor := workflow.NewMockExecutor(ctrl)
+			test.setup(executor, &meteredExecutor{Executor: executor, metrics: m})
+
+			text := metricsText(t, m)
+			for _, want := range test.want {
+				assert.Contains(t, text, want)
+			}
+		})
+	}
+}
+
+func TestMeteredInstaller(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m, err := metrics.New()
+	require.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	installer := workflow.NewMockInstaller(ctrl)
+	installer.EXPECT().Download("url", "archive.tar.gz").DoAndReturn(func(string, string) error {
+		return afero.WriteFile(fs, "archive.tar.gz", make([]byte, 100), perms.ReadWrite)
+	})
+
+	metered := &meteredInstaller{Installer: installer, metrics: m, fs: fs}
+	require.NoError(t, metered.Download("url", "archive.tar.gz"))
+
+	text := metricsText(t, m)
+	assert.Contains(t, text, "apm_download_bytes_total 100\n")
+	assert.Contains(t, text, "apm_download_duration_seconds_count 1\n")
+}
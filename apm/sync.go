@@ -0,0 +1,196 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package apm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/ava-labs/apm/constant"
+	"github.com/ava-labs/apm/manifest"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/util"
+	"github.com/ava-labs/apm/workflow"
+)
+
+// Sync makes the node match the manifest. Subnets, virtual machines and
+// repositories that aren't in the manifest are removed, and the missing ones
+// are added. Running it again with the same manifest does nothing.
+func (a *APM) Sync(m manifest.Manifest) (err error) {
+	if err := a.acquireLock(); err != nil {
+		return err
+	}
+	defer a.releaseLock()
+	defer func() { a.settleUpgrades(err) }()
+
+	// Remove things first, so we don't try to leave a subnet whose repository
+	// we just removed.
+	if err := a.syncRemovals(m); err != nil {
+		return err
+	}
+
+	if err := a.syncRepositories(m); err != nil {
+		return err
+	}
+
+	before := a.installedCommits()
+	backups, err := a.backupBinaries()
+	if err != nil {
+		return err
+	}
+	defer a.removeBackups()
+
+	for _, vm := range m.VMs {
+		if err := a.syncVM(vm); err != nil {
+			return err
+		}
+	}
+	if err := a.reloadChanges(before, backups); err != nil {
+		return err
+	}
+
+	for _, subnet := range m.Subnets {
+		if _, ok := a.stateFile.Subnets[subnet]; ok {
+			continue
+		}
+
+		if err := a.joinSubnetLocked(subnet); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Node is in sync with the manifest.\n")
+	// Reasons may have been updated outside of a workflow.
+	return a.stateFile.Commit()
+}
+
+// syncRemovals leaves subnets and uninstalls virtual machines that aren't in
+// the manifest.
+func (a *APM) syncRemovals(m manifest.Manifest) error {
+	subnets := make(map[string]bool, len(m.Subnets))
+	for _, subnet := range m.Subnets {
+		subnets[subnet] = true
+	}
+
+	for _, subnet := range util.SortedKeys(a.stateFile.Subnets) {
+		if subnets[subnet] {
+			continue
+		}
+
+		if err := a.leaveSubnetLocked(subnet); err != nil {
+			return err
+		}
+	}
+
+	vms := make(map[string]bool, len(m.VMs))
+	for _, vm := range m.VMs {
+		vms[vm.Name] = true
+	}
+
+	for _, name := range util.SortedKeys(a.stateFile.InstallationRegistry) {
+		installInfo := a.stateFile.InstallationRegistry[name]
+		if vms[name] || !installInfo.IsExplicit() {
+			continue
+		}
+
+		if len(installInfo.RequiredBy) > 0 {
+			fmt.Printf("Keeping %s, which is still required by %s.\n", name, strings.Join(installInfo.RequiredBy, ", "))
+			continue
+		}
+
+		if err := a.uninstallLocked(name); err != nil {
+			return err
+		}
+	}
+
+	return a.autoremove(nil)
+}
+
+// syncRepositories tracks the repositories in the manifest and stops tracking
+// the rest. Definitions are updated if anything changed.
+func (a *APM) syncRepositories(m manifest.Manifest) error {
+	repositories := make(map[string]manifest.Repository, len(m.Repositories))
+	for _, repository := range m.Repositories {
+		repositories[repository.Alias] = repository
+	}
+
+	changed := false
+	for _, alias := range util.SortedKeys(a.stateFile.Sources) {
+		source := a.stateFile.Sources[alias]
+		repository, ok := repositories[alias]
+		if alias == constant.CoreAlias && !ok {
+			// The core repository is always tracked.
+			continue
+		}
+		if ok && source.URL == repository.URL && source.Branch == plumbing.NewBranchReferenceName(repository.Branch) {
+			continue
+		}
+
+		if err := a.removeRepository(alias); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	for _, repository := range m.Repositories {
+		if _, ok := a.stateFile.Sources[repository.Alias]; ok {
+			continue
+		}
+
+		if err := a.addRepository(repository.Alias, repository.URL, repository.Branch); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return a.update()
+}
+
+// syncVM installs the virtual machine if it isn't installed, or upgrades it if
+// it's pinned to a different version than the one installed. Definitions can
+// only be installed at the repository's current commit, so a pin that doesn't
+// match it is an error.
+func (a *APM) syncVM(vm manifest.VM) error {
+	if vm.Commit != "" {
+		alias, plugin := util.ParseQualifiedName(vm.Name)
+		repository, err := a.repoFactory.GetRepository(alias)
+		if err != nil {
+			return err
+		}
+
+		definition, err := repository.GetVM(plugin)
+		if err != nil {
+			return err
+		}
+
+		if definition.Commit != vm.Commit {
+			return fmt.Errorf("%s is pinned to %s but repository %s has %s", vm.Name, vm.Commit, alias, definition.Commit)
+		}
+	}
+
+	installInfo, ok := a.stateFile.InstallationRegistry[vm.Name]
+	if !ok {
+		return a.installLocked(vm.Name)
+	}
+
+	// It's in the manifest, so it shouldn't be autoremoved anymore.
+	installInfo.Reason = state.ExplicitInstall
+
+	if vm.Commit == "" || installInfo.Commit == vm.Commit {
+		return nil
+	}
+
+	if err := a.upgradeVM(vm.Name); err != nil && !errors.Is(err, workflow.ErrAlreadyUpdated) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/spf13/afero"
+
+	"github.com/ava-labs/apm/constant"
+)
+
+const (
+	// File is the name of the audit log in the apm directory.
+	File = "audit.jsonl"
+
+	redacted = "<redacted>"
+)
+
+// sensitive are substrings of the flags whose values aren't recorded.
+var sensitive = []string{"password", "token", "secret"}
+
+// Outcome is how a workflow ended.
+type Outcome string
+
+const (
+	Succeeded Outcome = "succeeded"
+	Failed    Outcome = "failed"
+	// Skipped workflows weren't run, like upgrades the daemon's policy didn't
+	// allow.
+	Skipped Outcome = "skipped"
+)
+
+// Entry is a workflow that was executed.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// User is who ran the apm.
+	User string `json:"user"`
+	// Command is the apm invocation the workflow was part of, with secrets
+	// redacted.
+	Command []string `json:"command,omitempty"`
+	// Workflow is the kind of workflow (e.g install).
+	Workflow string `json:"workflow"`
+	// Name is the virtual machine, subnet or repository the workflow acted
+	// on, if any.
+	Name    string  `json:"name,omitempty"`
+	Outcome Outcome `json:"outcome"`
+	Error   string  `json:"error,omitempty"`
+	// Reason is why the workflow was skipped.
+	Reason string `json:"reason,omitempty"`
+	// Commit and SHA256 are what Name was left at.
+	Commit string `json:"commit,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Commits are the commits of every repository after an update.
+	Commits map[string]string `json:"commits,omitempty"`
+}
+
+type LogConfig struct {
+	Fs   afero.Fs
+	Path string
+	// User and Command are recorded in every entry.
+	User    string
+	Command []string
+}
+
+func NewLog(config LogConfig) *Log {
+	return &Log{
+		fs:      config.Fs,
+		path:    config.Path,
+		user:    config.User,
+		command: config.Command,
+		now:     time.Now,
+	}
+}
+
+// Log is an append-only record of the workflows that were executed, stored as
+// one json object per line.
+type Log struct {
+	fs      afero.Fs
+	path    string
+	user    string
+	command []string
+	now     func() time.Time
+
+	lock sync.Mutex
+}
+
+// Record appends the entry to the log, timestamped with who ran it.
+func (l *Log) Record(entry Entry) error {
+	entry.Time = l.now()
+	entry.User = l.user
+	entry.Command = l.command
+
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	file, err := l.fs.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perms.ReadWrite)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(bytes, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Filter selects entries from the log. Zero values match everything.
+type Filter struct {
+	// Name matches entries for the virtual machine, subnet or repository,
+	// either by its fully qualified name or its alias.
+	Name     string
+	Workflow string
+	// Since excludes entries before it.
+	Since time.Time
+	// Limit is the most entries to return. The most recent are kept.
+	Limit int
+}
+
+func (f Filter) matches(entry Entry) bool {
+	if f.Workflow != "" && f.Workflow != entry.Workflow {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if f.Name == "" || f.Name == entry.Name {
+		return true
+	}
+
+	_, alias, ok := strings.Cut(entry.Name, constant.QualifiedNameDelimiter)
+	return ok && f.Name == alias
+}
+
+// Read returns the entries that match the filter, oldest first.
+func (l *Log) Read(filter Filter) ([]Entry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	result := make([]Entry, 0)
+
+	file, err := l.fs.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse line %d of %s: %w", line, l.path, err)
+		}
+
+		if !filter.matches(entry) {
+			continue
+		}
+
+		result = append(result, entry)
+		if filter.Limit > 0 && len(result) > filter.Limit {
+			result = result[1:]
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+// Write writes the entries that match the filter to w in the provided format
+// ("text" or "json").
+func (l *Log) Write(w io.Writer, filter Filter, format string) error {
+	entries, err := l.Read(filter)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case "text":
+		tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+		fmt.Fprintln(tw, "time\tuser\tworkflow\tname\toutcome\tcommit\tdetails")
+		for _, entry := range entries {
+			details := entry.Error
+			if details == "" {
+				details = entry.Reason
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				entry.Time.Format(time.RFC3339),
+				entry.User,
+				entry.Workflow,
+				entry.Name,
+				entry.Outcome,
+				entry.Commit,
+				details,
+			)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown history format %s", format)
+	}
+}
+
+// CurrentUser returns the name of the user running the apm.
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Redact returns args with the values of flags that hold secrets (e.g
+// passwords and tokens) replaced.
+func Redact(args []string) []string {
+	result := make([]string, len(args))
+	copy(result, args)
+
+	for i := 0; i < len(result); i++ {
+		arg := result[i]
+		if !strings.HasPrefix(arg, "-") || !isSensitive(arg) {
+			continue
+		}
+
+		if flag, _, ok := strings.Cut(arg, "="); ok {
+			result[i] = fmt.Sprintf("%s=%s", flag, redacted)
+		} else if i+1 < len(result) {
+			i++
+			result[i] = redacted
+		}
+	}
+
+	return result
+}
+
+func isSensitive(flag string) bool {
+	flag, _, _ = strings.Cut(flag, "=")
+	for _, s := range sensitive {
+		if strings.Contains(strings.ToLower(flag), s) {
+			return true
+		}
+	}
+
+	return false
+}
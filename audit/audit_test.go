@@ -0,0 +1,136 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	start := time.Date(2022, 7, 2, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	log := NewLog(LogConfig{
+		Fs:      afero.NewMemMapFs(),
+		Path:    "audit.jsonl",
+		User:    "user",
+		Command: []string{"apm", "upgrade"},
+	})
+	log.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	entries, err := log.Read(Filter{})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	require.NoError(t, log.Record(Entry{Workflow: "update", Outcome: Succeeded}))
+	require.NoError(t, log.Record(Entry{Workflow: "install", Name: "organization/repository:vm", Outcome: Succeeded, Commit: "commit"}))
+	require.NoError(t, log.Record(Entry{Workflow: "upgrade-vm", Name: "organization/repository:vm", Outcome: Failed, Error: "something went wrong"}))
+	require.NoError(t, log.Record(Entry{Workflow: "join-subnet", Name: "organization/repository:subnet", Outcome: Succeeded}))
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{
+			name:   "all",
+			filter: Filter{},
+			want:   []string{"update", "install", "upgrade-vm", "join-subnet"},
+		},
+		{
+			name:   "fully qualified name",
+			filter: Filter{Name: "organization/repository:vm"},
+			want:   []string{"install", "upgrade-vm"},
+		},
+		{
+			name:   "alias",
+			filter: Filter{Name: "vm"},
+			want:   []string{"install", "upgrade-vm"},
+		},
+		{
+			name:   "workflow",
+			filter: Filter{Workflow: "install"},
+			want:   []string{"install"},
+		},
+		{
+			name:   "since",
+			filter: Filter{Since: start.Add(3 * time.Minute)},
+			want:   []string{"upgrade-vm", "join-subnet"},
+		},
+		{
+			name:   "limit keeps the most recent",
+			filter: Filter{Limit: 1},
+			want:   []string{"join-subnet"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := log.Read(test.filter)
+			require.NoError(t, err)
+
+			workflows := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				assert.Equal(t, "user", entry.User)
+				assert.Equal(t, []string{"apm", "upgrade"}, entry.Command)
+				workflows = append(workflows, entry.Workflow)
+			}
+			assert.Equal(t, test.want, workflows)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	log := NewLog(LogConfig{
+		Fs:   afero.NewMemMapFs(),
+		Path: "audit.jsonl",
+		User: "user",
+	})
+	log.now = func() time.Time {
+		return time.Date(2022, 7, 2, 12, 0, 0, 0, time.UTC)
+	}
+	require.NoError(t, log.Record(Entry{Workflow: "upgrade-vm", Name: "organization/repository:vm", Outcome: Skipped, Commit: "commit", Reason: "not in the allowlist"}))
+
+	w := &bytes.Buffer{}
+	require.NoError(t, log.Write(w, Filter{}, "text"))
+	assert.Equal(t, "time                 user workflow   name                       outcome commit details\n"+
+		"2022-07-02T12:00:00Z user upgrade-vm organization/repository:vm skipped commit not in the allowlist\n", w.String())
+
+	w.Reset()
+	require.NoError(t, log.Write(w, Filter{}, "json"))
+	entries := make([]Entry, 0)
+	require.NoError(t, json.Unmarshal(w.Bytes(), &entries))
+	assert.Len(t, entries, 1)
+
+	assert.Error(t, log.Write(w, Filter{}, "yaml"))
+}
+
+func TestRedact(t *testing.T) {
+	args := []string{
+		"upgrade",
+		"--admin-api-password", "hunter2",
+		"--admin-api-token=abc",
+		"--admin-api-username", "admin",
+		"--vm", "spacesvm",
+	}
+
+	assert.Equal(t, []string{
+		"upgrade",
+		"--admin-api-password", redacted,
+		"--admin-api-token=" + redacted,
+		"--admin-api-username", "admin",
+		"--vm", "spacesvm",
+	}, Redact(args))
+	// The original args are left alone.
+	assert.Equal(t, "hunter2", args[2])
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/apm"
+)
+
+func autoremove(fs afero.Fs) *cobra.Command {
+	yes := false
+	command := &cobra.Command{
+		Use:   "autoremove",
+		Short: "Uninstalls virtual machines that were installed as dependencies and are no longer required",
+	}
+	command.PersistentFlags().BoolVarP(&yes, "yes", "y", false, "uninstall without asking for confirmation")
+
+	dryRun := addDryRunFlags(command)
+
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		return dryRun.run(fs, func(a *apm.APM) error {
+			if yes {
+				return a.Autoremove(nil)
+			}
+
+			return a.Autoremove(confirm)
+		})
+	}
+
+	return command
+}
+
+// confirm asks the user whether to continue on stdin.
+func confirm([]string) (bool, error) {
+	fmt.Printf("Do you want to continue? [y/N] ")
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		// Treat a closed stdin as a no.
+		fmt.Println()
+		return false, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
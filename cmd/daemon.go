@@ -0,0 +1,137 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ava-labs/apm/apm"
+	"github.com/ava-labs/apm/audit"
+	"github.com/ava-labs/apm/daemon"
+	"github.com/ava-labs/apm/state"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+func runDaemon(fs afero.Fs) *cobra.Command {
+	var (
+		interval time.Duration
+		mode     string
+		allow    []string
+		windows  []string
+		listen   string
+	)
+	command := &cobra.Command{
+		Use:   "daemon",
+		Short: "Periodically updates repositories and upgrades virtual machines according to a policy",
+	}
+	command.PersistentFlags().DurationVar(&interval, "interval", time.Hour, "how often to check for upgrades")
+	command.PersistentFlags().StringVar(&mode, "mode", string(daemon.NotifyOnly), fmt.Sprintf("what to do when an upgrade is available (%s or %s)", daemon.AutoUpgrade, daemon.NotifyOnly))
+	command.PersistentFlags().StringSliceVar(&allow, "allow", nil, "virtual machines that can be upgraded, by alias or fully qualified name. Defaults to all of them")
+	command.PersistentFlags().StringSliceVar(&windows, "maintenance-window", nil, "when upgrades can be applied, in the form of [days] hh:mm-hh:mm in local time (e.g \"sat,sun 22:00-02:00\"). Defaults to any time")
+	command.PersistentFlags().StringVar(&listen, "listen", "127.0.0.1:9700", "address to serve the daemon's status and prometheus metrics on")
+	reload := addReloadFlag(command)
+	restart := addRestartFlag(command)
+
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		policy := daemon.Policy{Allow: allow}
+
+		var err error
+		if policy.Mode, err = daemon.ParseMode(mode); err != nil {
+			return err
+		}
+		for _, w := range windows {
+			window, err := daemon.ParseWindow(w)
+			if err != nil {
+				return err
+			}
+			policy.Windows = append(policy.Windows, window)
+		}
+		if len(allow) > 0 {
+			stateFile, err := state.New(viper.GetString(apmPathKey))
+			if err != nil {
+				return err
+			}
+			// Profiles have their own installation registry.
+			stateFile = stateFile.ForProfile(viper.GetString(profileKey))
+			installed := make([]string, 0, len(stateFile.InstallationRegistry))
+			for name := range stateFile.InstallationRegistry {
+				installed = append(installed, name)
+			}
+			for _, unmatched := range policy.Unmatched(installed) {
+				fmt.Printf("Warning - %s in --allow doesn't match any installed virtual machine.\n", unmatched)
+			}
+		}
+		if interval <= 0 {
+			return errors.New("--interval must be positive")
+		}
+
+		d := daemon.New(daemon.Config{
+			Policy:   policy,
+			Interval: interval,
+			NewAPM: func() (daemon.APM, error) {
+				config, err := apmConfig(fs)
+				if err != nil {
+					return nil, err
+				}
+				for _, option := range []func(*apm.Config) error{reload, restart} {
+					if err := option(&config); err != nil {
+						return nil, err
+					}
+				}
+
+				return apm.New(config)
+			},
+			AuditLog: audit.NewLog(audit.LogConfig{
+				Fs:      fs,
+				Path:    filepath.Join(viper.GetString(apmPathKey), audit.File),
+				User:    audit.CurrentUser(),
+				Command: auditCommand(),
+			}),
+		})
+
+		listener, err := net.Listen("tcp", listen)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", apmMetrics.Handler())
+		mux.Handle("/", d.Handler())
+		server := &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: shutdownTimeout,
+		}
+		go func() {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Printf("Status server stopped: %s\n", err)
+			}
+		}()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		fmt.Printf("Running in %s mode every %s. Serving status on http://%s.\n", policy.Mode, interval, listener.Addr())
+		d.Run(ctx)
+
+		fmt.Printf("Shutting down.\n")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+
+	return command
+}
@@ -0,0 +1,29 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+func doctor(fs afero.Fs) *cobra.Command {
+	fix := false
+	command := &cobra.Command{
+		Use:   "doctor",
+		Short: "Checks for inconsistent state and problems with your environment",
+	}
+	command.PersistentFlags().BoolVar(&fix, "fix", false, "fix the problems that can be fixed automatically")
+
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		apm, err := initAPM(fs)
+		if err != nil {
+			return err
+		}
+
+		return apm.Doctor(fix)
+	}
+
+	return command
+}
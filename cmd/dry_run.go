@@ -0,0 +1,78 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/apm"
+)
+
+const (
+	textOutput = "text"
+	jsonOutput = "json"
+)
+
+type dryRun struct {
+	enabled bool
+	output  string
+}
+
+// addDryRunFlags registers the flags for commands that support dry runs.
+func addDryRunFlags(command *cobra.Command) *dryRun {
+	d := &dryRun{}
+	command.PersistentFlags().BoolVar(&d.enabled, "dry-run", false, "print the actions this command would take without performing them")
+	command.PersistentFlags().StringVar(&d.output, "output", textOutput, "format to print dry-run actions in (text or json)")
+
+	return d
+}
+
+// run initializes the apm and runs command with it. If this is a dry run, the
+// planned actions are printed afterwards, and everything else goes to stderr.
+// options can modify the apm's config before it's initialized.
+func (d *dryRun) run(fs afero.Fs, command func(*apm.APM) error, options ...func(*apm.Config) error) error {
+	if d.output != textOutput && d.output != jsonOutput {
+		return fmt.Errorf("unknown output format %s (must be %s or %s)", d.output, textOutput, jsonOutput)
+	}
+
+	// Progress is printed to stdout, which only has room for the plan during
+	// dry runs.
+	stdout := os.Stdout
+	if d.enabled {
+		os.Stdout = os.Stderr
+		defer func() {
+			os.Stdout = stdout
+		}()
+	}
+
+	config, err := apmConfig(fs)
+	if err != nil {
+		return err
+	}
+	config.DryRun = d.enabled
+	for _, option := range options {
+		if err := option(&config); err != nil {
+			return err
+		}
+	}
+
+	a, err := apm.New(config)
+	if err != nil {
+		return err
+	}
+
+	if err := command(a); err != nil {
+		return err
+	}
+
+	if !d.enabled {
+		return nil
+	}
+
+	return a.WritePlan(stdout, d.output)
+}
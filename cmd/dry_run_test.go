@@ -0,0 +1,51 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/apm"
+	"github.com/ava-labs/apm/workflow"
+)
+
+func TestDryRunJSONOutput(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set(apmPathKey, t.TempDir())
+	viper.Set(pluginPathKey, t.TempDir())
+	viper.Set(networkKey, "fuji")
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	d := &dryRun{enabled: true, output: jsonOutput}
+	// The apm isn't bootstrapped, which it reports before running the
+	// command.
+	runErr := d.run(afero.NewOsFs(), func(a *apm.APM) error {
+		return a.Upgrade("")
+	})
+	require.NoError(t, w.Close())
+	os.Stdout = stdout
+	require.NoError(t, runErr)
+
+	bytes, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	var actions []workflow.Action
+	assert.NoError(t, json.Unmarshal(bytes, &actions), string(bytes))
+}
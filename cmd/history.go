@@ -0,0 +1,52 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ava-labs/apm/audit"
+)
+
+func history(fs afero.Fs) *cobra.Command {
+	var (
+		filter audit.Filter
+		since  time.Duration
+		output string
+	)
+	command := &cobra.Command{
+		Use:   "history",
+		Short: "Shows the workflows recorded in the audit log",
+	}
+	command.PersistentFlags().StringVar(&filter.Name, "name", "", "only show workflows for this virtual machine, subnet or repository")
+	command.PersistentFlags().StringVar(&filter.Workflow, "workflow", "", "only show workflows of this kind (e.g install, upgrade-vm, update)")
+	command.PersistentFlags().DurationVar(&since, "since", 0, "only show workflows from this long ago (e.g 24h)")
+	command.PersistentFlags().IntVar(&filter.Limit, "limit", 20, "most workflows to show. 0 shows all of them")
+	command.PersistentFlags().StringVar(&output, "output", textOutput, "format to print the history in (text or json)")
+
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		if output != textOutput && output != jsonOutput {
+			return fmt.Errorf("unknown output format %s (must be %s or %s)", output, textOutput, jsonOutput)
+		}
+		if since > 0 {
+			filter.Since = time.Now().Add(-since)
+		}
+
+		// Reading the log doesn't need a bootstrapped apm or the node.
+		log := audit.NewLog(audit.LogConfig{
+			Fs:   fs,
+			Path: filepath.Join(viper.GetString(apmPathKey), audit.File),
+		})
+		return log.Write(os.Stdout, filter, output)
+	}
+
+	return command
+}
@@ -6,6 +6,9 @@ package cmd
 import (
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/apm"
+	"github.com/ava-labs/apm/lockfile"
 )
 
 func install(fs afero.Fs) *cobra.Command {
@@ -20,13 +23,35 @@ func install(fs afero.Fs) *cobra.Command {
 		panic(err)
 	}
 
+	locked := false
+	lockFilePath := ""
+	command.PersistentFlags().BoolVar(&locked, "locked", false, "install the artifacts recorded in the lock file from the locked repository commits, failing if they can't be reproduced")
+	command.PersistentFlags().StringVar(&lockFilePath, "lock-file", lockfile.DefaultPath, "path to the lock file to use with --locked")
+
+	reload := addReloadFlag(command)
+	dryRun := addDryRunFlags(command)
+
 	command.RunE = func(_ *cobra.Command, _ []string) error {
-		apm, err := initAPM(fs)
-		if err != nil {
-			return err
-		}
+		return dryRun.run(
+			fs,
+			func(a *apm.APM) error {
+				return a.Install(vm)
+			},
+			func(config *apm.Config) error {
+				if !locked {
+					return nil
+				}
+
+				lockFile, err := lockfile.Load(fs, lockFilePath)
+				if err != nil {
+					return err
+				}
 
-		return apm.Install(vm)
+				config.LockFile = &lockFile
+				return nil
+			},
+			reload,
+		)
 	}
 
 	return command
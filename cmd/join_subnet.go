@@ -6,6 +6,8 @@ package cmd
 import (
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/apm"
 )
 
 func joinSubnet(fs afero.Fs) *cobra.Command {
@@ -22,13 +24,12 @@ func joinSubnet(fs afero.Fs) *cobra.Command {
 		panic(err)
 	}
 
-	command.RunE = func(_ *cobra.Command, _ []string) error {
-		apm, err := initAPM(fs)
-		if err != nil {
-			return err
-		}
+	dryRun := addDryRunFlags(command)
 
-		return apm.JoinSubnet(subnet)
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		return dryRun.run(fs, func(a *apm.APM) error {
+			return a.JoinSubnet(subnet)
+		})
 	}
 
 	return command
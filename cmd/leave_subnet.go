@@ -0,0 +1,36 @@
+// Copyright (C) 2019-2021, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/apm"
+)
+
+func leaveSubnet(fs afero.Fs) *cobra.Command {
+	subnet := ""
+
+	command := &cobra.Command{
+		Use:   "leave-subnet",
+		Short: "Stops tracking a subnet.",
+	}
+
+	command.PersistentFlags().StringVar(&subnet, "subnet", "", "subnet alias to leave")
+	err := command.MarkPersistentFlagRequired("subnet")
+	if err != nil {
+		panic(err)
+	}
+
+	dryRun := addDryRunFlags(command)
+
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		return dryRun.run(fs, func(a *apm.APM) error {
+			return a.LeaveSubnet(subnet)
+		})
+	}
+
+	return command
+}
@@ -0,0 +1,28 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+func listSubnets(fs afero.Fs) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "list-subnets",
+		Short: "Lists the subnets in all tracked plugin repositories and the networks they are available on.",
+	}
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		apm, err := initAPM(fs)
+		if err != nil {
+			return err
+		}
+
+		return apm.ListSubnets(os.Stdout)
+	}
+
+	return command
+}
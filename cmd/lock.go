@@ -0,0 +1,43 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/lockfile"
+)
+
+func lock(fs afero.Fs) *cobra.Command {
+	path := ""
+	command := &cobra.Command{
+		Use:   "lock",
+		Short: "Records the installed virtual machines and repository commits in a lock file",
+	}
+	command.PersistentFlags().StringVar(&path, "file", lockfile.DefaultPath, "path to write the lock file to")
+
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		apm, err := initAPM(fs)
+		if err != nil {
+			return err
+		}
+
+		lockFile, err := apm.LockFile()
+		if err != nil {
+			return err
+		}
+
+		if err := lockFile.Write(fs, path); err != nil {
+			return err
+		}
+
+		fmt.Printf("Wrote %d virtual machines to %s.\n", len(lockFile.VMs), path)
+		return nil
+	}
+
+	return command
+}
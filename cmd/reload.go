@@ -0,0 +1,22 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/apm"
+)
+
+// addReloadFlag adds the --reload flag to command. The returned option applies
+// it to the apm's config.
+func addReloadFlag(command *cobra.Command) func(*apm.Config) error {
+	reload := false
+	command.PersistentFlags().BoolVar(&reload, "reload", false, "ask the node to load the installed virtual machines, uninstalling new ones that fail to load")
+
+	return func(config *apm.Config) error {
+		config.Reload = reload
+		return nil
+	}
+}
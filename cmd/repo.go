@@ -0,0 +1,76 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/lint"
+	"github.com/ava-labs/apm/url"
+)
+
+func repo(fs afero.Fs) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "repo",
+		Short: "Tools for plugin repository maintainers",
+	}
+
+	command.AddCommand(repoLint(fs))
+	return command
+}
+
+func repoLint(fs afero.Fs) *cobra.Command {
+	verifyChecksums := false
+	command := &cobra.Command{
+		Use:   "lint <path>",
+		Short: "Checks the virtual machine and subnet definitions in a plugin repository for mistakes",
+		Args:  cobra.ExactArgs(1),
+	}
+	command.PersistentFlags().BoolVar(&verifyChecksums, "verify-checksums", false, "download every virtual machine's artifact and check it against its sha256")
+
+	command.RunE = func(_ *cobra.Command, args []string) error {
+		tmpPath, err := afero.TempDir(fs, "", "apm-lint")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = fs.RemoveAll(tmpPath)
+		}()
+
+		linter := lint.New(lint.Config{
+			Fs:              fs,
+			Path:            args[0],
+			VerifyChecksums: verifyChecksums,
+			URLClient:       url.NewClient(),
+			TmpPath:         tmpPath,
+		})
+
+		problems, err := linter.Lint()
+		if err != nil {
+			return err
+		}
+
+		errs := 0
+		for _, problem := range problems {
+			if problem.Warning {
+				fmt.Fprintf(os.Stderr, "Warning - %s\n", problem)
+				continue
+			}
+			fmt.Fprintln(os.Stderr, problem)
+			errs++
+		}
+
+		if errs == 0 {
+			fmt.Printf("No problems found in %s.\n", args[0])
+			return nil
+		}
+		return fmt.Errorf("found %d problems in %s", errs, args[0])
+	}
+
+	return command
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ava-labs/apm/apm"
+	"github.com/ava-labs/apm/node"
+)
+
+var errNoRestarter = fmt.Errorf("--restart requires one of --%s, --%s or --%s", restartSystemdUnitKey, restartContainerKey, restartCommandKey)
+
+// addRestartFlag adds the --restart flag to command. The returned option
+// applies it to the apm's config.
+func addRestartFlag(command *cobra.Command) func(*apm.Config) error {
+	restart := false
+	command.PersistentFlags().BoolVar(&restart, "restart", false, "restart the node after replacing binaries, rolling them back if the node doesn't become healthy")
+
+	return func(config *apm.Config) error {
+		if !restart {
+			return nil
+		}
+
+		restarter, err := initRestarter()
+		if err != nil {
+			return err
+		}
+
+		config.Restarter = restarter
+		config.HealthTimeout = viper.GetDuration(healthTimeoutKey)
+		return nil
+	}
+}
+
+// initRestarter returns the configured way to restart the node.
+func initRestarter() (node.Restarter, error) {
+	restarters := make([]node.Restarter, 0, 1)
+	if unit := viper.GetString(restartSystemdUnitKey); unit != "" {
+		restarters = append(restarters, node.NewSystemdRestarter(unit))
+	}
+	if container := viper.GetString(restartContainerKey); container != "" {
+		restarters = append(restarters, node.NewDockerRestarter(container))
+	}
+	if command := viper.GetString(restartCommandKey); command != "" {
+		restarters = append(restarters, node.NewCommandRestarter(command))
+	}
+
+	switch len(restarters) {
+	case 0:
+		return nil, errNoRestarter
+	case 1:
+		return restarters[0], nil
+	default:
+		return nil, errors.New("only one way to restart the node can be configured")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/ava-labs/avalanchego/utils/wrappers"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
@@ -15,9 +16,13 @@ import (
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 
+	"github.com/ava-labs/apm/admin"
 	"github.com/ava-labs/apm/apm"
+	"github.com/ava-labs/apm/audit"
 	"github.com/ava-labs/apm/config"
 	"github.com/ava-labs/apm/constant"
+	"github.com/ava-labs/apm/hooks"
+	"github.com/ava-labs/apm/metrics"
 )
 
 var (
@@ -27,14 +32,67 @@ var (
 )
 
 const (
-	configFileKey       = "config-file"
-	apmPathKey          = "apm-path"
-	pluginPathKey       = "plugin-path"
-	credentialsFileKey  = "credentials-file"
-	adminAPIEndpointKey = "admin-api-endpoint"
+	configFileKey          = "config-file"
+	apmPathKey             = "apm-path"
+	pluginPathKey          = "plugin-path"
+	credentialsFileKey     = "credentials-file"
+	adminAPIEndpointKey    = "admin-api-endpoint"
+	adminAPICAFileKey      = "admin-api-ca-file"
+	adminAPITokenKey       = "admin-api-token"
+	adminAPIUsernameKey    = "admin-api-username"
+	adminAPIPasswordKey    = "admin-api-password"
+	adminAPITimeoutKey     = "admin-api-timeout"
+	adminAPIRetriesKey     = "admin-api-retries"
+	avalancheGoVersionKey  = "avalanchego-version"
+	rpcChainVMProtocolKey  = "rpc-chain-vm-protocol"
+	ignoreCompatibilityKey = "ignore-compatibility"
+	nodeConfigFileKey      = "node-config-file"
+	nodeConfigDirKey       = "node-config-dir"
+	networkKey             = "network"
+	profileKey             = "profile"
+	profilesKey            = "profiles"
+	restartSystemdUnitKey  = "restart-systemd-unit"
+	restartContainerKey    = "restart-docker-container"
+	restartCommandKey      = "restart-command"
+	healthTimeoutKey       = "health-timeout"
+	waitKey                = "wait"
+	lockTimeoutKey         = "lock-timeout"
+	metricsTextfileKey     = "metrics-textfile"
+	hooksKey               = "hooks"
 )
 
+// profileKeys are the settings that can be overridden by a profile.
+var profileKeys = []string{
+	pluginPathKey,
+	adminAPIEndpointKey,
+	adminAPICAFileKey,
+	adminAPITokenKey,
+	adminAPIUsernameKey,
+	adminAPIPasswordKey,
+	adminAPITimeoutKey,
+	adminAPIRetriesKey,
+	networkKey,
+	nodeConfigFileKey,
+	nodeConfigDirKey,
+	avalancheGoVersionKey,
+	rpcChainVMProtocolKey,
+	restartSystemdUnitKey,
+	restartContainerKey,
+	restartCommandKey,
+	healthTimeoutKey,
+	metricsTextfileKey,
+	hooksKey,
+}
+
+// apmMetrics are shared by every apm created while running a command.
+var apmMetrics *metrics.Metrics
+
 func New(fs afero.Fs) (*cobra.Command, error) {
+	var err error
+	if apmMetrics, err = metrics.New(); err != nil {
+		return nil, err
+	}
+
 	rootCmd := &cobra.Command{
 		Use:   "apm",
 		Short: "apm is a plugin manager to help manage virtual machines and subnets",
@@ -42,7 +100,7 @@ func New(fs afero.Fs) (*cobra.Command, error) {
 			// we need to initialize our config here before each command starts,
 			// since Cobra doesn't actually parse any of the flags until
 			// cobra.Execute() is called.
-			return initializeConfig()
+			return initializeConfig(cmd)
 		},
 	}
 
@@ -50,7 +108,27 @@ func New(fs afero.Fs) (*cobra.Command, error) {
 	rootCmd.PersistentFlags().String(apmPathKey, apmDir, "path to the directory apm creates its artifacts")
 	rootCmd.PersistentFlags().String(pluginPathKey, filepath.Join(goPath, "src", "github.com", "ava-labs", "avalanchego", "build", "plugins"), "path to avalanche plugin directory")
 	rootCmd.PersistentFlags().String(credentialsFileKey, "", "path to credentials file")
-	rootCmd.PersistentFlags().String(adminAPIEndpointKey, "127.0.0.1:9650/ext/admin", "endpoint for the avalanche admin api")
+	rootCmd.PersistentFlags().String(adminAPIEndpointKey, "127.0.0.1:9650/ext/admin", "endpoint for the avalanche admin api. Prefix it with https:// if the node serves its apis over tls")
+	rootCmd.PersistentFlags().String(adminAPICAFileKey, "", "path to a PEM encoded CA bundle to trust for https admin api endpoints")
+	rootCmd.PersistentFlags().String(adminAPITokenKey, "", "bearer token to authenticate with the admin api")
+	rootCmd.PersistentFlags().String(adminAPIUsernameKey, "", "username to authenticate with the admin api using basic auth")
+	rootCmd.PersistentFlags().String(adminAPIPasswordKey, "", "password to authenticate with the admin api using basic auth")
+	rootCmd.PersistentFlags().Duration(adminAPITimeoutKey, 30*time.Second, "how long each admin api request can take. 0 means no timeout")
+	rootCmd.PersistentFlags().Int(adminAPIRetriesKey, 2, "how many times to retry admin api requests that time out or fail with a server error")
+	rootCmd.PersistentFlags().String(avalancheGoVersionKey, "", "avalanchego version to check plugin compatibility against. If unset, the node is queried for its version")
+	rootCmd.PersistentFlags().Uint(rpcChainVMProtocolKey, 0, "rpcchainvm protocol version to check plugin compatibility against. If unset, the node is queried for its version")
+	rootCmd.PersistentFlags().Bool(ignoreCompatibilityKey, false, "warn instead of failing when a plugin is incompatible with avalanchego")
+	rootCmd.PersistentFlags().String(nodeConfigFileKey, "", "path to the avalanchego config file to manage tracked subnets in")
+	rootCmd.PersistentFlags().String(profileKey, "", "name of the node profile in the config file to use")
+	rootCmd.PersistentFlags().String(networkKey, constant.DefaultNetwork, "network the node is running on (mainnet, fuji, local or a network id)")
+	rootCmd.PersistentFlags().String(nodeConfigDirKey, filepath.Join(homeDir, ".avalanchego", "configs"), "path to the avalanchego configs directory to write subnet and chain configs to")
+	rootCmd.PersistentFlags().String(restartSystemdUnitKey, "", "systemd unit to restart the node with (e.g avalanchego)")
+	rootCmd.PersistentFlags().String(restartContainerKey, "", "docker container to restart the node with")
+	rootCmd.PersistentFlags().String(restartCommandKey, "", "shell command to restart the node with")
+	rootCmd.PersistentFlags().Duration(healthTimeoutKey, 5*time.Minute, "how long to wait for the node to become healthy after restarting it")
+	rootCmd.PersistentFlags().Bool(waitKey, false, "wait for other apm processes to finish instead of failing")
+	rootCmd.PersistentFlags().Duration(lockTimeoutKey, 0, "longest to wait for other apm processes to finish (e.g 10m). Implies --wait. Defaults to waiting forever")
+	rootCmd.PersistentFlags().String(metricsTextfileKey, "", "path to write prometheus metrics to after each command, for the node exporter's textfile collector (e.g /var/lib/node_exporter/apm.prom)")
 
 	errs := wrappers.Errs{}
 	errs.Add(
@@ -59,6 +137,26 @@ func New(fs afero.Fs) (*cobra.Command, error) {
 		viper.BindPFlag(pluginPathKey, rootCmd.PersistentFlags().Lookup(pluginPathKey)),
 		viper.BindPFlag(credentialsFileKey, rootCmd.PersistentFlags().Lookup(credentialsFileKey)),
 		viper.BindPFlag(adminAPIEndpointKey, rootCmd.PersistentFlags().Lookup(adminAPIEndpointKey)),
+		viper.BindPFlag(adminAPICAFileKey, rootCmd.PersistentFlags().Lookup(adminAPICAFileKey)),
+		viper.BindPFlag(adminAPITokenKey, rootCmd.PersistentFlags().Lookup(adminAPITokenKey)),
+		viper.BindPFlag(adminAPIUsernameKey, rootCmd.PersistentFlags().Lookup(adminAPIUsernameKey)),
+		viper.BindPFlag(adminAPIPasswordKey, rootCmd.PersistentFlags().Lookup(adminAPIPasswordKey)),
+		viper.BindPFlag(adminAPITimeoutKey, rootCmd.PersistentFlags().Lookup(adminAPITimeoutKey)),
+		viper.BindPFlag(adminAPIRetriesKey, rootCmd.PersistentFlags().Lookup(adminAPIRetriesKey)),
+		viper.BindPFlag(avalancheGoVersionKey, rootCmd.PersistentFlags().Lookup(avalancheGoVersionKey)),
+		viper.BindPFlag(rpcChainVMProtocolKey, rootCmd.PersistentFlags().Lookup(rpcChainVMProtocolKey)),
+		viper.BindPFlag(ignoreCompatibilityKey, rootCmd.PersistentFlags().Lookup(ignoreCompatibilityKey)),
+		viper.BindPFlag(nodeConfigFileKey, rootCmd.PersistentFlags().Lookup(nodeConfigFileKey)),
+		viper.BindPFlag(nodeConfigDirKey, rootCmd.PersistentFlags().Lookup(nodeConfigDirKey)),
+		viper.BindPFlag(networkKey, rootCmd.PersistentFlags().Lookup(networkKey)),
+		viper.BindPFlag(profileKey, rootCmd.PersistentFlags().Lookup(profileKey)),
+		viper.BindPFlag(restartSystemdUnitKey, rootCmd.PersistentFlags().Lookup(restartSystemdUnitKey)),
+		viper.BindPFlag(restartContainerKey, rootCmd.PersistentFlags().Lookup(restartContainerKey)),
+		viper.BindPFlag(restartCommandKey, rootCmd.PersistentFlags().Lookup(restartCommandKey)),
+		viper.BindPFlag(healthTimeoutKey, rootCmd.PersistentFlags().Lookup(healthTimeoutKey)),
+		viper.BindPFlag(waitKey, rootCmd.PersistentFlags().Lookup(waitKey)),
+		viper.BindPFlag(lockTimeoutKey, rootCmd.PersistentFlags().Lookup(lockTimeoutKey)),
+		viper.BindPFlag(metricsTextfileKey, rootCmd.PersistentFlags().Lookup(metricsTextfileKey)),
 	)
 	if errs.Errored() {
 		return nil, errs.Err
@@ -67,24 +165,82 @@ func New(fs afero.Fs) (*cobra.Command, error) {
 	rootCmd.AddCommand(
 		install(fs),
 		uninstall(fs),
+		autoremove(fs),
 		update(fs),
 		upgrade(fs),
 		listRepositories(fs),
+		listSubnets(fs),
 		joinSubnet(fs),
+		leaveSubnet(fs),
 		addRepository(fs),
 		removeRepository(fs),
+		sync(fs),
+		lock(fs),
+		verify(fs),
+		doctor(fs),
+		runDaemon(fs),
+		history(fs),
+		repo(fs),
 	)
 
+	// Metrics are written even if the command fails, since failures are what
+	// operators most want to know about.
+	for _, command := range rootCmd.Commands() {
+		if command.RunE != nil {
+			command.RunE = writeMetrics(fs, command.RunE)
+		}
+	}
+
 	return rootCmd, nil
 }
 
+// writeMetrics wraps runE to write the metrics textfile after it runs, if one
+// is configured.
+func writeMetrics(fs afero.Fs, runE func(*cobra.Command, []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		err := runE(cmd, args)
+
+		if path := os.ExpandEnv(viper.GetString(metricsTextfileKey)); path != "" {
+			if writeErr := apmMetrics.WriteTextfile(fs, path); writeErr != nil {
+				fmt.Printf("Failed to write metrics to %s: %s\n", path, writeErr)
+			}
+		}
+
+		return err
+	}
+}
+
 // initializes config from file, if available.
-func initializeConfig() error {
+func initializeConfig(cmd *cobra.Command) error {
 	if viper.IsSet(configFileKey) {
 		cfgFile := os.ExpandEnv(viper.GetString(configFileKey))
 		viper.SetConfigFile(cfgFile)
 
-		return viper.ReadInConfig()
+		if err := viper.ReadInConfig(); err != nil {
+			return err
+		}
+	}
+
+	return initializeProfile(cmd)
+}
+
+// initializeProfile applies the settings of the selected profile. Flags passed
+// on the command line take precedence over the profile.
+func initializeProfile(cmd *cobra.Command) error {
+	name := viper.GetString(profileKey)
+	if name == "" {
+		return nil
+	}
+
+	profile := viper.Sub(fmt.Sprintf("%s.%s", profilesKey, name))
+	if profile == nil {
+		return fmt.Errorf("profile %s is not defined in the config file", name)
+	}
+
+	for _, key := range profileKeys {
+		if profile.IsSet(key) && !cmd.Flags().Changed(key) {
+			viper.Set(key, profile.Get(key))
+		}
 	}
 
 	return nil
@@ -114,16 +270,62 @@ func initCredentials() (http.BasicAuth, error) {
 }
 
 func initAPM(fs afero.Fs) (*apm.APM, error) {
-	credentials, err := initCredentials()
+	config, err := apmConfig(fs)
 	if err != nil {
 		return nil, err
 	}
 
-	return apm.New(apm.Config{
-		Directory:        viper.GetString(apmPathKey),
-		Auth:             credentials,
-		AdminAPIEndpoint: viper.GetString(adminAPIEndpointKey),
-		PluginDir:        viper.GetString(pluginPathKey),
-		Fs:               fs,
-	})
+	return apm.New(config)
+}
+
+func apmConfig(fs afero.Fs) (apm.Config, error) {
+	credentials, err := initCredentials()
+	if err != nil {
+		return apm.Config{}, err
+	}
+
+	hookConfigs := make([]hooks.Config, 0)
+	if err := viper.UnmarshalKey(hooksKey, &hookConfigs); err != nil {
+		return apm.Config{}, fmt.Errorf("failed to parse %s: %w", hooksKey, err)
+	}
+	var apmHooks *hooks.Hooks
+	if len(hookConfigs) > 0 {
+		if apmHooks, err = hooks.New(hookConfigs); err != nil {
+			return apm.Config{}, err
+		}
+	}
+
+	return apm.Config{
+		Directory: viper.GetString(apmPathKey),
+		Auth:      credentials,
+		AdminAPI: admin.Config{
+			Endpoint:    viper.GetString(adminAPIEndpointKey),
+			CAFile:      os.ExpandEnv(viper.GetString(adminAPICAFileKey)),
+			BearerToken: viper.GetString(adminAPITokenKey),
+			Username:    viper.GetString(adminAPIUsernameKey),
+			Password:    viper.GetString(adminAPIPasswordKey),
+			Timeout:     viper.GetDuration(adminAPITimeoutKey),
+			Retries:     viper.GetInt(adminAPIRetriesKey),
+		},
+		PluginDir:           viper.GetString(pluginPathKey),
+		Fs:                  fs,
+		AvalancheGoVersion:  viper.GetString(avalancheGoVersionKey),
+		RPCChainVMProtocol:  viper.GetUint(rpcChainVMProtocolKey),
+		IgnoreCompatibility: viper.GetBool(ignoreCompatibilityKey),
+		NodeConfigFile:      os.ExpandEnv(viper.GetString(nodeConfigFileKey)),
+		NodeConfigDir:       os.ExpandEnv(viper.GetString(nodeConfigDirKey)),
+		Network:             viper.GetString(networkKey),
+		Profile:             viper.GetString(profileKey),
+		Wait:                viper.GetBool(waitKey),
+		LockTimeout:         viper.GetDuration(lockTimeoutKey),
+		Metrics:             apmMetrics,
+		Hooks:               apmHooks,
+		Command:             auditCommand(),
+	}, nil
+}
+
+// auditCommand returns the apm invocation recorded in the audit log, with
+// secrets redacted.
+func auditCommand() []string {
+	return append([]string{constant.AppName}, audit.Redact(os.Args[1:])...)
 }
@@ -0,0 +1,83 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		// flags are passed on the command line.
+		flags          map[string]string
+		wantErr        bool
+		wantNetwork    string
+		wantPluginPath string
+	}{
+		{
+			name:           "no profile",
+			wantNetwork:    "fuji",
+			wantPluginPath: "default",
+		},
+		{
+			name:    "undefined profile",
+			profile: "undefined",
+			wantErr: true,
+		},
+		{
+			name:           "profile overrides defaults",
+			profile:        "staging",
+			wantNetwork:    "mainnet",
+			wantPluginPath: "staging",
+		},
+		{
+			name:    "flags override the profile",
+			profile: "staging",
+			flags: map[string]string{
+				networkKey: "local",
+			},
+			wantNetwork:    "local",
+			wantPluginPath: "staging",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+
+			command := &cobra.Command{}
+			command.Flags().String(networkKey, "fuji", "")
+			command.Flags().String(pluginPathKey, "default", "")
+			require.NoError(t, viper.BindPFlags(command.Flags()))
+			for key, value := range test.flags {
+				require.NoError(t, command.Flags().Set(key, value))
+			}
+
+			viper.Set(profileKey, test.profile)
+			viper.Set(profilesKey, map[string]interface{}{
+				"staging": map[string]interface{}{
+					networkKey:    "mainnet",
+					pluginPathKey: "staging",
+				},
+			})
+
+			err := initializeProfile(command)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantNetwork, viper.GetString(networkKey))
+			assert.Equal(t, test.wantPluginPath, viper.GetString(pluginPathKey))
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/apm"
+	"github.com/ava-labs/apm/manifest"
+)
+
+func sync(fs afero.Fs) *cobra.Command {
+	path := ""
+	command := &cobra.Command{
+		Use:   "sync",
+		Short: "Installs, upgrades and removes plugins to match a manifest",
+	}
+	command.PersistentFlags().StringVar(&path, "manifest", manifest.DefaultPath, "path to the manifest to sync")
+	reload := addReloadFlag(command)
+
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		// Check the manifest before we touch anything.
+		m, err := manifest.Load(fs, path)
+		if err != nil {
+			return err
+		}
+
+		config, err := apmConfig(fs)
+		if err != nil {
+			return err
+		}
+		if err := reload(&config); err != nil {
+			return err
+		}
+
+		a, err := apm.New(config)
+		if err != nil {
+			return err
+		}
+
+		return a.Sync(m)
+	}
+
+	return command
+}
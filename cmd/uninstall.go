@@ -6,6 +6,8 @@ package cmd
 import (
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/apm"
 )
 
 func uninstall(fs afero.Fs) *cobra.Command {
@@ -20,13 +22,12 @@ func uninstall(fs afero.Fs) *cobra.Command {
 		panic(err)
 	}
 
-	command.RunE = func(_ *cobra.Command, _ []string) error {
-		apm, err := initAPM(fs)
-		if err != nil {
-			return err
-		}
+	dryRun := addDryRunFlags(command)
 
-		return apm.Uninstall(vm)
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		return dryRun.run(fs, func(a *apm.APM) error {
+			return a.Uninstall(vm)
+		})
 	}
 
 	return command
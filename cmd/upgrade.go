@@ -6,6 +6,8 @@ package cmd
 import (
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+
+	"github.com/ava-labs/apm/apm"
 )
 
 func upgrade(fs afero.Fs) *cobra.Command {
@@ -17,13 +19,19 @@ func upgrade(fs afero.Fs) *cobra.Command {
 			"installed virtual machines are upgraded.",
 	}
 	command.PersistentFlags().StringVar(&vm, "vm", "", "vm alias to install")
-	command.RunE = func(_ *cobra.Command, _ []string) error {
-		apm, err := initAPM(fs)
-		if err != nil {
-			return err
-		}
+	reload := addReloadFlag(command)
+	restart := addRestartFlag(command)
+	dryRun := addDryRunFlags(command)
 
-		return apm.Upgrade(vm)
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		return dryRun.run(
+			fs,
+			func(a *apm.APM) error {
+				return a.Upgrade(vm)
+			},
+			reload,
+			restart,
+		)
 	}
 
 	return command
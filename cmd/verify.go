@@ -0,0 +1,31 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package cmd
+
+import (
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+func verify(fs afero.Fs) *cobra.Command {
+	vm := ""
+	repair := false
+	command := &cobra.Command{
+		Use:   "verify",
+		Short: "Checks installed virtual machines against the checksums recorded when they were installed",
+	}
+	command.PersistentFlags().StringVar(&vm, "vm", "", "vm alias to verify. If unset, every installed vm is verified")
+	command.PersistentFlags().BoolVar(&repair, "repair", false, "reinstall virtual machines that fail verification")
+
+	command.RunE = func(_ *cobra.Command, _ []string) error {
+		apm, err := initAPM(fs)
+		if err != nil {
+			return err
+		}
+
+		return apm.Verify(vm, repair)
+	}
+
+	return command
+}
@@ -10,5 +10,12 @@ const (
 	CoreBranch             = "master"
 	QualifiedNameDelimiter = ":"
 	AliasDelimiter         = "/"
-	DefaultNetwork         = "testnet"
+	DefaultNetwork         = Fuji
+)
+
+// Networks that subnets can be joined on.
+const (
+	Mainnet = "mainnet"
+	Fuji    = "fuji"
+	Local   = "local"
 )
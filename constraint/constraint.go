@@ -0,0 +1,118 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package constraint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/version"
+)
+
+const clauseDelimiter = ","
+
+// operators are ordered so that two-character operators are matched before
+// their one-character prefixes.
+var operators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// Constraint is a set of version clauses (e.g ">=v1.7.14, <v1.8.0") that must
+// all be satisfied by a version.
+// The zero value places no restrictions on a version.
+type Constraint struct {
+	clauses []clause
+}
+
+type clause struct {
+	operator string
+	version  *version.Semantic
+}
+
+// Parse parses a comma-separated list of version clauses. Versions may be
+// specified with or without a leading "v". A clause without an operator
+// requires an exact match.
+func Parse(s string) (Constraint, error) {
+	result := Constraint{}
+	if strings.TrimSpace(s) == "" {
+		return result, nil
+	}
+
+	for _, raw := range strings.Split(s, clauseDelimiter) {
+		raw = strings.TrimSpace(raw)
+
+		operator := "="
+		for _, op := range operators {
+			if strings.HasPrefix(raw, op) {
+				operator = op
+				raw = strings.TrimSpace(strings.TrimPrefix(raw, op))
+				break
+			}
+		}
+
+		v, err := ParseVersion(raw)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %w", s, err)
+		}
+
+		result.clauses = append(result.clauses, clause{
+			operator: operator,
+			version:  v,
+		})
+	}
+
+	return result, nil
+}
+
+// ParseVersion parses a semantic version with an optional "v" or
+// "avalanche/" prefix.
+func ParseVersion(s string) (*version.Semantic, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "avalanche/")
+	if !strings.HasPrefix(s, "v") {
+		s = "v" + s
+	}
+
+	return version.Parse(s)
+}
+
+// Allows returns true if v satisfies every clause in the constraint.
+func (c Constraint) Allows(v *version.Semantic) bool {
+	for _, clause := range c.clauses {
+		cmp := v.Compare(clause.version)
+
+		var ok bool
+		switch clause.operator {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		default:
+			ok = cmp == 0
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsZero returns true if the constraint places no restrictions on a version.
+func (c Constraint) IsZero() bool {
+	return len(c.clauses) == 0
+}
+
+func (c Constraint) String() string {
+	clauses := make([]string, 0, len(c.clauses))
+	for _, clause := range c.clauses {
+		clauses = append(clauses, fmt.Sprintf("%s%s", clause.operator, clause.version))
+	}
+
+	return strings.Join(clauses, clauseDelimiter+" ")
+}
@@ -0,0 +1,292 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/apm/apm"
+	"github.com/ava-labs/apm/audit"
+)
+
+const (
+	defaultHistoryLimit = 100
+
+	// upgradeWorkflow is the workflow the daemon's skipped upgrades are
+	// recorded as, like the upgrades the apm runs.
+	upgradeWorkflow = "upgrade-vm"
+	// daemonWorkflow is what the daemon's own failures are recorded as.
+	daemonWorkflow = "daemon"
+)
+
+var _ APM = &apm.APM{}
+
+// APM is the subset of the apm the daemon uses.
+type APM interface {
+	Update() error
+	AvailableUpgrades() ([]apm.AvailableUpgrade, error)
+	UpgradeVMs(names []string) (map[string]error, error)
+}
+
+type Config struct {
+	Policy   Policy
+	Interval time.Duration
+	// NewAPM returns the apm to use for a run. A new one is used for each run
+	// so changes made by other apm processes are picked up.
+	NewAPM func() (APM, error)
+	// AuditLog is where the daemon records its decisions, next to the
+	// workflows the apm runs.
+	AuditLog *audit.Log
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+func New(config Config) *Daemon {
+	now := config.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Daemon{
+		policy:   config.Policy,
+		interval: config.Interval,
+		newAPM:   config.NewAPM,
+		auditLog: config.AuditLog,
+		now:      now,
+		notified: make(map[apm.AvailableUpgrade]bool),
+		status: Status{
+			Mode:    config.Policy.Mode,
+			Pending: make([]apm.AvailableUpgrade, 0),
+		},
+	}
+}
+
+// Daemon periodically syncs repositories and upgrades virtual machines
+// according to a policy.
+type Daemon struct {
+	policy   Policy
+	interval time.Duration
+	newAPM   func() (APM, error)
+	auditLog *audit.Log
+	now      func() time.Time
+
+	// notified are the available upgrades already recorded in the audit log,
+	// so they're only recorded once.
+	notified map[apm.AvailableUpgrade]bool
+
+	lock   sync.Mutex
+	status Status
+}
+
+// Status is a snapshot of what the daemon is doing.
+type Status struct {
+	Mode    Mode      `json:"mode"`
+	Running bool      `json:"running"`
+	LastRun time.Time `json:"lastRun"`
+	NextRun time.Time `json:"nextRun"`
+	// LastError is the last error of the last run, if any.
+	LastError string `json:"lastError,omitempty"`
+	// Pending are the upgrades that weren't applied in the last run.
+	Pending []apm.AvailableUpgrade `json:"pending"`
+}
+
+// Run runs the daemon every interval until ctx is done.
+func (d *Daemon) Run(ctx context.Context) {
+	for {
+		d.RunOnce()
+
+		next := d.now().Add(d.interval)
+		d.lock.Lock()
+		d.status.NextRun = next
+		d.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.interval):
+		}
+	}
+}
+
+// RunOnce syncs repositories and handles the available upgrades.
+func (d *Daemon) RunOnce() {
+	d.lock.Lock()
+	d.status.Running = true
+	d.lock.Unlock()
+
+	pending, err := d.run()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.status.Running = false
+	d.status.LastRun = d.now()
+	d.status.LastError = ""
+	if err != nil {
+		d.status.LastError = err.Error()
+	}
+	if pending != nil {
+		d.status.Pending = pending
+	}
+}
+
+// run returns the upgrades that weren't applied, or nil if it failed before
+// finding them. Failed workflows are recorded in the audit log by the apm.
+func (d *Daemon) run() ([]apm.AvailableUpgrade, error) {
+	upgrades, a, err := d.findUpgrades()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]apm.AvailableUpgrade, 0)
+	allowed := make([]apm.AvailableUpgrade, 0)
+	for _, upgrade := range upgrades {
+		switch {
+		case !d.policy.Allows(upgrade.Name):
+			d.skip(upgrade, "not in the allowlist")
+		case d.policy.Mode == NotifyOnly:
+			d.skip(upgrade, fmt.Sprintf("upgrades aren't applied in %s mode", NotifyOnly))
+			pending = append(pending, upgrade)
+		case !d.policy.InWindow(d.now()):
+			d.skip(upgrade, "waiting for a maintenance window")
+			pending = append(pending, upgrade)
+		default:
+			allowed = append(allowed, upgrade)
+		}
+	}
+	if len(allowed) == 0 {
+		return pending, nil
+	}
+
+	// Upgrade everything at once, so the node only restarts once.
+	names := make([]string, 0, len(allowed))
+	for _, upgrade := range allowed {
+		names = append(names, upgrade.Name)
+	}
+	failed, lastErr := a.UpgradeVMs(names)
+	if lastErr != nil {
+		lastErr = fmt.Errorf("failed to upgrade: %w", lastErr)
+	}
+
+	for _, upgrade := range allowed {
+		if err, ok := failed[upgrade.Name]; ok {
+			lastErr = fmt.Errorf("failed to upgrade %s: %w", upgrade.Name, err)
+			pending = append(pending, upgrade)
+		}
+	}
+
+	return pending, lastErr
+}
+
+// findUpgrades updates the repositories and returns the available upgrades and
+// the apm to apply them with. Failures outside of the apm's workflows are
+// recorded in the audit log.
+func (d *Daemon) findUpgrades() ([]apm.AvailableUpgrade, APM, error) {
+	a, err := d.newAPM()
+	if err != nil {
+		d.fail(err)
+		return nil, nil, err
+	}
+
+	if err := a.Update(); err != nil {
+		return nil, nil, fmt.Errorf("failed to update repositories: %w", err)
+	}
+
+	upgrades, err := a.AvailableUpgrades()
+	if err != nil {
+		d.fail(err)
+		return nil, nil, err
+	}
+
+	return upgrades, a, nil
+}
+
+// skip records an upgrade the daemon didn't apply, the first time it's seen.
+func (d *Daemon) skip(upgrade apm.AvailableUpgrade, reason string) {
+	if d.notified[upgrade] {
+		return
+	}
+	d.notified[upgrade] = true
+
+	d.record(audit.Entry{
+		Workflow: upgradeWorkflow,
+		Name:     upgrade.Name,
+		Outcome:  audit.Skipped,
+		Commit:   upgrade.Installed,
+		Reason:   fmt.Sprintf("%s (upgrade to %s)", reason, upgrade.Latest),
+	})
+}
+
+// fail records an error that kept the daemon from running.
+func (d *Daemon) fail(err error) {
+	d.record(audit.Entry{
+		Workflow: daemonWorkflow,
+		Outcome:  audit.Failed,
+		Error:    err.Error(),
+	})
+}
+
+func (d *Daemon) record(entry audit.Entry) {
+	fields := []string{d.now().Format(time.RFC3339), entry.Workflow, string(entry.Outcome)}
+	for _, field := range []string{entry.Name, entry.Reason, entry.Error} {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	fmt.Println(strings.Join(fields, " "))
+
+	if err := d.auditLog.Record(entry); err != nil {
+		fmt.Printf("Warning - failed to write to the audit log: %s\n", err)
+	}
+}
+
+// Status returns what the daemon is doing.
+func (d *Daemon) Status() Status {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	status := d.status
+	status.Pending = append([]apm.AvailableUpgrade{}, d.status.Pending...)
+	return status
+}
+
+// Handler serves the daemon's status at /status and the audit log's most
+// recent entries at /history. The number of entries can be set with ?limit=n.
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, d.Status())
+	})
+	mux.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultHistoryLimit
+		if param := r.URL.Query().Get("limit"); param != "" {
+			var err error
+			if limit, err = strconv.Atoi(param); err != nil || limit < 0 {
+				http.Error(w, fmt.Sprintf("invalid limit %s", param), http.StatusBadRequest)
+				return
+			}
+		}
+
+		entries, err := d.auditLog.Read(audit.Filter{Limit: limit})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, entries)
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
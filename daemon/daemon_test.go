@@ -0,0 +1,259 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ava-labs/apm/apm"
+	"github.com/ava-labs/apm/audit"
+)
+
+var (
+	errWrong = errors.New("something went wrong")
+
+	// now is a Saturday at noon.
+	now = time.Date(2022, 7, 2, 12, 0, 0, 0, time.UTC)
+
+	upgrade1 = apm.AvailableUpgrade{Name: "organization/repository:vm1", Installed: "a", Latest: "b"}
+	upgrade2 = apm.AvailableUpgrade{Name: "organization/repository:vm2", Installed: "c", Latest: "d"}
+)
+
+func newAuditLog() *audit.Log {
+	return audit.NewLog(audit.LogConfig{Fs: afero.NewMemMapFs(), Path: audit.File})
+}
+
+func TestDaemonRunOnce(t *testing.T) {
+	type mocks struct {
+		apm *MockAPM
+	}
+	tests := []struct {
+		name         string
+		policy       Policy
+		setup        func(mocks)
+		wantOutcomes []audit.Outcome
+		wantPending  []apm.AvailableUpgrade
+		wantErr      bool
+	}{
+		{
+			name:   "update fails",
+			policy: Policy{Mode: AutoUpgrade},
+			setup: func(mocks mocks) {
+				mocks.apm.EXPECT().Update().Return(errWrong)
+			},
+			wantPending: []apm.AvailableUpgrade{},
+			wantErr:     true,
+		},
+		{
+			name:   "finding upgrades fails",
+			policy: Policy{Mode: AutoUpgrade},
+			setup: func(mocks mocks) {
+				mocks.apm.EXPECT().Update().Return(nil)
+				mocks.apm.EXPECT().AvailableUpgrades().Return(nil, errWrong)
+			},
+			wantOutcomes: []audit.Outcome{audit.Failed},
+			wantPending:  []apm.AvailableUpgrade{},
+			wantErr:      true,
+		},
+		{
+			name:   "auto upgrade",
+			policy: Policy{Mode: AutoUpgrade},
+			setup: func(mocks mocks) {
+				mocks.apm.EXPECT().Update().Return(nil)
+				mocks.apm.EXPECT().AvailableUpgrades().Return([]apm.AvailableUpgrade{upgrade1, upgrade2}, nil)
+				mocks.apm.EXPECT().UpgradeVMs([]string{upgrade1.Name, upgrade2.Name}).Return(map[string]error{}, nil)
+			},
+			wantPending: []apm.AvailableUpgrade{},
+		},
+		{
+			name:   "upgrade fails",
+			policy: Policy{Mode: AutoUpgrade},
+			setup: func(mocks mocks) {
+				mocks.apm.EXPECT().Update().Return(nil)
+				mocks.apm.EXPECT().AvailableUpgrades().Return([]apm.AvailableUpgrade{upgrade1, upgrade2}, nil)
+				mocks.apm.EXPECT().UpgradeVMs([]string{upgrade1.Name, upgrade2.Name}).Return(map[string]error{upgrade1.Name: errWrong}, nil)
+			},
+			wantPending: []apm.AvailableUpgrade{upgrade1},
+			wantErr:     true,
+		},
+		{
+			name:   "restart rolls back",
+			policy: Policy{Mode: AutoUpgrade},
+			setup: func(mocks mocks) {
+				mocks.apm.EXPECT().Update().Return(nil)
+				mocks.apm.EXPECT().AvailableUpgrades().Return([]apm.AvailableUpgrade{upgrade1, upgrade2}, nil)
+				mocks.apm.EXPECT().UpgradeVMs([]string{upgrade1.Name, upgrade2.Name}).Return(map[string]error{upgrade1.Name: errWrong, upgrade2.Name: errWrong}, errWrong)
+			},
+			wantPending: []apm.AvailableUpgrade{upgrade1, upgrade2},
+			wantErr:     true,
+		},
+		{
+			name:   "notify only",
+			policy: Policy{Mode: NotifyOnly},
+			setup: func(mocks mocks) {
+				mocks.apm.EXPECT().Update().Return(nil)
+				mocks.apm.EXPECT().AvailableUpgrades().Return([]apm.AvailableUpgrade{upgrade1}, nil)
+			},
+			wantOutcomes: []audit.Outcome{audit.Skipped},
+			wantPending:  []apm.AvailableUpgrade{upgrade1},
+		},
+		{
+			name: "outside maintenance window",
+			policy: Policy{
+				Mode:    AutoUpgrade,
+				Windows: []Window{{Start: 120, End: 240}},
+			},
+			setup: func(mocks mocks) {
+				mocks.apm.EXPECT().Update().Return(nil)
+				mocks.apm.EXPECT().AvailableUpgrades().Return([]apm.AvailableUpgrade{upgrade1}, nil)
+			},
+			wantOutcomes: []audit.Outcome{audit.Skipped},
+			wantPending:  []apm.AvailableUpgrade{upgrade1},
+		},
+		{
+			name: "inside maintenance window",
+			policy: Policy{
+				Mode:    AutoUpgrade,
+				Windows: []Window{{Days: []time.Weekday{time.Saturday}, Start: 660, End: 780}},
+			},
+			setup: func(mocks mocks) {
+				mocks.apm.EXPECT().Update().Return(nil)
+				mocks.apm.EXPECT().AvailableUpgrades().Return([]apm.AvailableUpgrade{upgrade1}, nil)
+				mocks.apm.EXPECT().UpgradeVMs([]string{upgrade1.Name}).Return(map[string]error{}, nil)
+			},
+			wantPending: []apm.AvailableUpgrade{},
+		},
+		{
+			name: "not allowed",
+			policy: Policy{
+				Mode:  AutoUpgrade,
+				Allow: []string{"vm2"},
+			},
+			setup: func(mocks mocks) {
+				mocks.apm.EXPECT().Update().Return(nil)
+				mocks.apm.EXPECT().AvailableUpgrades().Return([]apm.AvailableUpgrade{upgrade1, upgrade2}, nil)
+				mocks.apm.EXPECT().UpgradeVMs([]string{upgrade2.Name}).Return(map[string]error{}, nil)
+			},
+			wantOutcomes: []audit.Outcome{audit.Skipped},
+			wantPending:  []apm.AvailableUpgrade{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockAPM := NewMockAPM(ctrl)
+			test.setup(mocks{apm: mockAPM})
+
+			auditLog := newAuditLog()
+			d := New(Config{
+				Policy: test.policy,
+				NewAPM: func() (APM, error) {
+					return mockAPM, nil
+				},
+				AuditLog: auditLog,
+				Now: func() time.Time {
+					return now
+				},
+			})
+
+			d.RunOnce()
+
+			entries, err := auditLog.Read(audit.Filter{})
+			assert.NoError(t, err)
+			outcomes := make([]audit.Outcome, 0, len(entries))
+			for _, entry := range entries {
+				outcomes = append(outcomes, entry.Outcome)
+			}
+			if test.wantOutcomes == nil {
+				test.wantOutcomes = []audit.Outcome{}
+			}
+			assert.Equal(t, test.wantOutcomes, outcomes)
+
+			status := d.Status()
+			assert.Equal(t, test.policy.Mode, status.Mode)
+			assert.False(t, status.Running)
+			assert.Equal(t, now, status.LastRun)
+			assert.Equal(t, test.wantPending, status.Pending)
+			assert.Equal(t, test.wantErr, status.LastError != "")
+		})
+	}
+}
+
+// Tests that available upgrades are only recorded the first time they're seen.
+func TestDaemonNotifiesOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAPM := NewMockAPM(ctrl)
+	mockAPM.EXPECT().Update().Return(nil).Times(2)
+	mockAPM.EXPECT().AvailableUpgrades().Return([]apm.AvailableUpgrade{upgrade1}, nil)
+	mockAPM.EXPECT().AvailableUpgrades().Return([]apm.AvailableUpgrade{upgrade1, upgrade2}, nil)
+
+	auditLog := newAuditLog()
+	d := New(Config{
+		Policy: Policy{Mode: NotifyOnly},
+		NewAPM: func() (APM, error) {
+			return mockAPM, nil
+		},
+		AuditLog: auditLog,
+	})
+
+	d.RunOnce()
+	d.RunOnce()
+
+	entries, err := auditLog.Read(audit.Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, upgrade1.Name, entries[0].Name)
+	assert.Equal(t, upgrade1.Installed, entries[0].Commit)
+	assert.Equal(t, "upgrades aren't applied in notify-only mode (upgrade to b)", entries[0].Reason)
+	assert.Equal(t, upgrade2.Name, entries[1].Name)
+	assert.Equal(t, []apm.AvailableUpgrade{upgrade1, upgrade2}, d.Status().Pending)
+}
+
+func TestDaemonHandler(t *testing.T) {
+	auditLog := newAuditLog()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, auditLog.Record(audit.Entry{Workflow: "update", Outcome: audit.Succeeded}))
+	}
+	assert.NoError(t, auditLog.Record(audit.Entry{Workflow: "upgrade-vm", Name: upgrade1.Name, Outcome: audit.Succeeded}))
+
+	d := New(Config{
+		Policy:   Policy{Mode: NotifyOnly},
+		AuditLog: auditLog,
+	})
+	server := httptest.NewServer(d.Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/status")
+	assert.NoError(t, err)
+	status := Status{}
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&status))
+	assert.NoError(t, response.Body.Close())
+	assert.Equal(t, NotifyOnly, status.Mode)
+
+	response, err = http.Get(server.URL + "/history?limit=2")
+	assert.NoError(t, err)
+	entries := make([]audit.Entry, 0)
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&entries))
+	assert.NoError(t, response.Body.Close())
+	assert.Len(t, entries, 2)
+	assert.Equal(t, upgrade1.Name, entries[1].Name)
+
+	response, err = http.Get(server.URL + "/history?limit=some")
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
@@ -0,0 +1,207 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/apm/constant"
+)
+
+// Mode is what the daemon does when an upgrade is available.
+type Mode string
+
+const (
+	// AutoUpgrade upgrades virtual machines during maintenance windows.
+	AutoUpgrade Mode = "auto-upgrade"
+	// NotifyOnly records available upgrades without applying them.
+	NotifyOnly Mode = "notify-only"
+
+	clockFormat = "15:04"
+)
+
+var (
+	errInvalidWindow = errors.New("invalid maintenance window")
+
+	days = map[string]time.Weekday{
+		"sun": time.Sunday,
+		"mon": time.Monday,
+		"tue": time.Tuesday,
+		"wed": time.Wednesday,
+		"thu": time.Thursday,
+		"fri": time.Friday,
+		"sat": time.Saturday,
+	}
+)
+
+// ParseMode parses a mode.
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case AutoUpgrade, NotifyOnly:
+		return Mode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown mode %s (must be %s or %s)", mode, AutoUpgrade, NotifyOnly)
+	}
+}
+
+// Policy decides which upgrades the daemon applies.
+type Policy struct {
+	Mode Mode
+	// Windows are when upgrades can be applied. If empty, upgrades can be
+	// applied at any time.
+	Windows []Window
+	// Allow are the virtual machines that can be upgraded, either by their
+	// fully qualified name or their alias. If empty, every virtual machine can
+	// be upgraded.
+	Allow []string
+}
+
+// Allows returns whether the virtual machine with the fully qualified name can
+// be upgraded.
+func (p Policy) Allows(name string) bool {
+	if len(p.Allow) == 0 {
+		return true
+	}
+
+	for _, allowed := range p.Allow {
+		if matches(allowed, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unmatched returns the allowlist entries that don't match any of the
+// installed virtual machines, by their fully qualified names.
+func (p Policy) Unmatched(installed []string) []string {
+	result := make([]string, 0)
+	for _, allowed := range p.Allow {
+		matched := false
+		for _, name := range installed {
+			if matches(allowed, name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			result = append(result, allowed)
+		}
+	}
+
+	return result
+}
+
+// matches returns whether an allowlist entry matches the virtual machine with
+// the fully qualified name.
+func matches(allowed string, name string) bool {
+	_, alias, _ := strings.Cut(name, constant.QualifiedNameDelimiter)
+	return allowed == name || allowed == alias
+}
+
+// InWindow returns whether t is in a maintenance window.
+func (p Policy) InWindow(t time.Time) bool {
+	if len(p.Windows) == 0 {
+		return true
+	}
+
+	for _, window := range p.Windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Window is a recurring period of time, in minutes since midnight. Windows
+// that end before they start span midnight.
+type Window struct {
+	// Days are the days the window starts on. If empty, the window starts
+	// every day.
+	Days  []time.Weekday
+	Start int
+	End   int
+}
+
+// ParseWindow parses a window like "02:00-04:00" or "sat,sun 22:00-02:00".
+func ParseWindow(window string) (Window, error) {
+	result := Window{}
+
+	fields := strings.Fields(window)
+	switch len(fields) {
+	case 1:
+	case 2:
+		for _, day := range strings.Split(fields[0], ",") {
+			weekday, ok := days[strings.ToLower(day)]
+			if !ok {
+				return Window{}, fmt.Errorf("%w %s: unknown day %s", errInvalidWindow, window, day)
+			}
+			result.Days = append(result.Days, weekday)
+		}
+	default:
+		return Window{}, fmt.Errorf("%w %s: must be in the form of [days] hh:mm-hh:mm", errInvalidWindow, window)
+	}
+
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return Window{}, fmt.Errorf("%w %s: must be in the form of [days] hh:mm-hh:mm", errInvalidWindow, window)
+	}
+
+	var err error
+	if result.Start, err = minutes(start); err != nil {
+		return Window{}, fmt.Errorf("%w %s: %s", errInvalidWindow, window, err)
+	}
+	if result.End, err = minutes(end); err != nil {
+		return Window{}, fmt.Errorf("%w %s: %s", errInvalidWindow, window, err)
+	}
+	if result.Start == result.End {
+		return Window{}, fmt.Errorf("%w %s: must not start and end at the same time", errInvalidWindow, window)
+	}
+
+	return result, nil
+}
+
+// Contains returns whether t is in the window.
+func (w Window) Contains(t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	if w.Start <= w.End {
+		return w.startsOn(day) && w.Start <= now && now < w.End
+	}
+
+	// The window spans midnight, so after midnight it's yesterday's window.
+	if now >= w.Start {
+		return w.startsOn(day)
+	}
+	return now < w.End && w.startsOn((day+6)%7)
+}
+
+func (w Window) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// minutes returns the minutes since midnight of a clock time like "14:30".
+func minutes(clock string) (int, error) {
+	t, err := time.Parse(clockFormat, clock)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  string
+		want    Window
+		wantErr error
+	}{
+		{
+			name:   "every day",
+			window: "02:00-04:30",
+			want:   Window{Start: 120, End: 270},
+		},
+		{
+			name:   "days",
+			window: "Sat,sun 22:00-02:00",
+			want: Window{
+				Days:  []time.Weekday{time.Saturday, time.Sunday},
+				Start: 1320,
+				End:   120,
+			},
+		},
+		{
+			name:    "unknown day",
+			window:  "someday 22:00-02:00",
+			wantErr: errInvalidWindow,
+		},
+		{
+			name:    "missing end",
+			window:  "22:00",
+			wantErr: errInvalidWindow,
+		},
+		{
+			name:    "invalid time",
+			window:  "22:00-25:00",
+			wantErr: errInvalidWindow,
+		},
+		{
+			name:    "zero length",
+			window:  "02:00-02:00",
+			wantErr: errInvalidWindow,
+		},
+		{
+			name:    "too many fields",
+			window:  "sat 22:00 -02:00",
+			wantErr: errInvalidWindow,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			window, err := ParseWindow(test.window)
+			assert.ErrorIs(t, err, test.wantErr)
+			if test.wantErr == nil {
+				assert.Equal(t, test.want, window)
+			}
+		})
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	// 2022-07-02 is a Saturday.
+	saturday := func(clock string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04", "2022-07-02 "+clock)
+		return t
+	}
+	sunday := func(clock string) time.Time {
+		return saturday(clock).AddDate(0, 0, 1)
+	}
+
+	window := Window{Start: 120, End: 240}
+	assert.True(t, window.Contains(saturday("02:00")))
+	assert.True(t, window.Contains(sunday("03:59")))
+	assert.False(t, window.Contains(saturday("04:00")))
+	assert.False(t, window.Contains(saturday("01:59")))
+
+	// Saturday night into Sunday morning.
+	window = Window{Days: []time.Weekday{time.Saturday}, Start: 1320, End: 120}
+	assert.True(t, window.Contains(saturday("23:00")))
+	assert.True(t, window.Contains(sunday("01:00")))
+	assert.False(t, window.Contains(sunday("23:00")))
+	assert.False(t, window.Contains(saturday("01:00")))
+	assert.False(t, window.Contains(saturday("21:59")))
+}
+
+func TestPolicy(t *testing.T) {
+	policy := Policy{}
+	assert.True(t, policy.Allows("organization/repository:vm"))
+	assert.True(t, policy.InWindow(time.Now()))
+
+	policy = Policy{
+		Allow:   []string{"vm", "organization/repository:other"},
+		Windows: []Window{{Start: 0, End: 60}, {Start: 120, End: 180}},
+	}
+	assert.True(t, policy.Allows("organization/repository:vm"))
+	assert.True(t, policy.Allows("organization/repository:other"))
+	assert.False(t, policy.Allows("organization/other:other"))
+
+	day := time.Date(2022, 7, 2, 0, 0, 0, 0, time.UTC)
+	assert.True(t, policy.InWindow(day.Add(30*time.Minute)))
+	assert.True(t, policy.InWindow(day.Add(150*time.Minute)))
+	assert.False(t, policy.InWindow(day.Add(90*time.Minute)))
+
+	assert.Equal(t, []string{"organization/repository:other"}, policy.Unmatched([]string{"organization/repository:vm", "organization/other:other"}))
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("auto-upgrade")
+	assert.NoError(t, err)
+	assert.Equal(t, AutoUpgrade, mode)
+
+	_, err = ParseMode("sometimes")
+	assert.Error(t, err)
+}
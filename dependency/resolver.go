@@ -0,0 +1,212 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package dependency
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+	"github.com/ava-labs/apm/util"
+	"github.com/ava-labs/apm/workflow"
+)
+
+var (
+	ErrCycle    = errors.New("dependency cycle detected")
+	ErrConflict = errors.New("conflicting virtual machines")
+)
+
+// Step is a single virtual machine in an install plan.
+type Step struct {
+	// Name is the fully qualified name of the virtual machine.
+	Name       string
+	Definition state.Definition[types.VM]
+	// Installed is true if this virtual machine is already installed.
+	Installed bool
+}
+
+// Plan is an ordered list of virtual machines to install.
+type Plan struct {
+	// VMs are ordered so that each virtual machine comes after all of its
+	// dependencies.
+	VMs []Step
+}
+
+// Pending returns the steps in the plan that aren't installed yet.
+func (p Plan) Pending() []Step {
+	result := make([]Step, 0, len(p.VMs))
+	for _, step := range p.VMs {
+		if !step.Installed {
+			result = append(result, step)
+		}
+	}
+
+	return result
+}
+
+type ResolverConfig struct {
+	RepoFactory state.RepositoryFactory
+	StateFile   state.File
+	// Compatibility checks each definition against the node plugins are
+	// being installed for.
+	Compatibility workflow.CompatibilityChecker
+}
+
+func NewResolver(config ResolverConfig) *Resolver {
+	return &Resolver{
+		repoFactory:   config.RepoFactory,
+		stateFile:     config.StateFile,
+		compatibility: config.Compatibility,
+	}
+}
+
+// Resolver computes install plans for virtual machines and subnets. All
+// dependencies are resolved up-front so that cycles, conflicts, and
+// incompatibilities are detected before anything is downloaded.
+type Resolver struct {
+	repoFactory   state.RepositoryFactory
+	stateFile     state.File
+	compatibility workflow.CompatibilityChecker
+}
+
+// ResolveVM returns the plan to install the virtual machine with the provided
+// fully qualified name.
+func (r *Resolver) ResolveVM(name string) (Plan, error) {
+	res := r.newResolution()
+	if err := res.visit(name, ""); err != nil {
+		return Plan{}, err
+	}
+
+	return res.plan, nil
+}
+
+// ResolveSubnet returns the plan to install every virtual machine required by
+// the subnet with the provided fully qualified name.
+func (r *Resolver) ResolveSubnet(name string) (Plan, error) {
+	repoAlias, subnetName := util.ParseQualifiedName(name)
+	repository, err := r.repoFactory.GetRepository(repoAlias)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	definition, err := repository.GetSubnet(subnetName)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	subnet := definition.Definition
+	if err := r.compatibility.CheckAvalancheGo(name, subnet.Compatibility.AvalancheGo); err != nil {
+		return Plan{}, err
+	}
+
+	res := r.newResolution()
+	for _, vm := range subnet.VMs {
+		if err := res.visit(util.QualifyName(repoAlias, vm), name); err != nil {
+			return Plan{}, err
+		}
+	}
+
+	return res.plan, nil
+}
+
+func (r *Resolver) newResolution() *resolution {
+	return &resolution{
+		resolver: r,
+		visiting: make(map[string]bool),
+		resolved: make(map[string]bool),
+		ids:      make(map[string]string),
+	}
+}
+
+// resolution is the state of a single depth-first traversal of the
+// dependency graph.
+type resolution struct {
+	resolver *Resolver
+
+	// virtual machines on the current path through the graph
+	visiting map[string]bool
+	path     []string
+	// virtual machines that have already been added to the plan
+	resolved map[string]bool
+	// vm id -> fully qualified name of the virtual machine using it
+	ids map[string]string
+
+	plan Plan
+}
+
+func (res *resolution) visit(name string, requiredBy string) error {
+	if res.resolved[name] {
+		return nil
+	}
+	if res.visiting[name] {
+		return fmt.Errorf("%w: %s", ErrCycle, strings.Join(append(res.path, name), " -> "))
+	}
+
+	res.visiting[name] = true
+	res.path = append(res.path, name)
+
+	repoAlias, vmName := util.ParseQualifiedName(name)
+	repository, err := res.resolver.repoFactory.GetRepository(repoAlias)
+	if err != nil {
+		if requiredBy != "" {
+			return fmt.Errorf("%s requires %s, but repository %s could not be loaded: %w", requiredBy, name, repoAlias, err)
+		}
+		return err
+	}
+
+	definition, err := repository.GetVM(vmName)
+	if err != nil {
+		if requiredBy != "" {
+			return fmt.Errorf("%s requires %s, which could not be found: %w", requiredBy, name, err)
+		}
+		return err
+	}
+
+	vm := definition.Definition
+	if err := res.resolver.compatibility.CheckVM(name, vm); err != nil {
+		return err
+	}
+
+	for _, dependency := range vm.Dependencies {
+		if err := res.visit(util.QualifyName(repoAlias, dependency), name); err != nil {
+			return err
+		}
+	}
+
+	if err := res.checkConflicts(name, vm.ID); err != nil {
+		return err
+	}
+
+	delete(res.visiting, name)
+	res.path = res.path[:len(res.path)-1]
+	res.resolved[name] = true
+
+	_, installed := res.resolver.stateFile.InstallationRegistry[name]
+	res.plan.VMs = append(res.plan.VMs, Step{
+		Name:       name,
+		Definition: definition,
+		Installed:  installed,
+	})
+
+	return nil
+}
+
+// checkConflicts verifies that no other virtual machine in the plan or in the
+// installation registry would be installed to the same binary.
+func (res *resolution) checkConflicts(name string, id string) error {
+	if other, ok := res.ids[id]; ok && other != name {
+		return fmt.Errorf("%w: %s and %s both use vm id %s", ErrConflict, name, other, id)
+	}
+
+	for installed, installInfo := range res.resolver.stateFile.InstallationRegistry {
+		if installed != name && installInfo.ID == id {
+			return fmt.Errorf("%w: %s uses vm id %s, which is already installed by %s", ErrConflict, name, id, installed)
+		}
+	}
+
+	res.ids[id] = name
+	return nil
+}
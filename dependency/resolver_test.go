@@ -0,0 +1,242 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package dependency
+
+import (
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+	"github.com/ava-labs/apm/workflow"
+)
+
+func vmDefinition(id string, avalancheGoVersion string, dependencies ...string) state.Definition[types.VM] {
+	return state.Definition[types.VM]{
+		Definition: types.VM{
+			ID:           id,
+			Alias:        id,
+			Dependencies: dependencies,
+			Compatibility: types.Compatibility{
+				AvalancheGo: avalancheGoVersion,
+			},
+		},
+		Commit: "commit",
+	}
+}
+
+func TestResolveVM(t *testing.T) {
+	const (
+		repoAlias  = "organization/repository"
+		otherAlias = "other/repository"
+	)
+
+	type mocks struct {
+		stateFile   state.File
+		repoFactory *state.MockRepositoryFactory
+		repository  *state.MockRepository
+		other       *state.MockRepository
+	}
+	tests := []struct {
+		name               string
+		vm                 string
+		avalancheGoVersion string
+		setup              func(mocks)
+		want               []string
+		wantErr            error
+	}{
+		{
+			name: "no dependencies",
+			vm:   "organization/repository:a",
+			setup: func(mocks mocks) {
+				mocks.repository.EXPECT().GetVM("a").Return(vmDefinition("a", ""), nil)
+			},
+			want: []string{"organization/repository:a"},
+		},
+		{
+			name: "dependencies across repositories are installed first",
+			vm:   "organization/repository:a",
+			setup: func(mocks mocks) {
+				mocks.repository.EXPECT().GetVM("a").Return(vmDefinition("a", "", "b", "other/repository:c"), nil)
+				mocks.repository.EXPECT().GetVM("b").Return(vmDefinition("b", "", "other/repository:c"), nil)
+				mocks.other.EXPECT().GetVM("c").Return(vmDefinition("c", ""), nil)
+			},
+			want: []string{"other/repository:c", "organization/repository:b", "organization/repository:a"},
+		},
+		{
+			name: "cycle",
+			vm:   "organization/repository:a",
+			setup: func(mocks mocks) {
+				mocks.repository.EXPECT().GetVM("a").Return(vmDefinition("a", "", "b"), nil)
+				mocks.repository.EXPECT().GetVM("b").Return(vmDefinition("b", "", "a"), nil)
+			},
+			wantErr: ErrCycle,
+		},
+		{
+			name: "conflicting vm ids",
+			vm:   "organization/repository:a",
+			setup: func(mocks mocks) {
+				mocks.repository.EXPECT().GetVM("a").Return(vmDefinition("a", "", "other/repository:a"), nil)
+				mocks.other.EXPECT().GetVM("a").Return(vmDefinition("a", ""), nil)
+			},
+			wantErr: ErrConflict,
+		},
+		{
+			name: "conflicts with installed vm",
+			vm:   "organization/repository:a",
+			setup: func(mocks mocks) {
+				mocks.stateFile.InstallationRegistry["other/repository:a"] = &state.InstallInfo{ID: "a"}
+				mocks.repository.EXPECT().GetVM("a").Return(vmDefinition("a", ""), nil)
+			},
+			wantErr: ErrConflict,
+		},
+		{
+			name:               "incompatible avalanchego version",
+			vm:                 "organization/repository:a",
+			avalancheGoVersion: "v1.7.13",
+			setup: func(mocks mocks) {
+				mocks.repository.EXPECT().GetVM("a").Return(vmDefinition("a", ">=v1.7.14"), nil)
+			},
+			wantErr: workflow.ErrIncompatible,
+		},
+		{
+			name:               "compatible avalanchego version",
+			vm:                 "organization/repository:a",
+			avalancheGoVersion: "v1.7.14",
+			setup: func(mocks mocks) {
+				mocks.repository.EXPECT().GetVM("a").Return(vmDefinition("a", ">=v1.7.14, <v1.8.0"), nil)
+			},
+			want: []string{"organization/repository:a"},
+		},
+		{
+			name: "missing repository",
+			vm:   "organization/repository:a",
+			setup: func(mocks mocks) {
+				mocks.repository.EXPECT().GetVM("a").Return(vmDefinition("a", "", "missing/repository:b"), nil)
+				mocks.repoFactory.EXPECT().GetRepository("missing/repository").Return(nil, os.ErrNotExist)
+			},
+			wantErr: os.ErrNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+
+			avalancheGoVersion := test.avalancheGoVersion
+			if avalancheGoVersion == "" {
+				avalancheGoVersion = "v1.7.14"
+			}
+
+			repoFactory := state.NewMockRepositoryFactory(ctrl)
+			repository := state.NewMockRepository(ctrl)
+			other := state.NewMockRepository(ctrl)
+
+			repoFactory.EXPECT().GetRepository(repoAlias).Return(repository, nil).AnyTimes()
+			repoFactory.EXPECT().GetRepository(otherAlias).Return(other, nil).AnyTimes()
+
+			test.setup(mocks{
+				stateFile:   stateFile,
+				repoFactory: repoFactory,
+				repository:  repository,
+				other:       other,
+			})
+
+			resolver := NewResolver(ResolverConfig{
+				RepoFactory: repoFactory,
+				StateFile:   stateFile,
+				Compatibility: workflow.NewNodeCompatibility(workflow.NodeCompatibilityConfig{
+					AvalancheGoVersion: avalancheGoVersion,
+				}),
+			})
+
+			plan, err := resolver.ResolveVM(test.vm)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			got := make([]string, 0, len(plan.VMs))
+			for _, step := range plan.VMs {
+				got = append(got, step.Name)
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestResolveSubnet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	stateFile, err := state.New("stateFilePath")
+	require.NoError(t, err)
+	stateFile.InstallationRegistry["organization/repository:a"] = &state.InstallInfo{ID: "a"}
+
+	repoFactory := state.NewMockRepositoryFactory(ctrl)
+	repository := state.NewMockRepository(ctrl)
+	other := state.NewMockRepository(ctrl)
+	repoFactory.EXPECT().GetRepository("organization/repository").Return(repository, nil).AnyTimes()
+	repoFactory.EXPECT().GetRepository("other/repository").Return(other, nil).AnyTimes()
+
+	repository.EXPECT().GetSubnet("subnet").Return(state.Definition[types.Subnet]{
+		Definition: types.Subnet{
+			Alias: "subnet",
+			VMs:   []string{"a", "other/repository:b"},
+		},
+	}, nil)
+	repository.EXPECT().GetVM("a").Return(vmDefinition("a", ""), nil)
+	other.EXPECT().GetVM("b").Return(vmDefinition("b", "", "organization/repository:a"), nil)
+
+	resolver := NewResolver(ResolverConfig{
+		RepoFactory: repoFactory,
+		StateFile:   stateFile,
+		Compatibility: workflow.NewNodeCompatibility(workflow.NodeCompatibilityConfig{
+			AvalancheGoVersion: "v1.7.14",
+		}),
+	})
+
+	plan, err := resolver.ResolveSubnet("organization/repository:subnet")
+	require.NoError(t, err)
+	require.Len(t, plan.VMs, 2)
+	assert.True(t, plan.VMs[0].Installed)
+	assert.Equal(t, "other/repository:b", plan.VMs[1].Name)
+	assert.Equal(t, []Step{plan.VMs[1]}, plan.Pending())
+}
+
+func TestResolveSubnetIncompatible(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	stateFile, err := state.New("stateFilePath")
+	require.NoError(t, err)
+
+	repository := state.NewMockRepository(ctrl)
+	repository.EXPECT().GetSubnet("subnet").Return(state.Definition[types.Subnet]{
+		Definition: types.Subnet{
+			Alias:         "subnet",
+			VMs:           []string{"a"},
+			Compatibility: types.SubnetCompatibility{AvalancheGo: ">=v1.8.0"},
+		},
+	}, nil)
+	repoFactory := state.NewMockRepositoryFactory(ctrl)
+	repoFactory.EXPECT().GetRepository("organization/repository").Return(repository, nil)
+
+	resolver := NewResolver(ResolverConfig{
+		RepoFactory: repoFactory,
+		StateFile:   stateFile,
+		Compatibility: workflow.NewNodeCompatibility(workflow.NodeCompatibilityConfig{
+			AvalancheGoVersion: "v1.7.14",
+		}),
+	})
+
+	_, err = resolver.ResolveSubnet("organization/repository:subnet")
+	assert.ErrorIs(t, err, workflow.ErrIncompatible)
+}
@@ -0,0 +1,43 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package engine
+
+import (
+	"fmt"
+
+	"github.com/ava-labs/apm/workflow"
+)
+
+var _ workflow.Executor = &DryRunEngine{}
+
+func NewDryRunEngine() *DryRunEngine {
+	return &DryRunEngine{}
+}
+
+// DryRunEngine plans workflows instead of executing them. The state file is
+// never committed.
+type DryRunEngine struct {
+	actions []workflow.Action
+}
+
+func (d *DryRunEngine) Execute(wf workflow.Workflow) error {
+	planner, ok := wf.(workflow.Planner)
+	if !ok {
+		return fmt.Errorf("%T does not support dry runs", wf)
+	}
+
+	actions, err := planner.Plan()
+	if err != nil {
+		return err
+	}
+
+	d.actions = append(d.actions, actions...)
+	return nil
+}
+
+// Actions returns every action planned so far, in the order they would be
+// performed.
+func (d *DryRunEngine) Actions() []workflow.Action {
+	return d.actions
+}
@@ -4,22 +4,35 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
 
+	"github.com/ava-labs/apm/audit"
 	"github.com/ava-labs/apm/state"
 	"github.com/ava-labs/apm/workflow"
 )
 
 var _ workflow.Executor = &WorkflowEngine{}
 
-func NewWorkflowEngine(stateFile state.File) *WorkflowEngine {
+type WorkflowEngineConfig struct {
+	StateFile state.File
+	// AuditLog, if set, records every workflow that's executed.
+	AuditLog *audit.Log
+}
+
+func NewWorkflowEngine(config WorkflowEngineConfig) *WorkflowEngine {
 	return &WorkflowEngine{
-		stateFile: stateFile,
+		stateFile: config.StateFile,
+		auditLog:  config.AuditLog,
 	}
 }
 
 type WorkflowEngine struct {
 	stateFile state.File
+	auditLog  *audit.Log
 }
 
 func (w *WorkflowEngine) Execute(workflow workflow.Workflow) error {
@@ -29,5 +42,65 @@ func (w *WorkflowEngine) Execute(workflow workflow.Workflow) error {
 		}
 	}()
 
-	return workflow.Execute()
+	err := workflow.Execute()
+	w.audit(workflow, err)
+	return err
+}
+
+// audit records the workflow and what it left the state at.
+func (w *WorkflowEngine) audit(wf workflow.Workflow, err error) {
+	// Nothing happened.
+	if w.auditLog == nil || errors.Is(err, workflow.ErrAlreadyUpdated) {
+		return
+	}
+
+	entry := audit.Entry{
+		Workflow: workflowName(wf),
+		Outcome:  audit.Succeeded,
+	}
+	if err != nil {
+		entry.Outcome = audit.Failed
+		entry.Error = err.Error()
+	}
+
+	if named, ok := wf.(workflow.Named); ok {
+		entry.Name = named.Name()
+		if installInfo, ok := w.stateFile.InstallationRegistry[entry.Name]; ok {
+			entry.Commit = installInfo.Commit
+			entry.SHA256 = installInfo.SHA256
+		} else if source, ok := w.stateFile.Sources[entry.Name]; ok {
+			entry.Commit = source.Commit
+		}
+	}
+
+	if _, ok := wf.(*workflow.Update); ok {
+		entry.Commits = make(map[string]string, len(w.stateFile.Sources))
+		for alias, source := range w.stateFile.Sources {
+			entry.Commits[alias] = source.Commit
+		}
+	}
+
+	if err := w.auditLog.Record(entry); err != nil {
+		fmt.Printf("Warning - failed to write to the audit log: %s\n", err)
+	}
+}
+
+// workflowName returns the kebab-case name of the workflow's type (e.g
+// upgrade-vm for UpgradeVM).
+func workflowName(wf workflow.Workflow) string {
+	t := reflect.TypeOf(wf)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	name := []rune(t.Name())
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(name[i-1]) {
+			b.WriteRune('-')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
 }
@@ -0,0 +1,83 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package engine
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/audit"
+	"github.com/ava-labs/apm/git"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+	"github.com/ava-labs/apm/workflow"
+)
+
+func TestWorkflowEngineAudit(t *testing.T) {
+	const alias = "organization/repository"
+	errWrong := errors.New("something went wrong")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dir := t.TempDir()
+	stateFile, err := state.New(dir)
+	require.NoError(t, err)
+	auditLog := audit.NewLog(audit.LogConfig{
+		Fs:   afero.NewMemMapFs(),
+		Path: filepath.Join(dir, "audit.jsonl"),
+		User: "user",
+	})
+	e := NewWorkflowEngine(WorkflowEngineConfig{
+		StateFile: stateFile,
+		AuditLog:  auditLog,
+	})
+
+	// Named workflows record what they left the state at.
+	gitFactory := git.NewMockFactory(ctrl)
+	gitFactory.EXPECT().GetRepository("url", gomock.Any(), gomock.Any(), gomock.Any()).Return("commit", nil)
+	repoFactory := state.NewMockRepositoryFactory(ctrl)
+	repository := state.NewMockRepository(ctrl)
+	repoFactory.EXPECT().GetRepository(alias).Return(repository, nil)
+	repository.EXPECT().GetMetadata().Return(types.Repository{SchemaVersion: types.SchemaVersion}, nil)
+
+	assert.NoError(t, e.Execute(workflow.NewAddRepository(workflow.AddRepositoryConfig{
+		SourcesList:      stateFile.Sources,
+		Alias:            alias,
+		URL:              "url",
+		RepositoriesPath: dir,
+		Git:              gitFactory,
+		RepoFactory:      repoFactory,
+		Fs:               afero.NewMemMapFs(),
+	})))
+
+	failing := workflow.NewMockWorkflow(ctrl)
+	failing.EXPECT().Execute().Return(errWrong)
+	assert.ErrorIs(t, e.Execute(failing), errWrong)
+
+	// Upgrades that don't change anything aren't recorded.
+	alreadyUpdated := workflow.NewMockWorkflow(ctrl)
+	alreadyUpdated.EXPECT().Execute().Return(workflow.ErrAlreadyUpdated)
+	assert.ErrorIs(t, e.Execute(alreadyUpdated), workflow.ErrAlreadyUpdated)
+
+	entries, err := auditLog.Read(audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "add-repository", entries[0].Workflow)
+	assert.Equal(t, alias, entries[0].Name)
+	assert.Equal(t, audit.Succeeded, entries[0].Outcome)
+	assert.Equal(t, "commit", entries[0].Commit)
+	assert.Equal(t, "user", entries[0].User)
+
+	assert.Equal(t, "mock-workflow", entries[1].Workflow)
+	assert.Equal(t, audit.Failed, entries[1].Outcome)
+	assert.Equal(t, errWrong.Error(), entries[1].Error)
+}
@@ -4,17 +4,25 @@
 package git
 
 import (
+	"errors"
 	"io"
 	"os"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
 type Factory interface {
 	GetRepository(url string, path string, reference plumbing.ReferenceName, auth *http.BasicAuth) (string, error)
 	GetLastModified(repoPath string, filePath string) (string, error)
+	// CheckRemote returns an error if the repository at url can't be reached.
+	CheckRemote(url string, auth *http.BasicAuth) error
+	// Checkout checks out commit in the repository at path, fetching it from
+	// origin if it isn't there yet.
+	Checkout(path string, commit string, auth *http.BasicAuth) error
 }
 
 type RepositoryFactory struct{}
@@ -93,3 +101,43 @@ func (f RepositoryFactory) GetLastModified(repoAbsolutePath string, fileRelative
 
 	return commit.Hash.String(), nil
 }
+
+func (f RepositoryFactory) CheckRemote(url string, auth *http.BasicAuth) error {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{url},
+	})
+
+	_, err := remote.List(&git.ListOptions{Auth: auth})
+	return err
+}
+
+func (f RepositoryFactory) Checkout(path string, commit string, auth *http.BasicAuth) error {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return err
+	}
+
+	hash := plumbing.NewHash(commit)
+	if _, err := repo.CommitObject(hash); errors.Is(err, plumbing.ErrObjectNotFound) {
+		// Clones only have the branch they track, so the commit could be on
+		// another one or newer than the last update.
+		if err := repo.Fetch(&git.FetchOptions{
+			RemoteName: "origin",
+			RefSpecs:   []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+			Auth:       auth,
+			Progress:   io.Discard,
+		}); err != nil && err != git.NoErrAlreadyUpToDate {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	return worktree.Checkout(&git.CheckoutOptions{Hash: hash})
+}
@@ -39,6 +39,34 @@ func (m *MockFactory) EXPECT() *MockFactoryMockRecorder {
 	return m.recorder
 }
 
+// CheckRemote mocks base method.
+func (m *MockFactory) CheckRemote(url string, auth *http.BasicAuth) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckRemote", url, auth)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckRemote indicates an expected call of CheckRemote.
+func (mr *MockFactoryMockRecorder) CheckRemote(url, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckRemote", reflect.TypeOf((*MockFactory)(nil).CheckRemote), url, auth)
+}
+
+// Checkout mocks base method.
+func (m *MockFactory) Checkout(path, commit string, auth *http.BasicAuth) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Checkout", path, commit, auth)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Checkout indicates an expected call of Checkout.
+func (mr *MockFactoryMockRecorder) Checkout(path, commit, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checkout", reflect.TypeOf((*MockFactory)(nil).Checkout), path, commit, auth)
+}
+
 // GetLastModified mocks base method.
 func (m *MockFactory) GetLastModified(repoPath, filePath string) (string, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,246 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+var (
+	_ Hook = &CommandHook{}
+	_ Hook = &WebhookHook{}
+
+	errInvalidHook = errors.New("invalid hook")
+)
+
+// EventType is something that happened during a workflow.
+type EventType string
+
+const (
+	// RepositoryUpdated is emitted when an update finds new commits in a
+	// repository.
+	RepositoryUpdated EventType = "repository-updated"
+	// UpgradeStarted is emitted when a virtual machine starts being rebuilt.
+	UpgradeStarted EventType = "upgrade-started"
+	// UpgradeSucceeded is emitted when a virtual machine was upgraded.
+	UpgradeSucceeded EventType = "upgrade-succeeded"
+	// UpgradeFailed is emitted when a virtual machine failed to upgrade.
+	UpgradeFailed EventType = "upgrade-failed"
+	// ChecksumMismatch is emitted when a download didn't match its checksum.
+	ChecksumMismatch EventType = "checksum-mismatch"
+	// SubnetJoined is emitted when a subnet was joined.
+	SubnetJoined EventType = "subnet-joined"
+)
+
+var eventTypes = map[EventType]bool{
+	RepositoryUpdated: true,
+	UpgradeStarted:    true,
+	UpgradeSucceeded:  true,
+	UpgradeFailed:     true,
+	ChecksumMismatch:  true,
+	SubnetJoined:      true,
+}
+
+// Event is the json payload sent to hooks.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Name is the repository alias, or the fully qualified name of the virtual
+	// machine or subnet, the event is about.
+	Name string `json:"name"`
+	// From and To are the commits of an update or upgrade.
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Hook is notified of events.
+type Hook interface {
+	Fire(event Event) error
+}
+
+// Config is how a hook is declared in the config file. Exactly one of Command
+// or URL must be set.
+type Config struct {
+	// Events are the event types the hook is notified of. If empty, it's
+	// notified of every event.
+	Events []EventType `mapstructure:"events"`
+	// Command is run with sh -c.
+	Command string `mapstructure:"command"`
+	// URL is sent an HTTP POST.
+	URL string `mapstructure:"url"`
+	// Headers are added to the HTTP POST (e.g for authentication).
+	Headers map[string]string `mapstructure:"headers"`
+	// Timeout is how long the hook can take. Defaults to 10s.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func New(configs []Config) (*Hooks, error) {
+	result := &Hooks{
+		now: time.Now,
+	}
+
+	for i, config := range configs {
+		for _, eventType := range config.Events {
+			if !eventTypes[eventType] {
+				return nil, fmt.Errorf("%w %d: unknown event %s", errInvalidHook, i, eventType)
+			}
+		}
+
+		timeout := config.Timeout
+		if timeout == 0 {
+			timeout = defaultTimeout
+		}
+
+		var hook Hook
+		switch {
+		case config.Command != "" && config.URL != "":
+			return nil, fmt.Errorf("%w %d: only one of command or url can be set", errInvalidHook, i)
+		case config.Command != "":
+			hook = NewCommandHook(config.Command, timeout)
+		case config.URL != "":
+			hook = NewWebhookHook(config.URL, config.Headers, timeout)
+		default:
+			return nil, fmt.Errorf("%w %d: one of command or url must be set", errInvalidHook, i)
+		}
+
+		result.hooks = append(result.hooks, filteredHook{
+			Hook:   hook,
+			events: config.Events,
+		})
+	}
+
+	return result, nil
+}
+
+// Hooks notifies hooks of the events they're interested in.
+type Hooks struct {
+	hooks []filteredHook
+	now   func() time.Time
+}
+
+// Emit notifies hooks of the event. Hooks that fail are reported, but never
+// fail the workflow that emitted the event.
+func (h *Hooks) Emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = h.now()
+	}
+
+	for _, hook := range h.hooks {
+		if !hook.wants(event.Type) {
+			continue
+		}
+
+		if err := hook.Fire(event); err != nil {
+			fmt.Printf("Warning - %s hook failed: %s\n", event.Type, err)
+		}
+	}
+}
+
+type filteredHook struct {
+	Hook
+	events []EventType
+}
+
+func (f filteredHook) wants(eventType EventType) bool {
+	if len(f.events) == 0 {
+		return true
+	}
+
+	for _, e := range f.events {
+		if e == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewCommandHook(command string, timeout time.Duration) *CommandHook {
+	return &CommandHook{
+		command: command,
+		timeout: timeout,
+	}
+}
+
+// CommandHook runs a shell command with the event on stdin. The event's type
+// and name are also set in APM_EVENT and APM_EVENT_NAME.
+type CommandHook struct {
+	command string
+	timeout time.Duration
+}
+
+func (c *CommandHook) Fire(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.command) // #nosec G204 hooks are configured by the operator
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("APM_EVENT=%s", event.Type),
+		fmt.Sprintf("APM_EVENT_NAME=%s", event.Name),
+	)
+
+	return cmd.Run()
+}
+
+func NewWebhookHook(url string, headers map[string]string, timeout time.Duration) *WebhookHook {
+	return &WebhookHook{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// WebhookHook sends the event as json in an HTTP POST.
+type WebhookHook struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (w *WebhookHook) Fire(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range w.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s responded with status code %d", w.url, response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a local HTTP server that records the events posted to it.
+type recorder struct {
+	*httptest.Server
+
+	lock    sync.Mutex
+	events  []Event
+	headers []http.Header
+}
+
+func newRecorder(t *testing.T, status int) *recorder {
+	r := &recorder{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		event := Event{}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&event))
+
+		r.lock.Lock()
+		r.events = append(r.events, event)
+		r.headers = append(r.headers, req.Header)
+		r.lock.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []Config
+		wantErr error
+	}{
+		{
+			name: "valid",
+			configs: []Config{
+				{Command: "true"},
+				{URL: "http://127.0.0.1", Events: []EventType{UpgradeFailed}},
+			},
+		},
+		{
+			name:    "unknown event",
+			configs: []Config{{Command: "true", Events: []EventType{"upgraded"}}},
+			wantErr: errInvalidHook,
+		},
+		{
+			name:    "no target",
+			configs: []Config{{Events: []EventType{UpgradeFailed}}},
+			wantErr: errInvalidHook,
+		},
+		{
+			name:    "both targets",
+			configs: []Config{{Command: "true", URL: "http://127.0.0.1"}},
+			wantErr: errInvalidHook,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(test.configs)
+			assert.ErrorIs(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestEmitWebhook(t *testing.T) {
+	all := newRecorder(t, http.StatusOK)
+	failures := newRecorder(t, http.StatusOK)
+	broken := newRecorder(t, http.StatusInternalServerError)
+
+	now := time.Date(2022, 7, 2, 12, 0, 0, 0, time.UTC)
+	hooks, err := New([]Config{
+		{
+			URL:     all.URL,
+			Headers: map[string]string{"authorization": "Bearer token"},
+		},
+		{
+			URL:    failures.URL,
+			Events: []EventType{UpgradeFailed, ChecksumMismatch},
+		},
+		{
+			URL: broken.URL,
+		},
+	})
+	require.NoError(t, err)
+	hooks.now = func() time.Time {
+		return now
+	}
+
+	succeeded := Event{Type: UpgradeSucceeded, Name: "organization/repository:vm", From: "a", To: "b"}
+	failed := Event{Type: UpgradeFailed, Name: "organization/repository:vm", From: "a", Error: "something went wrong"}
+	hooks.Emit(succeeded)
+	hooks.Emit(failed)
+
+	succeeded.Time = now
+	failed.Time = now
+	assert.Equal(t, []Event{succeeded, failed}, all.events)
+	assert.Equal(t, "Bearer token", all.headers[0].Get("Authorization"))
+	assert.Equal(t, "application/json", all.headers[0].Get("Content-Type"))
+	assert.Equal(t, []Event{failed}, failures.events)
+	// Failing hooks don't stop other hooks from being notified.
+	assert.Len(t, broken.events, 2)
+}
+
+func TestCommandHook(t *testing.T) {
+	dir := t.TempDir()
+	payload := filepath.Join(dir, "payload.json")
+	env := filepath.Join(dir, "env")
+
+	hook := NewCommandHook(fmt.Sprintf(`cat > %s && echo "$APM_EVENT $APM_EVENT_NAME" > %s`, payload, env), time.Second)
+	event := Event{Type: SubnetJoined, Name: "organization/repository:subnet", Time: time.Unix(0, 0).UTC()}
+	require.NoError(t, hook.Fire(event))
+
+	bytes, err := os.ReadFile(payload)
+	require.NoError(t, err)
+	got := Event{}
+	require.NoError(t, json.Unmarshal(bytes, &got))
+	assert.Equal(t, event, got)
+
+	bytes, err = os.ReadFile(env)
+	require.NoError(t, err)
+	assert.Equal(t, "subnet-joined organization/repository:subnet\n", string(bytes))
+
+	assert.Error(t, NewCommandHook("exit 1", time.Second).Fire(event))
+	assert.Error(t, NewCommandHook("exec sleep 5", 10*time.Millisecond).Fire(event))
+}
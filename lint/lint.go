@@ -0,0 +1,406 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ava-labs/apm/checksum"
+	"github.com/ava-labs/apm/constant"
+	"github.com/ava-labs/apm/constraint"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+	"github.com/ava-labs/apm/url"
+)
+
+const (
+	vmDir     = state.VMDir
+	subnetDir = state.SubnetDir
+	extension = "." + state.Extension
+
+	metadataFile = state.MetadataFile + extension
+)
+
+var sha256Pattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// Problem is something wrong with a file in a plugin repository.
+type Problem struct {
+	// File is relative to the root of the repository.
+	File    string
+	Message string
+	// Warning is whether the problem is accepted by the apm, but should still
+	// be fixed.
+	Warning bool
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
+
+type Config struct {
+	Fs afero.Fs
+	// Path is the root of the plugin repository.
+	Path string
+	// VerifyChecksums downloads every virtual machine's artifact to TmpPath
+	// and checks it against the definition's sha256.
+	VerifyChecksums bool
+	URLClient       url.Client
+	TmpPath         string
+}
+
+func New(config Config) *Linter {
+	return &Linter{
+		fs:              config.Fs,
+		path:            config.Path,
+		verifyChecksums: config.VerifyChecksums,
+		urlClient:       config.URLClient,
+		tmpPath:         config.TmpPath,
+		checksummer:     checksum.NewSHA256(config.Fs),
+	}
+}
+
+// Linter checks the definitions in a plugin repository for mistakes that would
+// otherwise only be found when a user installs them.
+type Linter struct {
+	fs              afero.Fs
+	path            string
+	verifyChecksums bool
+	urlClient       url.Client
+	tmpPath         string
+	checksummer     checksum.Checksummer
+
+	problems []Problem
+}
+
+// Lint returns the problems found in the repository, including warnings. An
+// error is only returned if the repository couldn't be read.
+func (l *Linter) Lint() ([]Problem, error) {
+	l.problems = nil
+
+	if _, err := l.fs.Stat(l.path); err != nil {
+		return nil, err
+	}
+
+	vms, err := l.list(vmDir)
+	if err != nil {
+		return nil, err
+	}
+	subnets, err := l.list(subnetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vms) == 0 && len(subnets) == 0 {
+		l.report(".", "no %s or %s definitions found", vmDir, subnetDir)
+	}
+
+	if err := l.lintMetadata(); err != nil {
+		return nil, err
+	}
+
+	available := make(map[string]bool, len(vms))
+	for _, name := range vms {
+		available[name] = true
+	}
+
+	binaries := make(map[string]string, len(vms))
+	for _, name := range vms {
+		if err := l.lintVM(name, available, binaries); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range subnets {
+		if err := l.lintSubnet(name, available); err != nil {
+			return nil, err
+		}
+	}
+
+	return l.problems, nil
+}
+
+func (l *Linter) report(file string, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{
+		File:    file,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *Linter) warn(file string, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{
+		File:    file,
+		Message: fmt.Sprintf(format, args...),
+		Warning: true,
+	})
+}
+
+// list returns the names of the definitions in dir.
+func (l *Linter) list(dir string) ([]string, error) {
+	entries, err := afero.ReadDir(l.fs, filepath.Join(l.path, dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), extension) {
+			l.report(filepath.Join(dir, entry.Name()), "definitions must have a %s extension", extension)
+			continue
+		}
+
+		result = append(result, strings.TrimSuffix(entry.Name(), extension))
+	}
+
+	sort.Strings(result)
+	return result, nil
+}
+
+// decode strictly parses file into out, reporting unknown fields. Returns
+// false if the file couldn't be parsed.
+func (l *Linter) decode(file string, out interface{}) (bool, error) {
+	b, err := afero.ReadFile(l.fs, filepath.Join(l.path, file))
+	if err != nil {
+		return false, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); errors.Is(err, io.EOF) {
+		l.report(file, "file is empty")
+		return false, nil
+	} else if err != nil {
+		l.report(file, "failed to parse: %s", err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (l *Linter) lintMetadata() error {
+	if _, err := l.fs.Stat(filepath.Join(l.path, metadataFile)); errors.Is(err, os.ErrNotExist) {
+		// add-repository accepts repositories from before the metadata file
+		// was introduced, assuming schema version 1.
+		l.warn(metadataFile, "missing repository metadata (schema version 1 is assumed)")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	metadata := types.Repository{}
+	if ok, err := l.decode(metadataFile, &metadata); !ok || err != nil {
+		return err
+	}
+
+	if metadata.Name == "" {
+		l.report(metadataFile, "missing required field name")
+	}
+	if metadata.SchemaVersion < 1 || metadata.SchemaVersion > types.SchemaVersion {
+		l.report(metadataFile, "schemaVersion %d is not supported (must be between 1 and %d)", metadata.SchemaVersion, types.SchemaVersion)
+	}
+
+	return nil
+}
+
+// lintVM checks the definition of the virtual machine name. binaries maps the
+// ids of the virtual machines checked so far to their file, since virtual
+// machines with the same id overwrite each other's binary.
+func (l *Linter) lintVM(name string, available map[string]bool, binaries map[string]string) error {
+	file := filepath.Join(vmDir, name+extension)
+
+	vm := types.VM{}
+	if ok, err := l.decode(file, &vm); !ok || err != nil {
+		return err
+	}
+
+	l.requireFields(file, map[string]string{
+		"id":         vm.ID,
+		"alias":      vm.Alias,
+		"binaryPath": vm.BinaryPath,
+		"url":        vm.URL,
+		"sha256":     vm.SHA256,
+	})
+	l.checkAlias(file, name, vm.Alias)
+
+	if vm.ID != "" {
+		if _, err := ids.FromString(vm.ID); err != nil {
+			l.report(file, "id %s is not a valid VM ID: %s", vm.ID, err)
+		}
+		if other, ok := binaries[vm.ID]; ok {
+			l.report(file, "id %s is also used by %s", vm.ID, other)
+		}
+		binaries[vm.ID] = file
+	}
+
+	if vm.URL != "" {
+		l.checkURL(file, vm.URL)
+	}
+	if vm.SHA256 != "" && !sha256Pattern.MatchString(vm.SHA256) {
+		l.report(file, "sha256 %s must be 64 lowercase hex characters", vm.SHA256)
+	}
+
+	for _, dependency := range vm.Dependencies {
+		if dependency == name {
+			l.report(file, "depends on itself")
+		} else {
+			l.checkReference(file, "dependency", dependency, available)
+		}
+	}
+
+	if _, err := constraint.Parse(vm.Compatibility.AvalancheGo); err != nil {
+		l.report(file, "invalid compatibility.avalancheGo constraint: %s", err)
+	}
+
+	if l.verifyChecksums && vm.URL != "" && sha256Pattern.MatchString(vm.SHA256) {
+		return l.checkArtifact(file, name, vm)
+	}
+
+	return nil
+}
+
+func (l *Linter) lintSubnet(name string, available map[string]bool) error {
+	file := filepath.Join(subnetDir, name+extension)
+
+	subnet := types.Subnet{}
+	if ok, err := l.decode(file, &subnet); !ok || err != nil {
+		return err
+	}
+
+	l.requireFields(file, map[string]string{
+		"alias": subnet.Alias,
+	})
+	l.checkAlias(file, name, subnet.Alias)
+
+	if len(subnet.ID) == 0 {
+		l.report(file, "missing required field id")
+	}
+	l.checkIDs(file, "subnet", subnet.ID)
+
+	if len(subnet.VMs) == 0 {
+		l.report(file, "missing required field vms")
+	}
+	for _, vm := range subnet.VMs {
+		l.checkReference(file, "vm", vm, available)
+	}
+
+	for _, chain := range subnet.Chains {
+		if chain.Alias == "" {
+			l.report(file, "chain is missing required field alias")
+		}
+		l.checkIDs(file, "chain", chain.ID)
+	}
+
+	if _, err := constraint.Parse(subnet.Compatibility.AvalancheGo); err != nil {
+		l.report(file, "invalid compatibility.avalancheGo constraint: %s", err)
+	}
+
+	return nil
+}
+
+func (l *Linter) requireFields(file string, fields map[string]string) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if strings.TrimSpace(fields[name]) == "" {
+			l.report(file, "missing required field %s", name)
+		}
+	}
+}
+
+// checkAlias checks that the definition is named after its alias, since
+// definitions are looked up by file name.
+func (l *Linter) checkAlias(file string, name string, alias string) {
+	if alias != "" && alias != name {
+		l.report(file, "alias %s doesn't match the file name (expected %s)", alias, name)
+	}
+}
+
+// checkIDs checks the ids of a subnet or chain on each network.
+func (l *Linter) checkIDs(file string, kind string, networkIDs map[string]string) {
+	networks := make([]string, 0, len(networkIDs))
+	for network := range networkIDs {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+
+	for _, network := range networks {
+		if _, err := ids.FromString(networkIDs[network]); err != nil {
+			l.report(file, "%s id %s on %s is not a valid ID: %s", kind, networkIDs[network], network, err)
+		}
+	}
+}
+
+// checkReference checks that a virtual machine referenced by alias exists in
+// this repository. Fully qualified names can refer to other repositories, so
+// they aren't checked.
+func (l *Linter) checkReference(file string, kind string, reference string, available map[string]bool) {
+	if strings.Contains(reference, constant.QualifiedNameDelimiter) {
+		return
+	}
+
+	if !available[reference] {
+		l.report(file, "%s %s doesn't exist in %s", kind, reference, vmDir)
+	}
+}
+
+func (l *Linter) checkURL(file string, rawURL string) {
+	parsed, err := neturl.ParseRequestURI(rawURL)
+	if err != nil {
+		l.report(file, "url %s is malformed: %s", rawURL, err)
+		return
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		l.report(file, "url %s must use http or https", rawURL)
+	}
+	if parsed.Host == "" {
+		l.report(file, "url %s is missing a host", rawURL)
+	}
+}
+
+// checkArtifact downloads the virtual machine's artifact and checks its
+// checksum.
+func (l *Linter) checkArtifact(file string, name string, vm types.VM) error {
+	if err := l.fs.MkdirAll(l.tmpPath, perms.ReadWriteExecute); err != nil {
+		return err
+	}
+
+	archivePath := filepath.Join(l.tmpPath, fmt.Sprintf("%s.tar.gz", name))
+	defer func() {
+		_ = l.fs.Remove(archivePath)
+	}()
+
+	if err := l.urlClient.Download(vm.URL, archivePath); err != nil {
+		l.report(file, "failed to download %s: %s", vm.URL, err)
+		return nil
+	}
+
+	if hash := fmt.Sprintf("%x", l.checksummer.Checksum(archivePath)); hash != vm.SHA256 {
+		l.report(file, "checksum of %s is %s but the definition expects %s", vm.URL, hash, vm.SHA256)
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package lint
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/url"
+)
+
+const metadata = `
+name: repository
+description: description
+schemaVersion: 1
+`
+
+func TestLint(t *testing.T) {
+	vmID := ids.GenerateTestID().String()
+	subnetID := ids.GenerateTestID().String()
+
+	validVM := fmt.Sprintf(`
+id: %s
+alias: foovm
+homepage: homepage
+description: description
+maintainers:
+  - maintainer
+installScript: ./build.sh
+binaryPath: ./build/foovm
+url: https://example.com/foovm.tar.gz
+sha256: %064x
+`, vmID, 1)
+	validSubnet := fmt.Sprintf(`
+id:
+  fuji: %s
+alias: foosubnet
+homepage: homepage
+description: description
+maintainers:
+  - maintainer
+vms:
+  - foovm
+`, subnetID)
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+		// warnings is how many of the problems are warnings.
+		warnings int
+	}{
+		{
+			name: "valid",
+			files: map[string]string{
+				"repository.yaml":        metadata,
+				"vms/foovm.yaml":         validVM,
+				"subnets/foosubnet.yaml": validSubnet,
+			},
+			want: []string{},
+		},
+		{
+			name: "empty repository",
+			files: map[string]string{
+				"README.md": "",
+			},
+			want: []string{
+				".: no vms or subnets definitions found",
+				"repository.yaml: missing repository metadata",
+			},
+			warnings: 1,
+		},
+		{
+			name: "unsupported schema version",
+			files: map[string]string{
+				"repository.yaml": "name: repository\nschemaVersion: 2\n",
+				"vms/foovm.yaml":  validVM,
+			},
+			want: []string{
+				"repository.yaml: schemaVersion 2 is not supported (must be between 1 and 1)",
+			},
+		},
+		{
+			name: "unknown field",
+			files: map[string]string{
+				"repository.yaml": metadata,
+				"vms/foovm.yaml":  validVM + "binary: ./foovm\n",
+			},
+			want: []string{
+				"vms/foovm.yaml: failed to parse: yaml: unmarshal errors:\n  line 12: field binary not found in type types.VM",
+			},
+		},
+		{
+			name: "malformed vm",
+			files: map[string]string{
+				"repository.yaml": metadata,
+				"vms/foovm.yaml": `
+id: not-an-id
+alias: barvm
+url: example.com/foovm.tar.gz
+sha256: ABC
+dependencies:
+  - foovm
+  - bazvm
+  - organization/repository:bazvm
+compatibility:
+  avalancheGo: "~v1.7"
+`,
+				"vms/notes.txt": "",
+			},
+			want: []string{
+				"vms/notes.txt: definitions must have a .yaml extension",
+				"vms/foovm.yaml: missing required field binaryPath",
+				"vms/foovm.yaml: alias barvm doesn't match the file name (expected foovm)",
+				"vms/foovm.yaml: id not-an-id is not a valid VM ID",
+				"vms/foovm.yaml: url example.com/foovm.tar.gz is malformed",
+				"vms/foovm.yaml: sha256 ABC must be 64 lowercase hex characters",
+				"vms/foovm.yaml: depends on itself",
+				"vms/foovm.yaml: dependency bazvm doesn't exist in vms",
+				"vms/foovm.yaml: invalid compatibility.avalancheGo constraint",
+			},
+		},
+		{
+			name: "duplicate vm ids",
+			files: map[string]string{
+				"repository.yaml": metadata,
+				"vms/barvm.yaml":  validVM,
+				"vms/foovm.yaml":  validVM,
+			},
+			want: []string{
+				"vms/barvm.yaml: alias foovm doesn't match the file name (expected barvm)",
+				fmt.Sprintf("vms/foovm.yaml: id %s is also used by vms/barvm.yaml", vmID),
+			},
+		},
+		{
+			name: "malformed subnet",
+			files: map[string]string{
+				"repository.yaml": metadata,
+				"vms/foovm.yaml":  validVM,
+				"subnets/foosubnet.yaml": `
+id:
+  fuji: not-an-id
+alias: foosubnet
+vms:
+  - foovm
+  - barvm
+  - organization/repository:barvm
+chains:
+  - id:
+      fuji: not-an-id
+compatibility:
+  avalancheGo: "~v1.7"
+`,
+				"subnets/empty.yaml": "",
+			},
+			want: []string{
+				"subnets/empty.yaml: file is empty",
+				"subnets/foosubnet.yaml: subnet id not-an-id on fuji is not a valid ID",
+				"subnets/foosubnet.yaml: vm barvm doesn't exist in vms",
+				"subnets/foosubnet.yaml: chain is missing required field alias",
+				"subnets/foosubnet.yaml: chain id not-an-id on fuji is not a valid ID",
+				"subnets/foosubnet.yaml: invalid compatibility.avalancheGo constraint",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			for file, contents := range test.files {
+				require.NoError(t, afero.WriteFile(fs, filepath.Join("repository", file), []byte(contents), perms.ReadWrite))
+			}
+
+			problems, err := New(Config{Fs: fs, Path: "repository"}).Lint()
+			require.NoError(t, err)
+
+			require.Len(t, problems, len(test.want), "%v", problems)
+			warnings := 0
+			for i, problem := range problems {
+				// Only check the start of messages that include errors from
+				// other packages.
+				assert.Contains(t, problem.String(), test.want[i])
+				if problem.Warning {
+					warnings++
+				}
+			}
+			assert.Equal(t, test.warnings, warnings)
+		})
+	}
+}
+
+func TestLintMissingRepository(t *testing.T) {
+	_, err := New(Config{Fs: afero.NewMemMapFs(), Path: "repository"}).Lint()
+	assert.Error(t, err)
+}
+
+func TestLintVerifyChecksums(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	artifact := []byte("artifact")
+	definition := func(alias string, sha256 string) string {
+		return fmt.Sprintf(`
+id: %s
+alias: %s
+binaryPath: ./build/%s
+url: https://example.com/%s.tar.gz
+sha256: %s
+`, ids.GenerateTestID(), alias, alias, alias, sha256)
+	}
+
+	fs := afero.NewMemMapFs()
+	files := map[string]string{
+		"repository.yaml": metadata,
+		"vms/foovm.yaml":  definition("foovm", fmt.Sprintf("%x", sha256.Sum256(artifact))),
+		"vms/barvm.yaml":  definition("barvm", fmt.Sprintf("%064x", 1)),
+		"vms/bazvm.yaml":  definition("bazvm", fmt.Sprintf("%064x", 1)),
+	}
+	for file, contents := range files {
+		require.NoError(t, afero.WriteFile(fs, filepath.Join("repository", file), []byte(contents), perms.ReadWrite))
+	}
+
+	// the mock records the path before the url
+	download := func(path string, _ string) error {
+		return afero.WriteFile(fs, path, artifact, perms.ReadWrite)
+	}
+	urlClient := url.NewMockClient(ctrl)
+	urlClient.EXPECT().Download(filepath.Join("tmp", "foovm.tar.gz"), "https://example.com/foovm.tar.gz").DoAndReturn(download)
+	urlClient.EXPECT().Download(filepath.Join("tmp", "barvm.tar.gz"), "https://example.com/barvm.tar.gz").DoAndReturn(download)
+	urlClient.EXPECT().Download(gomock.Any(), "https://example.com/bazvm.tar.gz").Return(errors.New("not found"))
+
+	problems, err := New(Config{
+		Fs:              fs,
+		Path:            "repository",
+		VerifyChecksums: true,
+		URLClient:       urlClient,
+		TmpPath:         "tmp",
+	}).Lint()
+	require.NoError(t, err)
+
+	require.Len(t, problems, 2)
+	assert.Contains(t, problems[0].String(), "vms/barvm.yaml: checksum of https://example.com/barvm.tar.gz is")
+	assert.Equal(t, "vms/bazvm.yaml: failed to download https://example.com/bazvm.tar.gz: not found", problems[1].String())
+
+	// downloads are cleaned up
+	entries, err := afero.ReadDir(fs, "tmp")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+)
+
+// DefaultPath is where the lock file is written to and read from if no path
+// is provided.
+const DefaultPath = "apm-lock.yaml"
+
+var ErrMismatch = errors.New("does not match the lock file")
+
+// File records exactly what was installed on a node so the same artifacts can
+// be installed on other nodes.
+type File struct {
+	// Mapping of each tracked repository's alias to the commit it was at
+	Repositories map[string]Repository `yaml:"repositories"`
+	// Mapping of each installed vm's fully qualified name to its artifact
+	VMs map[string]VM `yaml:"vms"`
+}
+
+// Repository is a tracked repository at a specific commit.
+type Repository struct {
+	URL    string `yaml:"url"`
+	Branch string `yaml:"branch"`
+	Commit string `yaml:"commit"`
+}
+
+// VM is the artifact a virtual machine was installed from.
+type VM struct {
+	// Commit is the commit of the virtual machine's definition.
+	Commit string `yaml:"commit"`
+	URL    string `yaml:"url"`
+	SHA256 string `yaml:"sha256"`
+}
+
+func New() File {
+	return File{
+		Repositories: make(map[string]Repository),
+		VMs:          make(map[string]VM),
+	}
+}
+
+// Load reads the lock file at path.
+func Load(fs afero.Fs, path string) (File, error) {
+	bytes, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return File{}, err
+	}
+
+	result := New()
+	if err := yaml.Unmarshal(bytes, &result); err != nil {
+		return File{}, fmt.Errorf("failed to parse lock file %s: %w", path, err)
+	}
+
+	return result, nil
+}
+
+// Write writes the lock file to path.
+func (f File) Write(fs afero.Fs, path string) error {
+	bytes, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(fs, path, bytes, perms.ReadWrite)
+}
+
+// CheckVM returns an error if the definition a virtual machine would be
+// installed from differs from the one in the lock file.
+func (f File) CheckVM(name string, definition state.Definition[types.VM]) error {
+	locked, ok := f.VMs[name]
+	if !ok {
+		return fmt.Errorf("%s %w: it isn't locked", name, ErrMismatch)
+	}
+
+	vm := definition.Definition
+	switch {
+	case definition.Commit != locked.Commit:
+		return fmt.Errorf("%s %w: its definition is at %s but %s is locked", name, ErrMismatch, definition.Commit, locked.Commit)
+	case vm.URL != locked.URL:
+		return fmt.Errorf("%s %w: it would be downloaded from %s but %s is locked", name, ErrMismatch, vm.URL, locked.URL)
+	case vm.SHA256 != locked.SHA256:
+		return fmt.Errorf("%s %w: its sha256 is %s but %s is locked", name, ErrMismatch, vm.SHA256, locked.SHA256)
+	default:
+		return nil
+	}
+}
+
+// CheckInstalled returns an error if an installed virtual machine is at a
+// different commit than the one in the lock file.
+func (f File) CheckInstalled(name string, installInfo state.InstallInfo) error {
+	locked, ok := f.VMs[name]
+	if !ok {
+		return fmt.Errorf("%s %w: it isn't locked", name, ErrMismatch)
+	}
+
+	if installInfo.Commit != locked.Commit {
+		return fmt.Errorf("%s %w: it's installed at %s but %s is locked", name, ErrMismatch, installInfo.Commit, locked.Commit)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package lockfile
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+)
+
+func TestCheckVM(t *testing.T) {
+	const name = "organization/repository:vm"
+
+	lockFile := New()
+	lockFile.VMs[name] = VM{
+		Commit: "commit",
+		URL:    "url",
+		SHA256: "sha256",
+	}
+
+	tests := []struct {
+		name       string
+		vm         string
+		definition state.Definition[types.VM]
+		wantErr    assert.ErrorAssertionFunc
+	}{
+		{
+			name: "matches",
+			vm:   name,
+			definition: state.Definition[types.VM]{
+				Definition: types.VM{URL: "url", SHA256: "sha256"},
+				Commit:     "commit",
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "not locked",
+			vm:   "organization/repository:other",
+			definition: state.Definition[types.VM]{
+				Definition: types.VM{URL: "url", SHA256: "sha256"},
+				Commit:     "commit",
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrMismatch)
+			},
+		},
+		{
+			name: "different commit",
+			vm:   name,
+			definition: state.Definition[types.VM]{
+				Definition: types.VM{URL: "url", SHA256: "sha256"},
+				Commit:     "newer",
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrMismatch)
+			},
+		},
+		{
+			name: "different artifact",
+			vm:   name,
+			definition: state.Definition[types.VM]{
+				Definition: types.VM{URL: "url", SHA256: "tampered"},
+				Commit:     "commit",
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrMismatch)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.wantErr(t, lockFile.CheckVM(test.vm, test.definition))
+		})
+	}
+}
+
+func TestWriteLoad(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	lockFile := New()
+	lockFile.Repositories["organization/repository"] = Repository{
+		URL:    "url",
+		Branch: "main",
+		Commit: "commit",
+	}
+	lockFile.VMs["organization/repository:vm"] = VM{
+		Commit: "commit",
+		URL:    "url",
+		SHA256: "sha256",
+	}
+
+	require.NoError(t, lockFile.Write(fs, DefaultPath))
+
+	got, err := Load(fs, DefaultPath)
+	require.NoError(t, err)
+	assert.Equal(t, lockFile, got)
+}
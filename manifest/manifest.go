@@ -0,0 +1,124 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ava-labs/apm/constant"
+	"github.com/ava-labs/apm/util"
+)
+
+// DefaultPath is where the manifest is read from if no path is provided.
+const DefaultPath = "apm.yaml"
+
+// Manifest declares the repositories, virtual machines and subnets a node
+// should have.
+type Manifest struct {
+	Repositories []Repository `yaml:"repositories"`
+	VMs          []VM         `yaml:"vms"`
+	// Subnets are the fully qualified names of the subnets to join.
+	Subnets []string `yaml:"subnets"`
+}
+
+// Repository is a plugin repository to track.
+type Repository struct {
+	Alias  string `yaml:"alias"`
+	URL    string `yaml:"url"`
+	Branch string `yaml:"branch"`
+}
+
+// VM is a virtual machine to install.
+type VM struct {
+	// Name is the fully qualified name of the virtual machine.
+	Name string `yaml:"name"`
+	// Commit pins the virtual machine to a version of its definition. If
+	// empty, any installed version is accepted.
+	Commit string `yaml:"commit,omitempty"`
+}
+
+// Load reads and validates the manifest at path.
+func Load(fs afero.Fs, path string) (Manifest, error) {
+	bytes, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	manifest := Manifest{}
+	if err := yaml.Unmarshal(bytes, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	if err := manifest.Validate(); err != nil {
+		return Manifest{}, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+
+	return manifest, nil
+}
+
+// Validate returns an error if the manifest is malformed.
+func (m Manifest) Validate() error {
+	repositories := make(map[string]bool, len(m.Repositories))
+	for _, repository := range m.Repositories {
+		if !validAlias(repository.Alias) {
+			return fmt.Errorf("%s is not a valid alias (must be in the form of organization/repository)", repository.Alias)
+		}
+		if repository.URL == "" || repository.Branch == "" {
+			return fmt.Errorf("repository %s must have a url and branch", repository.Alias)
+		}
+		if repositories[repository.Alias] {
+			return fmt.Errorf("repository %s is declared more than once", repository.Alias)
+		}
+		repositories[repository.Alias] = true
+	}
+
+	vms := make(map[string]bool, len(m.VMs))
+	for _, vm := range m.VMs {
+		if err := validateName(vm.Name, repositories); err != nil {
+			return err
+		}
+		if vms[vm.Name] {
+			return fmt.Errorf("vm %s is declared more than once", vm.Name)
+		}
+		vms[vm.Name] = true
+	}
+
+	subnets := make(map[string]bool, len(m.Subnets))
+	for _, subnet := range m.Subnets {
+		if err := validateName(subnet, repositories); err != nil {
+			return err
+		}
+		if subnets[subnet] {
+			return fmt.Errorf("subnet %s is declared more than once", subnet)
+		}
+		subnets[subnet] = true
+	}
+
+	return nil
+}
+
+// validAlias checks that alias is in the form of organization/repository.
+// util.ValidAlias assumes the alias has exactly one delimiter.
+func validAlias(alias string) bool {
+	return strings.Count(alias, constant.AliasDelimiter) == 1 && util.ValidAlias(alias)
+}
+
+// validateName checks that name is fully qualified and belongs to a
+// repository that will be tracked.
+func validateName(name string, repositories map[string]bool) error {
+	if !strings.Contains(name, constant.QualifiedNameDelimiter) {
+		return fmt.Errorf("%s is not a fully qualified name (must be in the form of organization/repository:name)", name)
+	}
+
+	alias, _ := util.ParseQualifiedName(name)
+	if alias != constant.CoreAlias && !repositories[alias] {
+		return fmt.Errorf("%s belongs to repository %s, which isn't declared", name, alias)
+	}
+
+	return nil
+}
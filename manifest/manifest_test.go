@@ -0,0 +1,105 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package manifest
+
+import (
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     Manifest
+		wantErr  assert.ErrorAssertionFunc
+	}{
+		{
+			name: "valid",
+			manifest: `
+repositories:
+  - alias: organization/repository
+    url: https://github.com/organization/repository.git
+    branch: main
+vms:
+  - name: organization/repository:vm
+    commit: commit
+  - name: ava-labs/avalanche-plugins-core:spacesvm
+subnets:
+  - organization/repository:subnet
+`,
+			want: Manifest{
+				Repositories: []Repository{
+					{
+						Alias:  "organization/repository",
+						URL:    "https://github.com/organization/repository.git",
+						Branch: "main",
+					},
+				},
+				VMs: []VM{
+					{Name: "organization/repository:vm", Commit: "commit"},
+					{Name: "ava-labs/avalanche-plugins-core:spacesvm"},
+				},
+				Subnets: []string{"organization/repository:subnet"},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "invalid alias",
+			manifest: `
+repositories:
+  - alias: repository
+    url: url
+    branch: main
+`,
+			wantErr: assert.Error,
+		},
+		{
+			name: "duplicate repository",
+			manifest: `
+repositories:
+  - alias: organization/repository
+    url: url
+    branch: main
+  - alias: organization/repository
+    url: url
+    branch: main
+`,
+			wantErr: assert.Error,
+		},
+		{
+			name: "unqualified vm",
+			manifest: `
+vms:
+  - name: spacesvm
+`,
+			wantErr: assert.Error,
+		},
+		{
+			name: "undeclared repository",
+			manifest: `
+subnets:
+  - organization/repository:subnet
+`,
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, DefaultPath, []byte(test.manifest), perms.ReadWrite))
+
+			got, err := Load(fs, DefaultPath)
+			if !test.wantErr(t, err) || err != nil {
+				return
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
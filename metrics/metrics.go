@@ -0,0 +1,235 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/afero"
+)
+
+const namespace = "apm"
+
+// Operations that can fail.
+const (
+	InstallOperation = "install"
+	UpgradeOperation = "upgrade"
+	UpdateOperation  = "update"
+)
+
+var _ prometheus.Collector = &repositoryCollector{}
+
+// Metrics are the prometheus metrics of apm operations. Safe for concurrent
+// use.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	installs           prometheus.Counter
+	upgrades           prometheus.Counter
+	failures           *prometheus.CounterVec
+	downloadBytes      prometheus.Counter
+	downloadDuration   prometheus.Histogram
+	checksumMismatches prometheus.Counter
+	syncDuration       *prometheus.HistogramVec
+	repositories       *repositoryCollector
+}
+
+func New() (*Metrics, error) {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		installs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "installs_total",
+			Help:      "Number of virtual machines installed",
+		}),
+		upgrades: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upgrades_total",
+			Help:      "Number of virtual machines upgraded",
+		}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failures_total",
+			Help:      "Number of failed operations",
+		}, []string{"operation"}),
+		downloadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "download_bytes_total",
+			Help:      "Number of bytes of virtual machine archives downloaded",
+		}),
+		downloadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "download_duration_seconds",
+			Help:      "Time taken to download virtual machine archives",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+		}),
+		checksumMismatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "checksum_mismatches_total",
+			Help:      "Number of downloads that didn't match their expected checksum",
+		}),
+		syncDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_sync_duration_seconds",
+			Help:      "Time taken to sync a repository",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+		}, []string{"repository"}),
+		repositories: newRepositoryCollector(time.Now),
+	}
+
+	errs := wrappers.Errs{}
+	errs.Add(
+		m.registry.Register(m.installs),
+		m.registry.Register(m.upgrades),
+		m.registry.Register(m.failures),
+		m.registry.Register(m.downloadBytes),
+		m.registry.Register(m.downloadDuration),
+		m.registry.Register(m.checksumMismatches),
+		m.registry.Register(m.syncDuration),
+		m.registry.Register(m.repositories),
+	)
+	if errs.Errored() {
+		return nil, errs.Err
+	}
+
+	return m, nil
+}
+
+// Install records an installed virtual machine.
+func (m *Metrics) Install() {
+	m.installs.Inc()
+}
+
+// Upgrade records an upgraded virtual machine.
+func (m *Metrics) Upgrade() {
+	m.upgrades.Inc()
+}
+
+// Failure records a failed operation.
+func (m *Metrics) Failure(operation string) {
+	m.failures.WithLabelValues(operation).Inc()
+}
+
+// Download records a downloaded archive.
+func (m *Metrics) Download(bytes int64, duration time.Duration) {
+	m.downloadBytes.Add(float64(bytes))
+	m.downloadDuration.Observe(duration.Seconds())
+}
+
+// ChecksumMismatch records a download that didn't match its checksum.
+func (m *Metrics) ChecksumMismatch() {
+	m.checksumMismatches.Inc()
+}
+
+// Sync records how long it took to sync the repository.
+func (m *Metrics) Sync(repository string, duration time.Duration) {
+	m.syncDuration.WithLabelValues(repository).Observe(duration.Seconds())
+}
+
+// Updated sets when each repository was last successfully updated, keyed by
+// alias. Repositories that were never updated should be left out.
+func (m *Metrics) Updated(updated map[string]time.Time) {
+	m.repositories.set(updated)
+}
+
+// Handler serves the metrics in the prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// WriteTextfile writes the metrics to path for the node exporter's textfile
+// collector. The file is replaced atomically so the collector never reads a
+// partial file.
+func (m *Metrics) WriteTextfile(fs afero.Fs, path string) error {
+	families, err := m.registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.%d", filepath.Base(path), os.Getpid()))
+	file, err := fs.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perms.ReadWrite)
+	if err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(file, family); err != nil {
+			_ = file.Close()
+			_ = fs.Remove(tmpPath)
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		_ = fs.Remove(tmpPath)
+		return err
+	}
+
+	return fs.Rename(tmpPath, path)
+}
+
+// repositoryCollector reports when each repository was last updated, and how
+// long ago that was at the time of collection.
+type repositoryCollector struct {
+	now           func() time.Time
+	timestampDesc *prometheus.Desc
+	ageDesc       *prometheus.Desc
+
+	lock    sync.Mutex
+	updated map[string]time.Time
+}
+
+func newRepositoryCollector(now func() time.Time) *repositoryCollector {
+	return &repositoryCollector{
+		now: now,
+		timestampDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "repository", "last_update_timestamp_seconds"),
+			"Unix time of the repository's last successful update",
+			[]string{"repository"},
+			nil,
+		),
+		ageDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "repository", "last_update_age_seconds"),
+			"Time since the repository's last successful update",
+			[]string{"repository"},
+			nil,
+		),
+		updated: make(map[string]time.Time),
+	}
+}
+
+func (r *repositoryCollector) set(updated map[string]time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.updated = make(map[string]time.Time, len(updated))
+	for repository, t := range updated {
+		r.updated[repository] = t
+	}
+}
+
+func (r *repositoryCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- r.timestampDesc
+	descs <- r.ageDesc
+}
+
+func (r *repositoryCollector) Collect(metrics chan<- prometheus.Metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	for repository, updated := range r.updated {
+		metrics <- prometheus.MustNewConstMetric(r.timestampDesc, prometheus.GaugeValue, float64(updated.Unix()), repository)
+		metrics <- prometheus.MustNewConstMetric(r.ageDesc, prometheus.GaugeValue, now.Sub(updated).Seconds(), repository)
+	}
+}
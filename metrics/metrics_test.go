@@ -0,0 +1,74 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTextfile(t *testing.T) {
+	m, err := New()
+	require.NoError(t, err)
+
+	m.Install()
+	m.Install()
+	m.Upgrade()
+	m.Failure(UpgradeOperation)
+	m.ChecksumMismatch()
+	m.Download(1024, 2*time.Second)
+	m.Sync("organization/repository", 300*time.Millisecond)
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("textfiles", 0o755))
+	require.NoError(t, m.WriteTextfile(fs, "textfiles/apm.prom"))
+
+	bytes, err := afero.ReadFile(fs, "textfiles/apm.prom")
+	require.NoError(t, err)
+	text := string(bytes)
+	assert.Contains(t, text, "apm_installs_total 2\n")
+	assert.Contains(t, text, "apm_upgrades_total 1\n")
+	assert.Contains(t, text, `apm_failures_total{operation="upgrade"} 1`)
+	assert.Contains(t, text, "apm_checksum_mismatches_total 1\n")
+	assert.Contains(t, text, "apm_download_bytes_total 1024\n")
+	assert.Contains(t, text, "apm_download_duration_seconds_count 1\n")
+	assert.Contains(t, text, `apm_repository_sync_duration_seconds_count{repository="organization/repository"} 1`)
+
+	// The temporary file is renamed over the textfile.
+	files, err := afero.ReadDir(fs, "textfiles")
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestRepositoryCollector(t *testing.T) {
+	m, err := New()
+	require.NoError(t, err)
+
+	now := time.Unix(1_000_000, 0)
+	m.repositories.now = func() time.Time {
+		return now
+	}
+	m.Updated(map[string]time.Time{
+		"organization/repository": now.Add(-time.Hour),
+	})
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	bytes, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	text := string(bytes)
+	assert.Contains(t, text, `apm_repository_last_update_timestamp_seconds{repository="organization/repository"} 996400`)
+	assert.Contains(t, text, `apm_repository_last_update_age_seconds{repository="organization/repository"} 3600`)
+}
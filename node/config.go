@@ -0,0 +1,211 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/ava-labs/avalanchego/version"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// TrackSubnetsKey is the avalanchego config key for the subnets a node
+	// tracks.
+	TrackSubnetsKey = "track-subnets"
+	// WhitelistedSubnetsKey is the name of TrackSubnetsKey in older versions
+	// of avalanchego.
+	WhitelistedSubnetsKey = "whitelisted-subnets"
+
+	subnetDelimiter = ","
+)
+
+// trackSubnetsVersion is the first version of avalanchego that reads
+// TrackSubnetsKey.
+var trackSubnetsVersion = &version.Semantic{Major: 1, Minor: 9, Patch: 6}
+
+// NodeVersion returns the version of avalanchego the node is running, or false
+// if it couldn't be determined.
+type NodeVersion func() (*version.Semantic, bool)
+
+// SubnetsKey returns the config key nodeVersion reads its tracked subnets
+// from. Nodes of unknown versions are assumed to read WhitelistedSubnetsKey,
+// like the avalanchego version apm supports.
+func SubnetsKey(nodeVersion NodeVersion) string {
+	if nodeVersion == nil {
+		return WhitelistedSubnetsKey
+	}
+
+	v, ok := nodeVersion()
+	if !ok || v.Compare(trackSubnetsVersion) < 0 {
+		return WhitelistedSubnetsKey
+	}
+	return TrackSubnetsKey
+}
+
+// Config manages the subnets tracked by a node.
+type Config interface {
+	// TrackSubnet adds the subnet to the node's tracked subnets. Returns true
+	// if the node's configuration was changed.
+	TrackSubnet(subnetID string) (bool, error)
+	// UntrackSubnet removes the subnet from the node's tracked subnets.
+	// Returns true if the node's configuration was changed.
+	UntrackSubnet(subnetID string) (bool, error)
+}
+
+var (
+	_ Config = &FileConfig{}
+	_ Config = &ManualConfig{}
+)
+
+func NewFileConfig(fs afero.Fs, path string, nodeVersion NodeVersion) *FileConfig {
+	return &FileConfig{
+		fs:          fs,
+		path:        path,
+		nodeVersion: nodeVersion,
+	}
+}
+
+// FileConfig manages tracked subnets by editing an avalanchego config file.
+// All other keys in the file are preserved. Like avalanchego, the file is
+// parsed as yaml if it has a .yaml or .yml extension and as json otherwise.
+type FileConfig struct {
+	fs          afero.Fs
+	path        string
+	nodeVersion NodeVersion
+}
+
+func (f *FileConfig) TrackSubnet(subnetID string) (bool, error) {
+	return f.update(func(subnets []string) []string {
+		for _, subnet := range subnets {
+			if subnet == subnetID {
+				return subnets
+			}
+		}
+		return append(subnets, subnetID)
+	})
+}
+
+func (f *FileConfig) UntrackSubnet(subnetID string) (bool, error) {
+	return f.update(func(subnets []string) []string {
+		result := make([]string, 0, len(subnets))
+		for _, subnet := range subnets {
+			if subnet != subnetID {
+				result = append(result, subnet)
+			}
+		}
+		return result
+	})
+}
+
+func (f *FileConfig) update(modify func([]string) []string) (bool, error) {
+	config := make(map[string]interface{})
+
+	bytes, err := afero.ReadFile(f.fs, f.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// We'll create the config file when we write it out.
+	case err != nil:
+		return false, err
+	default:
+		if err := f.unmarshal(bytes, &config); err != nil {
+			return false, fmt.Errorf("failed to parse node config %s: %w", f.path, err)
+		}
+	}
+
+	// Respect the key the operator is already using.
+	key := SubnetsKey(f.nodeVersion)
+	for _, existing := range []string{TrackSubnetsKey, WhitelistedSubnetsKey} {
+		if _, ok := config[existing]; ok {
+			key = existing
+			break
+		}
+	}
+
+	previous := ""
+	if raw, ok := config[key]; ok {
+		previous, ok = raw.(string)
+		if !ok {
+			return false, fmt.Errorf("expected %s in %s to be a string but got %T", key, f.path, raw)
+		}
+	}
+
+	subnets := make([]string, 0)
+	for _, subnet := range strings.Split(previous, subnetDelimiter) {
+		if subnet = strings.TrimSpace(subnet); subnet != "" {
+			subnets = append(subnets, subnet)
+		}
+	}
+
+	before := strings.Join(subnets, subnetDelimiter)
+	updated := strings.Join(modify(subnets), subnetDelimiter)
+	if updated == before {
+		return false, nil
+	}
+
+	if updated == "" {
+		delete(config, key)
+	} else {
+		config[key] = updated
+	}
+
+	bytes, err = f.marshal(config)
+	if err != nil {
+		return false, err
+	}
+
+	return true, afero.WriteFile(f.fs, f.path, bytes, perms.ReadWrite)
+}
+
+func (f *FileConfig) isYAML() bool {
+	switch strings.ToLower(filepath.Ext(f.path)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *FileConfig) unmarshal(bytes []byte, config *map[string]interface{}) error {
+	if f.isYAML() {
+		return yaml.Unmarshal(bytes, config)
+	}
+	return json.Unmarshal(bytes, config)
+}
+
+func (f *FileConfig) marshal(config map[string]interface{}) ([]byte, error) {
+	if f.isYAML() {
+		return yaml.Marshal(config)
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
+func NewManualConfig(nodeVersion NodeVersion) ManualConfig {
+	return ManualConfig{
+		nodeVersion: nodeVersion,
+	}
+}
+
+// ManualConfig is used when apm doesn't know where the node's config file is.
+// It tells the operator how to update their node instead.
+type ManualConfig struct {
+	nodeVersion NodeVersion
+}
+
+func (m ManualConfig) TrackSubnet(subnetID string) (bool, error) {
+	fmt.Printf("No node config file was provided. Add %s to the --%s flag of your node to track it.\n", subnetID, SubnetsKey(m.nodeVersion))
+	return false, nil
+}
+
+func (m ManualConfig) UntrackSubnet(subnetID string) (bool, error) {
+	fmt.Printf("No node config file was provided. Remove %s from the --%s flag of your node to stop tracking it.\n", subnetID, SubnetsKey(m.nodeVersion))
+	return false, nil
+}
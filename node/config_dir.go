@@ -0,0 +1,161 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/spf13/afero"
+)
+
+const (
+	subnetsDir          = "subnets"
+	chainsDir           = "chains"
+	chainConfigFile     = "config.json"
+	chainUpgradeFile    = "upgrade.json"
+	configFileExtension = ".json"
+)
+
+// ConfigDir manages the subnet and chain config files read by a node.
+type ConfigDir interface {
+	// WriteSubnetConfig merges config into the subnet's config file. Returns
+	// true if the file was changed.
+	WriteSubnetConfig(subnetID string, config map[string]interface{}) (bool, error)
+	// WriteChainConfig merges config and upgrade into the chain's config and
+	// upgrade files. Returns true if either file was changed.
+	WriteChainConfig(chainID string, config map[string]interface{}, upgrade map[string]interface{}) (bool, error)
+	// RemoveSubnetConfig removes the subnet's config file. Returns true if
+	// there was one.
+	RemoveSubnetConfig(subnetID string) (bool, error)
+	// RemoveChainConfig removes the chain's config directory. Returns true if
+	// there was one.
+	RemoveChainConfig(chainID string) (bool, error)
+}
+
+var _ ConfigDir = &FileConfigDir{}
+
+func NewFileConfigDir(fs afero.Fs, path string) *FileConfigDir {
+	return &FileConfigDir{
+		fs:   fs,
+		path: path,
+	}
+}
+
+// FileConfigDir writes config files in the layout avalanchego expects under its
+// configs directory (subnets/<subnetID>.json and chains/<chainID>/*.json).
+//
+// Values already in a file take precedence over the ones being written, so
+// local overrides made by the operator are preserved.
+type FileConfigDir struct {
+	fs   afero.Fs
+	path string
+}
+
+func (f *FileConfigDir) WriteSubnetConfig(subnetID string, config map[string]interface{}) (bool, error) {
+	return f.write(filepath.Join(f.path, subnetsDir, subnetID+configFileExtension), config)
+}
+
+func (f *FileConfigDir) WriteChainConfig(chainID string, config map[string]interface{}, upgrade map[string]interface{}) (bool, error) {
+	chainDir := filepath.Join(f.path, chainsDir, chainID)
+
+	configChanged, err := f.write(filepath.Join(chainDir, chainConfigFile), config)
+	if err != nil {
+		return false, err
+	}
+
+	upgradeChanged, err := f.write(filepath.Join(chainDir, chainUpgradeFile), upgrade)
+	if err != nil {
+		return false, err
+	}
+
+	return configChanged || upgradeChanged, nil
+}
+
+func (f *FileConfigDir) RemoveSubnetConfig(subnetID string) (bool, error) {
+	return f.remove(filepath.Join(f.path, subnetsDir, subnetID+configFileExtension))
+}
+
+func (f *FileConfigDir) RemoveChainConfig(chainID string) (bool, error) {
+	return f.remove(filepath.Join(f.path, chainsDir, chainID))
+}
+
+func (f *FileConfigDir) remove(path string) (bool, error) {
+	exists, err := afero.Exists(f.fs, path)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	return true, f.fs.RemoveAll(path)
+}
+
+func (f *FileConfigDir) write(path string, config map[string]interface{}) (bool, error) {
+	if len(config) == 0 {
+		return false, nil
+	}
+
+	existing := make(map[string]interface{})
+
+	previous, err := afero.ReadFile(f.fs, path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// We'll create the file when we write it out.
+	case err != nil:
+		return false, err
+	default:
+		if err := json.Unmarshal(previous, &existing); err != nil {
+			return false, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+
+	updated, err := json.MarshalIndent(merge(config, existing), "", "  ")
+	if err != nil {
+		return false, err
+	}
+
+	if previous != nil {
+		// Compare against the previous contents after normalizing them, so
+		// formatting differences alone don't count as a change.
+		normalized, err := json.MarshalIndent(existing, "", "  ")
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(normalized, updated) {
+			return false, nil
+		}
+	}
+
+	if err := f.fs.MkdirAll(filepath.Dir(path), perms.ReadWriteExecute); err != nil {
+		return false, err
+	}
+
+	return true, afero.WriteFile(f.fs, path, updated, perms.ReadWrite)
+}
+
+// merge returns defaults with overrides applied on top of it. Nested objects
+// are merged recursively.
+func merge(defaults map[string]interface{}, overrides map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(defaults)+len(overrides))
+	for key, value := range defaults {
+		result[key] = value
+	}
+
+	for key, override := range overrides {
+		overrideMap, overrideIsMap := override.(map[string]interface{})
+		defaultMap, defaultIsMap := result[key].(map[string]interface{})
+		if overrideIsMap && defaultIsMap {
+			result[key] = merge(defaultMap, overrideMap)
+			continue
+		}
+
+		result[key] = override
+	}
+
+	return result
+}
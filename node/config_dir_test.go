@@ -0,0 +1,144 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileConfigDirSubnetConfig(t *testing.T) {
+	path := filepath.Join("configs", "subnets", "subnetID.json")
+
+	tests := []struct {
+		name     string
+		existing string
+		config   map[string]interface{}
+		changed  bool
+		want     map[string]interface{}
+	}{
+		{
+			name: "creates config",
+			config: map[string]interface{}{
+				"validatorOnly": true,
+			},
+			changed: true,
+			want: map[string]interface{}{
+				"validatorOnly": true,
+			},
+		},
+		{
+			name:     "local overrides are preserved",
+			existing: `{"validatorOnly": false, "consensusParameters": {"k": 30}}`,
+			config: map[string]interface{}{
+				"validatorOnly": true,
+				"consensusParameters": map[string]interface{}{
+					"k":     20,
+					"alpha": 15,
+				},
+			},
+			changed: true,
+			want: map[string]interface{}{
+				"validatorOnly": false,
+				"consensusParameters": map[string]interface{}{
+					"k":     float64(30),
+					"alpha": float64(15),
+				},
+			},
+		},
+		{
+			name:     "already up to date",
+			existing: `{"validatorOnly":true}`,
+			config: map[string]interface{}{
+				"validatorOnly": true,
+			},
+			changed: false,
+			want: map[string]interface{}{
+				"validatorOnly": true,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			if test.existing != "" {
+				require.NoError(t, afero.WriteFile(fs, path, []byte(test.existing), perms.ReadWrite))
+			}
+
+			changed, err := NewFileConfigDir(fs, "configs").WriteSubnetConfig("subnetID", test.config)
+			require.NoError(t, err)
+			assert.Equal(t, test.changed, changed)
+
+			bytes, err := afero.ReadFile(fs, path)
+			require.NoError(t, err)
+
+			got := make(map[string]interface{})
+			require.NoError(t, json.Unmarshal(bytes, &got))
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestFileConfigDirChainConfig(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	configDir := NewFileConfigDir(fs, "configs")
+
+	changed, err := configDir.WriteChainConfig(
+		"chainID",
+		map[string]interface{}{"pruning-enabled": true},
+		nil,
+	)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	exists, err := afero.Exists(fs, filepath.Join("configs", "chains", "chainID", "config.json"))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// upgrades aren't written unless the definition has them
+	exists, err = afero.Exists(fs, filepath.Join("configs", "chains", "chainID", "upgrade.json"))
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestFileConfigDirRemove(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	configDir := NewFileConfigDir(fs, "configs")
+
+	_, err := configDir.WriteSubnetConfig("subnetID", map[string]interface{}{"validatorOnly": true})
+	require.NoError(t, err)
+	_, err = configDir.WriteChainConfig("chainID", map[string]interface{}{"pruning-enabled": false}, map[string]interface{}{"precompileUpgrades": []interface{}{}})
+	require.NoError(t, err)
+
+	removed, err := configDir.RemoveSubnetConfig("subnetID")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = configDir.RemoveChainConfig("chainID")
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	for _, path := range []string{
+		filepath.Join("configs", "subnets", "subnetID.json"),
+		filepath.Join("configs", "chains", "chainID"),
+	} {
+		exists, err := afero.Exists(fs, path)
+		require.NoError(t, err)
+		assert.False(t, exists, path)
+	}
+
+	// there's nothing left to remove
+	removed, err = configDir.RemoveSubnetConfig("subnetID")
+	require.NoError(t, err)
+	assert.False(t, removed)
+	removed, err = configDir.RemoveChainConfig("chainID")
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
@@ -0,0 +1,203 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/ava-labs/avalanchego/version"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSubnetsKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		nodeVersion NodeVersion
+		want        string
+	}{
+		{
+			name: "no version",
+			want: WhitelistedSubnetsKey,
+		},
+		{
+			name: "unknown version",
+			nodeVersion: func() (*version.Semantic, bool) {
+				return nil, false
+			},
+			want: WhitelistedSubnetsKey,
+		},
+		{
+			name: "old version",
+			nodeVersion: func() (*version.Semantic, bool) {
+				return &version.Semantic{Major: 1, Minor: 7, Patch: 14}, true
+			},
+			want: WhitelistedSubnetsKey,
+		},
+		{
+			name: "new version",
+			nodeVersion: func() (*version.Semantic, bool) {
+				return &version.Semantic{Major: 1, Minor: 9, Patch: 6}, true
+			},
+			want: TrackSubnetsKey,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, SubnetsKey(test.nodeVersion))
+		})
+	}
+}
+
+func TestFileConfig(t *testing.T) {
+	const path = "config.json"
+
+	newNode := func() (*version.Semantic, bool) {
+		return &version.Semantic{Major: 1, Minor: 9, Patch: 6}, true
+	}
+
+	tests := []struct {
+		name        string
+		existing    string
+		nodeVersion NodeVersion
+		update      func(*FileConfig) (bool, error)
+		changed     bool
+		want        map[string]interface{}
+	}{
+		{
+			name: "track creates config",
+			update: func(c *FileConfig) (bool, error) {
+				return c.TrackSubnet("a")
+			},
+			changed: true,
+			want: map[string]interface{}{
+				WhitelistedSubnetsKey: "a",
+			},
+		},
+		{
+			name:        "track creates config for new nodes",
+			nodeVersion: newNode,
+			update: func(c *FileConfig) (bool, error) {
+				return c.TrackSubnet("a")
+			},
+			changed: true,
+			want: map[string]interface{}{
+				TrackSubnetsKey: "a",
+			},
+		},
+		{
+			name:     "track preserves other keys",
+			existing: `{"network-id": "fuji", "track-subnets": "a"}`,
+			update: func(c *FileConfig) (bool, error) {
+				return c.TrackSubnet("b")
+			},
+			changed: true,
+			want: map[string]interface{}{
+				"network-id":    "fuji",
+				TrackSubnetsKey: "a,b",
+			},
+		},
+		{
+			name:     "track already tracked subnet",
+			existing: `{"track-subnets": "a, b"}`,
+			update: func(c *FileConfig) (bool, error) {
+				return c.TrackSubnet("b")
+			},
+			changed: false,
+			want: map[string]interface{}{
+				TrackSubnetsKey: "a, b",
+			},
+		},
+		{
+			name:        "track uses legacy key",
+			existing:    `{"whitelisted-subnets": "a"}`,
+			nodeVersion: newNode,
+			update: func(c *FileConfig) (bool, error) {
+				return c.TrackSubnet("b")
+			},
+			changed: true,
+			want: map[string]interface{}{
+				WhitelistedSubnetsKey: "a,b",
+			},
+		},
+		{
+			name:     "untrack",
+			existing: `{"track-subnets": "a,b"}`,
+			update: func(c *FileConfig) (bool, error) {
+				return c.UntrackSubnet("a")
+			},
+			changed: true,
+			want: map[string]interface{}{
+				TrackSubnetsKey: "b",
+			},
+		},
+		{
+			name:     "untrack last subnet",
+			existing: `{"track-subnets": "a"}`,
+			update: func(c *FileConfig) (bool, error) {
+				return c.UntrackSubnet("a")
+			},
+			changed: true,
+			want:    map[string]interface{}{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			if test.existing != "" {
+				require.NoError(t, afero.WriteFile(fs, path, []byte(test.existing), perms.ReadWrite))
+			}
+
+			changed, err := test.update(NewFileConfig(fs, path, test.nodeVersion))
+			require.NoError(t, err)
+			assert.Equal(t, test.changed, changed)
+
+			bytes, err := afero.ReadFile(fs, path)
+			require.NoError(t, err)
+
+			got := make(map[string]interface{})
+			require.NoError(t, json.Unmarshal(bytes, &got))
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestFileConfigYAML(t *testing.T) {
+	const path = "config.yaml"
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, path, []byte("network-id: fuji\nwhitelisted-subnets: a\n"), perms.ReadWrite))
+
+	changed, err := NewFileConfig(fs, path, nil).TrackSubnet("b")
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	bytes, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+
+	got := make(map[string]interface{})
+	require.NoError(t, yaml.Unmarshal(bytes, &got))
+	assert.Equal(t, map[string]interface{}{
+		"network-id":          "fuji",
+		WhitelistedSubnetsKey: "a,b",
+	}, got)
+}
+
+func TestFileConfigMalformed(t *testing.T) {
+	const path = "config.json"
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, path, []byte("network-id: fuji\n"), perms.ReadWrite))
+
+	_, err := NewFileConfig(fs, path, nil).TrackSubnet("a")
+	var syntaxErr *json.SyntaxError
+	assert.True(t, errors.As(err, &syntaxErr))
+}
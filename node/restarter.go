@@ -0,0 +1,65 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var _ Restarter = &CommandRestarter{}
+
+// Restarter restarts the node.
+type Restarter interface {
+	Restart() error
+}
+
+// CommandRestarter restarts the node by running a command.
+type CommandRestarter struct {
+	name string
+	args []string
+}
+
+// NewSystemdRestarter returns a Restarter for a node run as a systemd unit.
+func NewSystemdRestarter(unit string) *CommandRestarter {
+	return &CommandRestarter{
+		name: "systemctl",
+		args: []string{"restart", unit},
+	}
+}
+
+// NewDockerRestarter returns a Restarter for a node run in a docker
+// container.
+func NewDockerRestarter(container string) *CommandRestarter {
+	return &CommandRestarter{
+		name: "docker",
+		args: []string{"restart", container},
+	}
+}
+
+// NewCommandRestarter returns a Restarter that runs command in a shell.
+func NewCommandRestarter(command string) *CommandRestarter {
+	return &CommandRestarter{
+		name: "sh",
+		args: []string{"-c", command},
+	}
+}
+
+func (c *CommandRestarter) Restart() error {
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", c, err)
+	}
+
+	return nil
+}
+
+func (c *CommandRestarter) String() string {
+	return strings.Join(append([]string{c.name}, c.args...), " ")
+}
@@ -0,0 +1,18 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandRestarter(t *testing.T) {
+	assert.NoError(t, NewCommandRestarter("exit 0").Restart())
+	assert.Error(t, NewCommandRestarter("exit 1").Restart())
+
+	assert.Equal(t, "systemctl restart avalanchego", NewSystemdRestarter("avalanchego").String())
+	assert.Equal(t, "docker restart avalanchego", NewDockerRestarter("avalanchego").String())
+}
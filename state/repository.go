@@ -4,9 +4,11 @@
 package state
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 
@@ -14,11 +16,13 @@ import (
 	"github.com/ava-labs/apm/types"
 )
 
-var (
-	vmDir     = "vms"
-	subnetDir = "subnets"
+// The layout of a plugin repository.
+const (
+	VMDir        = "vms"
+	SubnetDir    = "subnets"
+	MetadataFile = "repository"
 
-	extension = "yaml"
+	Extension = "yaml"
 )
 
 // Repository wraps a plugin repository's VMs and Subnets
@@ -26,6 +30,13 @@ type Repository interface {
 	GetPath() string
 	GetVM(name string) (Definition[types.VM], error)
 	GetSubnet(name string) (Definition[types.Subnet], error)
+	// GetMetadata returns the repository's metadata file. The error wraps
+	// os.ErrNotExist if the repository doesn't have one.
+	GetMetadata() (types.Repository, error)
+	// ListVMs returns the names of every virtual machine in the repository.
+	ListVMs() ([]string, error)
+	// ListSubnets returns the names of every subnet in the repository.
+	ListSubnets() ([]string, error)
 }
 
 type DiskRepository struct {
@@ -34,11 +45,55 @@ type DiskRepository struct {
 }
 
 func (d DiskRepository) GetVM(name string) (Definition[types.VM], error) {
-	return get[types.VM](d, vmDir, name)
+	return get[types.VM](d, VMDir, name)
 }
 
 func (d DiskRepository) GetSubnet(name string) (Definition[types.Subnet], error) {
-	return get[types.Subnet](d, subnetDir, name)
+	return get[types.Subnet](d, SubnetDir, name)
+}
+
+func (d DiskRepository) GetMetadata() (types.Repository, error) {
+	bytes, err := os.ReadFile(filepath.Join(d.Path, fmt.Sprintf("%s.%s", MetadataFile, Extension)))
+	if err != nil {
+		return types.Repository{}, err
+	}
+
+	metadata := types.Repository{}
+	if err := yaml.Unmarshal(bytes, &metadata); err != nil {
+		return types.Repository{}, err
+	}
+
+	return metadata, nil
+}
+
+func (d DiskRepository) ListVMs() ([]string, error) {
+	return d.list(VMDir)
+}
+
+func (d DiskRepository) ListSubnets() ([]string, error) {
+	return d.list(SubnetDir)
+}
+
+// list returns the names of the definitions in dir.
+func (d DiskRepository) list(dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(d.Path, dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(entries))
+	suffix := fmt.Sprintf(".%s", Extension)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+
+		result = append(result, strings.TrimSuffix(entry.Name(), suffix))
+	}
+
+	return result, nil
 }
 
 func (d DiskRepository) GetPath() string {
@@ -46,7 +101,7 @@ func (d DiskRepository) GetPath() string {
 }
 
 func get[T types.Definition](d DiskRepository, dir string, file string) (Definition[T], error) {
-	relativePathWithExtension := filepath.Join(dir, fmt.Sprintf("%s.%s", file, extension))
+	relativePathWithExtension := filepath.Join(dir, fmt.Sprintf("%s.%s", file, Extension))
 	absolutePathWithExtension := filepath.Join(d.Path, relativePathWithExtension)
 	bytes, err := os.ReadFile(absolutePathWithExtension)
 	if err != nil {
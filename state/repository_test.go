@@ -0,0 +1,50 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskRepositoryListSubnets(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name: "no subnets directory",
+		},
+		{
+			name:  "definitions",
+			files: []string{"subnets/a.yaml", "subnets/b.yaml", "vms/c.yaml"},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "ignores other files",
+			files: []string{"subnets/a.yaml", "subnets/README.md", "subnets/nested/b.yaml"},
+			want:  []string{"a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := t.TempDir()
+			for _, file := range test.files {
+				file = filepath.Join(path, file)
+				require.NoError(t, os.MkdirAll(filepath.Dir(file), perms.ReadWriteExecute))
+				require.NoError(t, os.WriteFile(file, nil, perms.ReadWrite))
+			}
+
+			got, err := DiskRepository{Path: path}.ListSubnets()
+			require.NoError(t, err)
+			assert.ElementsMatch(t, test.want, got)
+		})
+	}
+}
@@ -4,6 +4,8 @@
 package state
 
 import (
+	"time"
+
 	"github.com/go-git/go-git/v5/plumbing"
 
 	"github.com/ava-labs/apm/types"
@@ -14,11 +16,64 @@ type SourceInfo struct {
 	URL    string                 `yaml:"url"`
 	Commit string                 `yaml:"commit"`
 	Branch plumbing.ReferenceName `yaml:"branch"`
+	// LastUpdated is when the repository was last successfully updated.
+	LastUpdated time.Time `yaml:"last-updated,omitempty"`
 }
 
+// InstallReason is why a VM was installed.
+type InstallReason string
+
+const (
+	// ExplicitInstall VMs were installed directly by the user.
+	ExplicitInstall InstallReason = "explicit"
+	// DependencyInstall VMs were installed because something else required
+	// them.
+	DependencyInstall InstallReason = "dependency"
+)
+
 type InstallInfo struct {
 	ID     string `yaml:"id"`
 	Commit string `yaml:"commit"`
+	// Reason is why this VM was installed. VMs installed before reasons were
+	// recorded are treated as explicitly installed.
+	Reason InstallReason `yaml:"reason,omitempty"`
+	// RequiredBy are the fully qualified names of the joined subnets and
+	// installed VMs that require this VM.
+	RequiredBy []string `yaml:"required-by,omitempty"`
+	// SHA256 is the checksum of the binary in the plugin directory.
+	SHA256 string `yaml:"sha256,omitempty"`
+	// Size is the size of the binary in the plugin directory in bytes.
+	Size int64 `yaml:"size,omitempty"`
+	// PreUninstall and PostUninstall are the uninstall hooks of the definition
+	// this VM was installed from, so they can run even if the definition has
+	// since changed or been removed.
+	PreUninstall  string `yaml:"pre-uninstall,omitempty"`
+	PostUninstall string `yaml:"post-uninstall,omitempty"`
+}
+
+// IsExplicit returns true if this VM was installed directly by the user.
+func (i InstallInfo) IsExplicit() bool {
+	return i.Reason != DependencyInstall
+}
+
+// IsRequiredBy returns true if name requires this VM.
+func (i InstallInfo) IsRequiredBy(name string) bool {
+	for _, requiredBy := range i.RequiredBy {
+		if requiredBy == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SubnetInfo represents a joined subnet.
+type SubnetInfo struct {
+	ID      string `yaml:"id"`
+	Network string `yaml:"network"`
+	// Chains are the ids of the chains whose configs were written when the
+	// subnet was joined.
+	Chains []string `yaml:"chains,omitempty"`
 }
 
 // Definition stores a plugin definition alongside the plugin-repository's commit
@@ -20,6 +20,8 @@ func newEmpty(path string) File {
 	return File{
 		Sources:              make(map[string]*SourceInfo),
 		InstallationRegistry: make(map[string]*InstallInfo),
+		Subnets:              make(map[string]*SubnetInfo),
+		Profiles:             make(map[string]*Profile),
 		path:                 filepath.Join(path, stateFile),
 	}
 }
@@ -49,11 +51,55 @@ type File struct {
 	Sources map[string]*SourceInfo `yaml:"sources"`
 	// Mapping of each installed vm's alias to the version installed
 	InstallationRegistry map[string]*InstallInfo `yaml:"installation-registry"`
+	// Mapping of each joined subnet's alias to the subnet joined
+	Subnets map[string]*SubnetInfo `yaml:"subnets"`
+	// Mapping of each profile's name to the plugins installed on its node
+	Profiles map[string]*Profile `yaml:"profiles,omitempty"`
 
 	path string
+	// root is the file this profile's view was created from, if any.
+	root *File
+}
+
+// Profile is the state of a node managed under a named profile.
+type Profile struct {
+	InstallationRegistry map[string]*InstallInfo `yaml:"installation-registry"`
+	Subnets              map[string]*SubnetInfo  `yaml:"subnets"`
+}
+
+// ForProfile returns a view of the file where the installation registry and
+// joined subnets are those of the named profile. Repositories are shared by
+// all profiles. The default profile ("") is the top level of the file.
+func (s File) ForProfile(name string) File {
+	if name == "" {
+		return s
+	}
+
+	profile, ok := s.Profiles[name]
+	if !ok {
+		profile = &Profile{}
+		s.Profiles[name] = profile
+	}
+	if profile.InstallationRegistry == nil {
+		profile.InstallationRegistry = make(map[string]*InstallInfo)
+	}
+	if profile.Subnets == nil {
+		profile.Subnets = make(map[string]*SubnetInfo)
+	}
+
+	root := s
+	result := s
+	result.InstallationRegistry = profile.InstallationRegistry
+	result.Subnets = profile.Subnets
+	result.root = &root
+	return result
 }
 
 func (s *File) Commit() error {
+	if s.root != nil {
+		return s.root.Commit()
+	}
+
 	bytes, err := yaml.Marshal(s)
 	if err != nil {
 		return err
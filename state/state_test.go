@@ -0,0 +1,67 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForProfile(t *testing.T) {
+	root, err := New(t.TempDir())
+	require.NoError(t, err)
+	root.Sources["organization/repository"] = &SourceInfo{}
+	root.InstallationRegistry["organization/repository:root"] = &InstallInfo{}
+
+	staging := root.ForProfile("staging")
+	production := root.ForProfile("production")
+	staging.InstallationRegistry["organization/repository:staging"] = &InstallInfo{}
+	staging.Subnets["organization/repository:subnet"] = &SubnetInfo{}
+
+	// repositories are shared
+	assert.Equal(t, root.Sources, staging.Sources)
+	assert.Equal(t, root.Sources, production.Sources)
+
+	// installed plugins and subnets aren't
+	assert.Equal(t, []string{"organization/repository:root"}, keys(root.InstallationRegistry))
+	assert.Equal(t, []string{"organization/repository:staging"}, keys(staging.InstallationRegistry))
+	assert.Empty(t, production.InstallationRegistry)
+	assert.Empty(t, root.Subnets)
+	assert.Empty(t, production.Subnets)
+
+	// views of the same profile share state
+	assert.Equal(t, staging.InstallationRegistry, root.ForProfile("staging").InstallationRegistry)
+
+	// the default profile is the root
+	assert.Equal(t, root.InstallationRegistry, root.ForProfile("").InstallationRegistry)
+}
+
+func TestForProfileCommit(t *testing.T) {
+	dir := t.TempDir()
+	root, err := New(dir)
+	require.NoError(t, err)
+	root.InstallationRegistry["organization/repository:root"] = &InstallInfo{}
+
+	staging := root.ForProfile("staging")
+	staging.InstallationRegistry["organization/repository:staging"] = &InstallInfo{}
+	require.NoError(t, staging.Commit())
+
+	// committing a view writes the whole file
+	committed, err := New(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"organization/repository:root"}, keys(committed.InstallationRegistry))
+	require.Contains(t, committed.Profiles, "staging")
+	assert.Equal(t, []string{"organization/repository:staging"}, keys(committed.Profiles["staging"].InstallationRegistry))
+	assert.Equal(t, []string{"organization/repository:staging"}, keys(committed.ForProfile("staging").InstallationRegistry))
+}
+
+func keys[T any](m map[string]T) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	return result
+}
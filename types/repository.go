@@ -0,0 +1,21 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package types
+
+// SchemaVersion is the newest plugin repository layout this apm understands.
+const SchemaVersion = 1
+
+// Repository describes a plugin repository. It's stored in the repository.yaml
+// at the root of the repository.
+type Repository struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Maintainers []string `yaml:"maintainers"`
+	// SchemaVersion is the version of the layout the repository's definitions
+	// follow.
+	SchemaVersion int `yaml:"schemaVersion"`
+	// SigningKeys are the public keys the repository's maintainers sign
+	// artifacts with.
+	SigningKeys []string `yaml:"signingKeys,omitempty"`
+}
@@ -3,6 +3,15 @@
 
 package types
 
+import (
+	"sort"
+	"strconv"
+
+	"github.com/ava-labs/avalanchego/utils/constants"
+
+	"github.com/ava-labs/apm/constant"
+)
+
 var _ Definition = &Subnet{}
 
 type Subnet struct {
@@ -11,13 +20,83 @@ type Subnet struct {
 	Homepage    string            `yaml:"homepage"`
 	Description string            `yaml:"description"`
 	Maintainers []string          `yaml:"maintainers"`
-	VMs         []string          `yaml:"vms"`
-	// Config      subnets.SubnetConfig `yaml:"config,omitempty"`
+	// VMs are the virtual machines required by this subnet. These can either
+	// be aliases in the same repository or fully qualified names.
+	VMs []string `yaml:"vms"`
+	// Compatibility describes which avalanchego nodes can validate the subnet.
+	Compatibility SubnetCompatibility `yaml:"compatibility,omitempty"`
+	// Config is written to the node's subnet config file when the subnet is
+	// joined.
+	Config map[string]interface{} `yaml:"config,omitempty"`
+	// Chains are the blockchains in this subnet that need to be configured.
+	Chains []Chain `yaml:"chains,omitempty"`
+}
+
+// SubnetCompatibility describes which avalanchego nodes are able to validate
+// a subnet. It has the same shape as a VM's Compatibility, without the fields
+// that only apply to VM binaries.
+type SubnetCompatibility struct {
+	// AvalancheGo is a version constraint on the avalanchego node
+	// (e.g ">=v1.7.14, <v1.8.0").
+	AvalancheGo string `yaml:"avalancheGo,omitempty"`
 }
 
+// Chain is a blockchain validated by a subnet.
+type Chain struct {
+	ID    map[string]string `yaml:"id"`
+	Alias string            `yaml:"alias"`
+	// Config is written to the chain's config.json.
+	Config map[string]interface{} `yaml:"config,omitempty"`
+	// Upgrade is written to the chain's upgrade.json.
+	Upgrade map[string]interface{} `yaml:"upgrade,omitempty"`
+}
+
+// GetID returns the chain's id on the provided network.
+func (c Chain) GetID(network string) (string, bool) {
+	return getID(c.ID, network)
+}
+
+// networkAliases are other names a network's subnet id may be keyed by.
+var networkAliases = map[string][]string{
+	constant.Fuji: {"testnet"},
+	"testnet":     {constant.Fuji},
+}
+
+// GetID returns the subnet's id on the provided network.
 func (s Subnet) GetID(network string) (string, bool) {
-	id, ok := s.ID[network]
-	return id, ok
+	return getID(s.ID, network)
+}
+
+func getID(ids map[string]string, network string) (string, bool) {
+	if id, ok := ids[network]; ok {
+		return id, ok
+	}
+
+	for _, alias := range networkAliases[network] {
+		if id, ok := ids[alias]; ok {
+			return id, ok
+		}
+	}
+
+	// Custom networks may be keyed by their network id.
+	if networkID, err := constants.NetworkID(network); err == nil {
+		if id, ok := ids[strconv.FormatUint(uint64(networkID), 10)]; ok {
+			return id, ok
+		}
+	}
+
+	return "", false
+}
+
+// Networks returns the names of the networks the subnet is available on.
+func (s Subnet) Networks() []string {
+	result := make([]string, 0, len(s.ID))
+	for network := range s.ID {
+		result = append(result, network)
+	}
+
+	sort.Strings(result)
+	return result
 }
 
 func (s Subnet) GetAlias() string {
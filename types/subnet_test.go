@@ -0,0 +1,67 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubnetGetID(t *testing.T) {
+	subnet := Subnet{
+		ID: map[string]string{
+			"mainnet": "mainnetID",
+			"testnet": "fujiID",
+			"1337":    "customID",
+		},
+	}
+
+	tests := []struct {
+		network string
+		want    string
+		wantOk  bool
+	}{
+		{network: "mainnet", want: "mainnetID", wantOk: true},
+		{network: "fuji", want: "fujiID", wantOk: true},
+		{network: "network-1337", want: "customID", wantOk: true},
+		{network: "local"},
+		{network: "network-1338"},
+		{network: "unknown"},
+	}
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			got, ok := subnet.GetID(test.network)
+			assert.Equal(t, test.wantOk, ok)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestSubnetNetworks(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  map[string]string
+		want []string
+	}{
+		{
+			name: "none",
+			want: []string{},
+		},
+		{
+			name: "sorted",
+			ids: map[string]string{
+				"mainnet": "mainnetID",
+				"fuji":    "fujiID",
+				"1337":    "customID",
+			},
+			want: []string{"1337", "fuji", "mainnet"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, Subnet{ID: test.ids}.Networks())
+		})
+	}
+}
@@ -15,6 +15,29 @@ type VM struct {
 	BinaryPath    string   `yaml:"binaryPath"`
 	URL           string   `yaml:"url"`
 	SHA256        string   `yaml:"sha256"`
+	// Dependencies are other virtual machines required by this one. These can
+	// either be aliases in the same repository or fully qualified names.
+	Dependencies  []string      `yaml:"dependencies,omitempty"`
+	Compatibility Compatibility `yaml:"compatibility,omitempty"`
+	// PreInstall and PostInstall are commands run with sh -c in the unpacked
+	// archive before the install script and after the binary has been moved
+	// into the plugin directory. They're also run on upgrades.
+	PreInstall  string `yaml:"preInstall,omitempty"`
+	PostInstall string `yaml:"postInstall,omitempty"`
+	// PreUninstall and PostUninstall are commands run with sh -c in the
+	// plugin directory before and after the binary is removed.
+	PreUninstall  string `yaml:"preUninstall,omitempty"`
+	PostUninstall string `yaml:"postUninstall,omitempty"`
+}
+
+// Compatibility describes which avalanchego nodes are able to run a VM.
+type Compatibility struct {
+	// RPCChainVMProtocol is the rpcchainvm protocol version the VM binary
+	// speaks. Zero if unspecified.
+	RPCChainVMProtocol uint `yaml:"rpcChainVMProtocol,omitempty"`
+	// AvalancheGo is a version constraint on the avalanchego node
+	// (e.g ">=v1.7.14, <v1.8.0").
+	AvalancheGo string `yaml:"avalancheGo,omitempty"`
 }
 
 func (vm VM) GetID() string {
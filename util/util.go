@@ -4,8 +4,12 @@
 package util
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/ava-labs/avalanchego/utils/constants"
+
 	"github.com/ava-labs/apm/constant"
 )
 
@@ -28,3 +32,37 @@ func ValidAlias(alias string) bool {
 
 	return true
 }
+
+// QualifyName returns name as a fully qualified name. Names that aren't
+// already qualified are assumed to belong to the repository with the provided
+// alias.
+func QualifyName(alias string, name string) string {
+	if strings.Contains(name, constant.QualifiedNameDelimiter) {
+		return name
+	}
+
+	return strings.Join([]string{alias, name}, constant.QualifiedNameDelimiter)
+}
+
+// ParseNetwork returns the canonical name of a network. Networks can be
+// referred to by name (e.g mainnet, fuji, local), by id (e.g 1337) or as
+// network-<id>. Custom networks are named network-<id>.
+func ParseNetwork(network string) (string, error) {
+	id, err := constants.NetworkID(network)
+	if err != nil {
+		return "", fmt.Errorf("unknown network %s (must be %s, %s, %s or a network id): %w", network, constant.Mainnet, constant.Fuji, constant.Local, err)
+	}
+
+	return constants.NetworkName(id), nil
+}
+
+// SortedKeys returns the keys of m in sorted order.
+func SortedKeys[T any](m map[string]T) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,45 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		network string
+		want    string
+		wantErr bool
+	}{
+		{network: "mainnet", want: "mainnet"},
+		{network: "Fuji", want: "fuji"},
+		{network: "testnet", want: "fuji"},
+		{network: "local", want: "local"},
+		{network: "1", want: "mainnet"},
+		{network: "1337", want: "network-1337"},
+		{network: "network-1337", want: "network-1337"},
+		{network: "unknown", wantErr: true},
+		{network: "", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			got, err := ParseNetwork(test.network)
+			if test.wantErr {
+				assert.ErrorContains(t, err, "unknown network "+test.network)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, SortedKeys(map[string]int{"c": 3, "a": 1, "b": 2}))
+	assert.Empty(t, SortedKeys(map[string]int{}))
+}
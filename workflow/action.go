@@ -0,0 +1,42 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import "fmt"
+
+type ActionType string
+
+const (
+	DownloadAction    ActionType = "download"
+	RunScriptAction   ActionType = "run-script"
+	ReplaceFileAction ActionType = "replace-file"
+	DeleteFileAction  ActionType = "delete-file"
+	StateChangeAction ActionType = "state-change"
+	AdminAPIAction    ActionType = "admin-api"
+	NodeConfigAction  ActionType = "node-config"
+	RestartAction     ActionType = "restart"
+)
+
+// Action is a single side effect of a workflow.
+type Action struct {
+	Type ActionType `json:"type"`
+	// Name is the fully qualified name of the vm or subnet this action is for.
+	Name string `json:"name"`
+	// URL is the url an artifact is downloaded from.
+	URL string `json:"url,omitempty"`
+	// SHA256 is the expected checksum of a downloaded artifact.
+	SHA256 string `json:"sha256,omitempty"`
+	// Command is a script that is run.
+	Command string `json:"command,omitempty"`
+	// Path is the file or directory that is affected.
+	Path string `json:"path,omitempty"`
+	// Method is the admin api method that is called.
+	Method string `json:"method,omitempty"`
+	// Description is a human-readable summary of the action.
+	Description string `json:"description"`
+}
+
+func (a Action) String() string {
+	return fmt.Sprintf("[%s] %s: %s", a.Type, a.Name, a.Description)
+}
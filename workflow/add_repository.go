@@ -4,21 +4,40 @@
 package workflow
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/spf13/afero"
 
+	"github.com/ava-labs/apm/git"
 	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
 )
 
-var _ Workflow = AddRepository{}
+var (
+	_ Workflow = AddRepository{}
+	_ Named    = AddRepository{}
+
+	ErrNotPluginRepository      = errors.New("not a plugin repository")
+	ErrUnsupportedSchemaVersion = errors.New("unsupported repository schema version")
+)
 
 func NewAddRepository(config AddRepositoryConfig) *AddRepository {
 	return &AddRepository{
-		sourcesList: config.SourcesList,
-		alias:       config.Alias,
-		url:         config.URL,
-		branch:      config.Branch,
+		sourcesList:      config.SourcesList,
+		alias:            config.Alias,
+		url:              config.URL,
+		branch:           config.Branch,
+		repositoriesPath: config.RepositoriesPath,
+		auth:             config.Auth,
+		git:              config.Git,
+		repoFactory:      config.RepoFactory,
+		fs:               config.Fs,
 	}
 }
 
@@ -26,12 +45,31 @@ type AddRepositoryConfig struct {
 	SourcesList map[string]*state.SourceInfo
 	Alias, URL  string
 	Branch      plumbing.ReferenceName
+
+	// The repository is cloned into RepositoriesPath and validated before it's
+	// tracked.
+	RepositoriesPath string
+	Auth             http.BasicAuth
+	Git              git.Factory
+	RepoFactory      state.RepositoryFactory
+	Fs               afero.Fs
 }
 
 type AddRepository struct {
 	sourcesList map[string]*state.SourceInfo
 	alias, url  string
 	branch      plumbing.ReferenceName
+
+	repositoriesPath string
+	auth             http.BasicAuth
+	git              git.Factory
+	repoFactory      state.RepositoryFactory
+	fs               afero.Fs
+}
+
+// Name returns the alias of the repository.
+func (a AddRepository) Name() string {
+	return a.alias
 }
 
 func (a AddRepository) Execute() error {
@@ -39,12 +77,86 @@ func (a AddRepository) Execute() error {
 		return fmt.Errorf("%s is already registered as a repository", a.alias)
 	}
 
-	unsynced := &state.SourceInfo{
-		URL:    a.url,
-		Branch: a.branch,
-		Commit: plumbing.ZeroHash.String(), // hasn't been synced yet
+	repositoryPath := filepath.Join(a.repositoriesPath, a.alias)
+	// A leftover clone is pulled instead of cloned, so it isn't ours to remove.
+	existed, err := afero.Exists(a.fs, repositoryPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cloning %s...\n", a.url)
+	commit, err := a.git.GetRepository(a.url, repositoryPath, a.branch, &a.auth)
+	if err == nil {
+		err = a.validate()
+	}
+	if err != nil {
+		// Don't leave a clone behind for a repository we're not tracking.
+		if !existed {
+			if err := a.fs.RemoveAll(repositoryPath); err != nil {
+				fmt.Printf("Warning - failed to clean up %s: %s\n", repositoryPath, err)
+			}
+		}
+
+		return fmt.Errorf("failed to add %s: %w", a.alias, err)
+	}
+
+	a.sourcesList[a.alias] = &state.SourceInfo{
+		URL:         a.url,
+		Branch:      a.branch,
+		Commit:      commit,
+		LastUpdated: time.Now(),
+	}
+
+	fmt.Printf("Successfully added %s@%s.\n", a.alias, commit)
+	return nil
+}
+
+// validate checks that the cloned repository is a plugin repository this apm
+// understands.
+func (a AddRepository) validate() error {
+	repository, err := a.repoFactory.GetRepository(a.alias)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := repository.GetMetadata()
+	if errors.Is(err, os.ErrNotExist) {
+		// Repositories from before the metadata file was introduced are
+		// accepted as long as they have definitions.
+		return a.validateLegacy(repository)
+	} else if err != nil {
+		return fmt.Errorf("failed to parse the repository metadata: %w", err)
+	}
+
+	if metadata.SchemaVersion < 1 || metadata.SchemaVersion > types.SchemaVersion {
+		return fmt.Errorf(
+			"%w: %s uses schema version %d but this apm supports versions 1 to %d",
+			ErrUnsupportedSchemaVersion, a.alias, metadata.SchemaVersion, types.SchemaVersion,
+		)
+	}
+
+	if metadata.Name != "" {
+		fmt.Printf("Found %s (schema version %d): %s\n", metadata.Name, metadata.SchemaVersion, metadata.Description)
+	}
+
+	return nil
+}
+
+func (a AddRepository) validateLegacy(repository state.Repository) error {
+	vms, err := repository.ListVMs()
+	if err != nil {
+		return err
+	}
+
+	subnets, err := repository.ListSubnets()
+	if err != nil {
+		return err
+	}
+
+	if len(vms) == 0 && len(subnets) == 0 {
+		return fmt.Errorf("%w: %s has no repository.yaml, vms or subnets", ErrNotPluginRepository, a.url)
 	}
 
-	a.sourcesList[a.alias] = unsynced
+	fmt.Printf("Warning - %s has no repository.yaml. Assuming schema version 1.\n", a.alias)
 	return nil
 }
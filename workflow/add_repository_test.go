@@ -4,59 +4,215 @@
 package workflow
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/ava-labs/apm/git"
 	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
 )
 
 func TestAddRepositoryExecute(t *testing.T) {
+	const (
+		alias  = "organization/repository"
+		url    = "url"
+		commit = "commit"
+	)
+	errWrong := errors.New("something went wrong")
+
 	type mocks struct {
-		sourcesList map[string]*state.SourceInfo
+		sourcesList    map[string]*state.SourceInfo
+		repositoryPath string
+		git            *git.MockFactory
+		repoFactory    *state.MockRepositoryFactory
+		repository     *state.MockRepository
+		fs             afero.Fs
+	}
+	// clone pretends to clone the repository.
+	clone := func(fs afero.Fs, commit string, err error) func(string, string, plumbing.ReferenceName, *http.BasicAuth) (string, error) {
+		return func(_ string, path string, _ plumbing.ReferenceName, _ *http.BasicAuth) (string, error) {
+			if err := fs.MkdirAll(path, perms.ReadWriteExecute); err != nil {
+				return "", err
+			}
+			return commit, err
+		}
 	}
 	tests := []struct {
 		name    string
 		setup   func(mocks)
 		wantErr assert.ErrorAssertionFunc
+		// added is whether the repository should be tracked afterwards.
+		added bool
+		// onDisk is whether the repository should be on disk afterwards.
+		onDisk bool
 	}{
 		{
 			name: "already exists",
 			setup: func(mocks mocks) {
-				mocks.sourcesList["alias"] = nil
+				mocks.sourcesList[alias] = nil
 			},
 			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
 				return assert.Error(t, err)
 			},
+			added: true,
+		},
+		{
+			name: "rejected leftover clone",
+			setup: func(mocks mocks) {
+				// a clone that was left behind is pulled, not cloned
+				require.NoError(t, mocks.fs.MkdirAll(mocks.repositoryPath, perms.ReadWriteExecute))
+				mocks.git.EXPECT().GetRepository(url, mocks.repositoryPath, gomock.Any(), gomock.Any()).Return(commit, nil)
+				mocks.repoFactory.EXPECT().GetRepository(alias).Return(mocks.repository, nil)
+				mocks.repository.EXPECT().GetMetadata().Return(types.Repository{}, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, errWrong)
+			},
+			onDisk: true,
+		},
+		{
+			name: "clone fails",
+			setup: func(mocks mocks) {
+				mocks.git.EXPECT().GetRepository(url, mocks.repositoryPath, gomock.Any(), gomock.Any()).DoAndReturn(clone(mocks.fs, "", errWrong))
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, errWrong)
+			},
+		},
+		{
+			name: "unsupported schema version",
+			setup: func(mocks mocks) {
+				mocks.git.EXPECT().GetRepository(url, mocks.repositoryPath, gomock.Any(), gomock.Any()).DoAndReturn(clone(mocks.fs, commit, nil))
+				mocks.repoFactory.EXPECT().GetRepository(alias).Return(mocks.repository, nil)
+				mocks.repository.EXPECT().GetMetadata().Return(types.Repository{SchemaVersion: types.SchemaVersion + 1}, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrUnsupportedSchemaVersion)
+			},
+		},
+		{
+			name: "missing schema version",
+			setup: func(mocks mocks) {
+				mocks.git.EXPECT().GetRepository(url, mocks.repositoryPath, gomock.Any(), gomock.Any()).DoAndReturn(clone(mocks.fs, commit, nil))
+				mocks.repoFactory.EXPECT().GetRepository(alias).Return(mocks.repository, nil)
+				mocks.repository.EXPECT().GetMetadata().Return(types.Repository{Name: "name"}, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrUnsupportedSchemaVersion)
+			},
+		},
+		{
+			name: "malformed metadata",
+			setup: func(mocks mocks) {
+				mocks.git.EXPECT().GetRepository(url, mocks.repositoryPath, gomock.Any(), gomock.Any()).DoAndReturn(clone(mocks.fs, commit, nil))
+				mocks.repoFactory.EXPECT().GetRepository(alias).Return(mocks.repository, nil)
+				mocks.repository.EXPECT().GetMetadata().Return(types.Repository{}, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, errWrong)
+			},
+		},
+		{
+			name: "not a plugin repository",
+			setup: func(mocks mocks) {
+				mocks.git.EXPECT().GetRepository(url, mocks.repositoryPath, gomock.Any(), gomock.Any()).DoAndReturn(clone(mocks.fs, commit, nil))
+				mocks.repoFactory.EXPECT().GetRepository(alias).Return(mocks.repository, nil)
+				mocks.repository.EXPECT().GetMetadata().Return(types.Repository{}, fmt.Errorf("open: %w", os.ErrNotExist))
+				mocks.repository.EXPECT().ListVMs().Return(nil, nil)
+				mocks.repository.EXPECT().ListSubnets().Return(nil, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrNotPluginRepository)
+			},
+		},
+		{
+			name: "repository without metadata",
+			setup: func(mocks mocks) {
+				mocks.git.EXPECT().GetRepository(url, mocks.repositoryPath, gomock.Any(), gomock.Any()).DoAndReturn(clone(mocks.fs, commit, nil))
+				mocks.repoFactory.EXPECT().GetRepository(alias).Return(mocks.repository, nil)
+				mocks.repository.EXPECT().GetMetadata().Return(types.Repository{}, fmt.Errorf("open: %w", os.ErrNotExist))
+				mocks.repository.EXPECT().ListVMs().Return([]string{"vm"}, nil)
+				mocks.repository.EXPECT().ListSubnets().Return(nil, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Nil(t, err)
+			},
+			added:  true,
+			onDisk: true,
 		},
 		{
 			name: "success",
 			setup: func(mocks mocks) {
+				mocks.git.EXPECT().GetRepository(url, mocks.repositoryPath, gomock.Any(), gomock.Any()).DoAndReturn(clone(mocks.fs, commit, nil))
+				mocks.repoFactory.EXPECT().GetRepository(alias).Return(mocks.repository, nil)
+				mocks.repository.EXPECT().GetMetadata().Return(types.Repository{
+					Name:          "name",
+					Description:   "description",
+					SchemaVersion: types.SchemaVersion,
+				}, nil)
 			},
 			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
 				return assert.Nil(t, err)
 			},
+			added:  true,
+			onDisk: true,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			fs := afero.NewMemMapFs()
+			repositoriesPath := "repositories"
+			repositoryPath := filepath.Join(repositoriesPath, alias)
+
 			sourcesList := make(map[string]*state.SourceInfo)
+			gitFactory := git.NewMockFactory(ctrl)
+			repoFactory := state.NewMockRepositoryFactory(ctrl)
 
 			test.setup(mocks{
-				sourcesList: sourcesList,
+				sourcesList:    sourcesList,
+				repositoryPath: repositoryPath,
+				git:            gitFactory,
+				repoFactory:    repoFactory,
+				repository:     state.NewMockRepository(ctrl),
+				fs:             fs,
 			})
 
 			wf := NewAddRepository(
 				AddRepositoryConfig{
-					SourcesList: sourcesList,
-					Alias:       "alias",
-					URL:         "url",
-					Branch:      "master",
+					SourcesList:      sourcesList,
+					Alias:            alias,
+					URL:              url,
+					Branch:           "master",
+					RepositoriesPath: repositoriesPath,
+					Git:              gitFactory,
+					RepoFactory:      repoFactory,
+					Fs:               fs,
 				},
 			)
 
 			test.wantErr(t, wf.Execute())
+
+			_, ok := sourcesList[alias]
+			assert.Equal(t, test.added, ok)
+
+			// rejected clones aren't left on disk
+			exists, err := afero.Exists(fs, repositoryPath)
+			require.NoError(t, err)
+			assert.Equal(t, test.onDisk, exists)
 		})
 	}
 }
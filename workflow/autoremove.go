@@ -0,0 +1,148 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/util"
+)
+
+var (
+	_ Workflow = &Autoremove{}
+	_ Planner  = &Autoremove{}
+)
+
+type AutoremoveConfig struct {
+	Executor   Executor
+	StateFile  state.File
+	Fs         afero.Fs
+	PluginPath string
+	// Confirm is called with the VMs that will be uninstalled before anything
+	// is removed. Nothing is removed if it returns false. If nil, the VMs are
+	// removed without confirmation.
+	Confirm func(names []string) (bool, error)
+}
+
+func NewAutoremove(config AutoremoveConfig) *Autoremove {
+	return &Autoremove{
+		executor:   config.Executor,
+		stateFile:  config.StateFile,
+		fs:         config.Fs,
+		pluginPath: config.PluginPath,
+		confirm:    config.Confirm,
+	}
+}
+
+// Autoremove uninstalls VMs that were installed as dependencies but are no
+// longer required by any joined subnet or installed VM.
+type Autoremove struct {
+	executor   Executor
+	stateFile  state.File
+	fs         afero.Fs
+	pluginPath string
+	confirm    func(names []string) (bool, error)
+}
+
+func (a *Autoremove) Execute() error {
+	unneeded := orphans(a.stateFile, "")
+	if len(unneeded) == 0 {
+		fmt.Printf("No virtual machines to autoremove.\n")
+		return nil
+	}
+
+	fmt.Printf("The following virtual machines are no longer required and will be uninstalled: %s.\n", strings.Join(unneeded, ", "))
+	if a.confirm != nil {
+		ok, err := a.confirm(unneeded)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Printf("Aborted.\n")
+			return nil
+		}
+	}
+
+	for _, name := range unneeded {
+		if err := a.executor.Execute(newUninstall(name, a.stateFile, a.fs, a.pluginPath)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *Autoremove) Plan() ([]Action, error) {
+	for _, name := range orphans(a.stateFile, "") {
+		if err := a.executor.Execute(newUninstall(name, a.stateFile, a.fs, a.pluginPath)); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// orphans returns the VMs installed as dependencies that nothing requires
+// anymore, in a deterministic order. A VM is only required by joined subnets
+// and by installed VMs that aren't orphans themselves. If leaving is set, that
+// subnet is treated as if it had already been left.
+func orphans(stateFile state.File, leaving string) []string {
+	orphaned := make(map[string]bool)
+
+	required := func(installInfo *state.InstallInfo) bool {
+		for _, requiredBy := range installInfo.RequiredBy {
+			if requiredBy == leaving {
+				continue
+			}
+			if _, ok := stateFile.Subnets[requiredBy]; ok {
+				return true
+			}
+			if _, ok := stateFile.InstallationRegistry[requiredBy]; ok && !orphaned[requiredBy] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	// Removing a VM can orphan its own dependencies, so keep going until
+	// nothing changes.
+	for changed := true; changed; {
+		changed = false
+		for name, installInfo := range stateFile.InstallationRegistry {
+			if orphaned[name] || installInfo.IsExplicit() || required(installInfo) {
+				continue
+			}
+
+			orphaned[name] = true
+			changed = true
+		}
+	}
+
+	result := make([]string, 0, len(orphaned))
+	for name := range orphaned {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+func newUninstall(name string, stateFile state.File, fs afero.Fs, pluginPath string) *Uninstall {
+	repoAlias, plugin := util.ParseQualifiedName(name)
+
+	return NewUninstall(UninstallConfig{
+		Name:       name,
+		Plugin:     plugin,
+		RepoAlias:  repoAlias,
+		StateFile:  stateFile,
+		Fs:         fs,
+		PluginPath: pluginPath,
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/state"
+)
+
+func TestOrphans(t *testing.T) {
+	const (
+		subnet = "organization/repository:subnet"
+		vm     = "organization/repository:vm"
+		lib    = "organization/repository:lib"
+		other  = "organization/repository:other"
+	)
+
+	tests := []struct {
+		name    string
+		setup   func(state.File)
+		leaving string
+		want    []string
+	}{
+		{
+			name: "explicit vms are never orphans",
+			setup: func(stateFile state.File) {
+				stateFile.InstallationRegistry[vm] = &state.InstallInfo{Reason: state.ExplicitInstall}
+				stateFile.InstallationRegistry[other] = &state.InstallInfo{}
+			},
+			want: []string{},
+		},
+		{
+			name: "required by a joined subnet",
+			setup: func(stateFile state.File) {
+				stateFile.Subnets[subnet] = &state.SubnetInfo{}
+				stateFile.InstallationRegistry[vm] = &state.InstallInfo{
+					Reason:     state.DependencyInstall,
+					RequiredBy: []string{subnet},
+				}
+			},
+			want: []string{},
+		},
+		{
+			name: "leaving the only subnet",
+			setup: func(stateFile state.File) {
+				stateFile.Subnets[subnet] = &state.SubnetInfo{}
+				stateFile.InstallationRegistry[vm] = &state.InstallInfo{
+					Reason:     state.DependencyInstall,
+					RequiredBy: []string{subnet},
+				}
+			},
+			leaving: subnet,
+			want:    []string{vm},
+		},
+		{
+			name: "required by an explicit vm",
+			setup: func(stateFile state.File) {
+				stateFile.InstallationRegistry[vm] = &state.InstallInfo{Reason: state.ExplicitInstall}
+				stateFile.InstallationRegistry[lib] = &state.InstallInfo{
+					Reason:     state.DependencyInstall,
+					RequiredBy: []string{vm},
+				}
+			},
+			want: []string{},
+		},
+		{
+			name: "required by an uninstalled vm",
+			setup: func(stateFile state.File) {
+				stateFile.InstallationRegistry[lib] = &state.InstallInfo{
+					Reason:     state.DependencyInstall,
+					RequiredBy: []string{vm},
+				}
+			},
+			want: []string{lib},
+		},
+		{
+			name: "dependencies of orphans are orphans",
+			setup: func(stateFile state.File) {
+				stateFile.InstallationRegistry[vm] = &state.InstallInfo{
+					Reason:     state.DependencyInstall,
+					RequiredBy: []string{subnet},
+				}
+				stateFile.InstallationRegistry[lib] = &state.InstallInfo{
+					Reason:     state.DependencyInstall,
+					RequiredBy: []string{vm},
+				}
+			},
+			want: []string{lib, vm},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+
+			test.setup(stateFile)
+
+			assert.Equal(t, test.want, orphans(stateFile, test.leaving))
+		})
+	}
+}
+
+func TestAutoremoveExecute(t *testing.T) {
+	const vm = "organization/repository:vm"
+
+	errWrong := fmt.Errorf("something went wrong")
+
+	type mocks struct {
+		executor *MockExecutor
+	}
+	tests := []struct {
+		name    string
+		setup   func(mocks)
+		confirm func([]string) (bool, error)
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "confirm fails",
+			setup: func(mocks mocks) {},
+			confirm: func([]string) (bool, error) {
+				return false, errWrong
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name:  "not confirmed",
+			setup: func(mocks mocks) {},
+			confirm: func([]string) (bool, error) {
+				return false, nil
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.NoError(t, err)
+			},
+		},
+		{
+			name: "uninstall fails",
+			setup: func(mocks mocks) {
+				mocks.executor.EXPECT().Execute(gomock.Any()).Return(errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "success",
+			setup: func(mocks mocks) {
+				mocks.executor.EXPECT().Execute(gomock.Any()).DoAndReturn(func(wf Workflow) error {
+					uninstall, ok := wf.(*Uninstall)
+					require.True(t, ok)
+					assert.Equal(t, vm, uninstall.name)
+					return nil
+				})
+			},
+			confirm: func(names []string) (bool, error) {
+				return true, nil
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.NoError(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			executor := NewMockExecutor(ctrl)
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+
+			stateFile.InstallationRegistry[vm] = &state.InstallInfo{
+				Reason: state.DependencyInstall,
+			}
+
+			test.setup(mocks{
+				executor: executor,
+			})
+
+			wf := NewAutoremove(AutoremoveConfig{
+				Executor:   executor,
+				StateFile:  stateFile,
+				Fs:         afero.NewMemMapFs(),
+				PluginPath: "pluginPath",
+				Confirm:    test.confirm,
+			})
+
+			test.wantErr(t, wf.Execute())
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/version"
+
+	"github.com/ava-labs/apm/admin"
+	"github.com/ava-labs/apm/constraint"
+	"github.com/ava-labs/apm/types"
+)
+
+var ErrIncompatible = errors.New("incompatible with avalanchego")
+
+// CompatibilityChecker checks whether definitions can run on the node apm is
+// managing plugins for.
+type CompatibilityChecker interface {
+	// CheckVM returns an error if the vm can't be loaded by the node.
+	CheckVM(name string, vm types.VM) error
+	// CheckAvalancheGo returns an error if the node's version doesn't satisfy
+	// the provided version constraint.
+	CheckAvalancheGo(name string, versionConstraint string) error
+}
+
+var (
+	_ CompatibilityChecker = &NodeCompatibility{}
+	_ CompatibilityChecker = skipCompatibility{}
+)
+
+type NodeCompatibilityConfig struct {
+	AdminClient admin.Client
+	// AvalancheGoVersion is used instead of querying the node if set.
+	AvalancheGoVersion string
+	// RPCChainVMProtocol is used instead of querying the node if set.
+	RPCChainVMProtocol uint
+	// Force downgrades incompatibilities to warnings.
+	Force bool
+}
+
+func NewNodeCompatibility(config NodeCompatibilityConfig) *NodeCompatibility {
+	return &NodeCompatibility{
+		adminClient:        config.AdminClient,
+		avalancheGoVersion: config.AvalancheGoVersion,
+		rpcChainVMProtocol: config.RPCChainVMProtocol,
+		force:              config.Force,
+	}
+}
+
+// NodeCompatibility checks definitions against either a configured
+// avalanchego version or the version reported by the running node.
+type NodeCompatibility struct {
+	adminClient        admin.Client
+	avalancheGoVersion string
+	rpcChainVMProtocol uint
+	force              bool
+
+	once     sync.Once
+	warnOnce sync.Once
+	version  *version.Semantic
+	err      error
+	// configErr is set if the configured avalanchego version is invalid.
+	configErr error
+}
+
+func (n *NodeCompatibility) CheckVM(name string, vm types.VM) error {
+	c, err := constraint.Parse(vm.Compatibility.AvalancheGo)
+	if err != nil {
+		return fmt.Errorf("%s has an invalid avalanchego version constraint: %w", name, err)
+	}
+
+	nodeVersion, ok, err := n.nodeVersion()
+	if err != nil || !ok {
+		return err
+	}
+
+	problems := make([]string, 0, 2)
+	if !c.Allows(nodeVersion) {
+		problems = append(problems, fmt.Sprintf("requires avalanchego %s but the node is running %s", c, nodeVersion))
+	}
+
+	expected := vm.Compatibility.RPCChainVMProtocol
+	if expected != 0 && n.rpcChainVMProtocol != 0 && expected != n.rpcChainVMProtocol {
+		problems = append(problems, fmt.Sprintf("speaks rpcchainvm protocol %d but the node speaks %d", expected, n.rpcChainVMProtocol))
+	}
+
+	return n.report(name, problems)
+}
+
+func (n *NodeCompatibility) CheckAvalancheGo(name string, versionConstraint string) error {
+	c, err := constraint.Parse(versionConstraint)
+	if err != nil {
+		return fmt.Errorf("%s has an invalid avalanchego version constraint: %w", name, err)
+	}
+
+	nodeVersion, ok, err := n.nodeVersion()
+	if err != nil || !ok || c.Allows(nodeVersion) {
+		return err
+	}
+
+	return n.report(name, []string{fmt.Sprintf("requires avalanchego %s but the node is running %s", c, nodeVersion)})
+}
+
+func (n *NodeCompatibility) report(name string, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("%s is %w: %s", name, ErrIncompatible, strings.Join(problems, "; "))
+	if n.force {
+		fmt.Printf("Warning - %s. Continuing anyway.\n", err)
+		return nil
+	}
+
+	return err
+}
+
+// NodeVersion returns the avalanchego version, or false if it couldn't be
+// determined.
+func (n *NodeCompatibility) NodeVersion() (*version.Semantic, bool) {
+	n.once.Do(n.load)
+	return n.version, n.err == nil
+}
+
+func (n *NodeCompatibility) load() {
+	if n.avalancheGoVersion != "" {
+		n.version, n.err = constraint.ParseVersion(n.avalancheGoVersion)
+		if n.err != nil {
+			n.configErr = fmt.Errorf("invalid avalanchego version %q: %w", n.avalancheGoVersion, n.err)
+		}
+		return
+	}
+
+	reply, err := n.adminClient.GetNodeVersion()
+	if err != nil {
+		n.err = err
+		return
+	}
+
+	n.version, n.err = constraint.ParseVersion(reply.Version)
+	if n.rpcChainVMProtocol == 0 {
+		n.rpcChainVMProtocol = uint(reply.RPCProtocolVersion)
+	}
+}
+
+// nodeVersion is NodeVersion, but warns once if the node couldn't be queried
+// since compatibility checks are skipped. A configured version that can't be
+// parsed is an error instead, so a typo doesn't silently disable the checks.
+func (n *NodeCompatibility) nodeVersion() (*version.Semantic, bool, error) {
+	n.once.Do(n.load)
+
+	if n.configErr != nil {
+		return nil, false, n.configErr
+	}
+	if n.err != nil {
+		n.warnOnce.Do(func() {
+			fmt.Printf("Warning - unable to determine the avalanchego version (%s). Skipping compatibility checks.\n", n.err)
+		})
+		return nil, false, nil
+	}
+
+	return n.version, true, nil
+}
+
+// skipCompatibility accepts every definition. It's used when no checker is
+// configured.
+type skipCompatibility struct{}
+
+func (skipCompatibility) CheckVM(string, types.VM) error {
+	return nil
+}
+
+func (skipCompatibility) CheckAvalancheGo(string, string) error {
+	return nil
+}
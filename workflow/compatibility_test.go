@@ -0,0 +1,150 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ava-labs/apm/admin"
+	"github.com/ava-labs/apm/types"
+)
+
+func TestNodeCompatibilityCheckVM(t *testing.T) {
+	errWrong := fmt.Errorf("something went wrong")
+
+	vm := types.VM{
+		ID: "id",
+		Compatibility: types.Compatibility{
+			RPCChainVMProtocol: 15,
+			AvalancheGo:        ">=v1.7.14, <v1.8.0",
+		},
+	}
+
+	type mocks struct {
+		adminClient *admin.MockClient
+	}
+	tests := []struct {
+		name    string
+		config  NodeCompatibilityConfig
+		vm      types.VM
+		setup   func(mocks)
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "compatible node",
+			vm:   vm,
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().GetNodeVersion().Return(admin.NodeVersion{
+					Version:            "avalanche/1.7.16",
+					RPCProtocolVersion: 15,
+				}, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Nil(t, err)
+			},
+		},
+		{
+			name: "node too old",
+			vm:   vm,
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().GetNodeVersion().Return(admin.NodeVersion{
+					Version: "avalanche/1.7.13",
+				}, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrIncompatible)
+			},
+		},
+		{
+			name: "wrong rpcchainvm protocol",
+			vm:   vm,
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().GetNodeVersion().Return(admin.NodeVersion{
+					Version:            "avalanche/1.7.16",
+					RPCProtocolVersion: 16,
+				}, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrIncompatible)
+			},
+		},
+		{
+			name: "configured version overrides the node",
+			config: NodeCompatibilityConfig{
+				AvalancheGoVersion: "v1.8.0",
+			},
+			vm:    vm,
+			setup: func(mocks mocks) {},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrIncompatible)
+			},
+		},
+		{
+			name: "force only warns",
+			config: NodeCompatibilityConfig{
+				AvalancheGoVersion: "v1.8.0",
+				Force:              true,
+			},
+			vm:    vm,
+			setup: func(mocks mocks) {},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Nil(t, err)
+			},
+		},
+		{
+			name: "invalid configured version",
+			config: NodeCompatibilityConfig{
+				AvalancheGoVersion: "v1.8",
+			},
+			vm:    vm,
+			setup: func(mocks mocks) {},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorContains(t, err, `invalid avalanchego version "v1.8"`)
+			},
+		},
+		{
+			name: "node offline",
+			vm:   vm,
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().GetNodeVersion().Return(admin.NodeVersion{}, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Nil(t, err)
+			},
+		},
+		{
+			name: "invalid constraint",
+			vm: types.VM{
+				Compatibility: types.Compatibility{
+					AvalancheGo: ">=foo",
+				},
+			},
+			setup: func(mocks mocks) {},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			adminClient := admin.NewMockClient(ctrl)
+
+			test.setup(mocks{
+				adminClient: adminClient,
+			})
+
+			config := test.config
+			config.AdminClient = adminClient
+			checker := NewNodeCompatibility(config)
+
+			test.wantErr(t, checker.CheckVM("name", test.vm))
+		})
+	}
+}
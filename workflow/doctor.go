@@ -0,0 +1,399 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/spf13/afero"
+
+	"github.com/ava-labs/apm/git"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/util"
+)
+
+var (
+	_ Workflow = &Doctor{}
+
+	ErrUnhealthy = errors.New("found problems that need attention")
+
+	// requiredTools are the tools installs shell out to. tar unpacks every
+	// plugin, and most install scripts build from source with go.
+	requiredTools = []string{"tar", "go"}
+)
+
+const gitIndexLock = "index.lock"
+
+type DoctorConfig struct {
+	// Executor runs the workflows that fix problems.
+	Executor         Executor
+	StateFile        state.File
+	Fs               afero.Fs
+	TmpPath          string
+	RepositoriesPath string
+	PluginPath       string
+	Git              git.Factory
+	Auth             http.BasicAuth
+	// StaleLockHolder describes the process apm.lock recorded as its holder
+	// before the doctor took it, if that process isn't running anymore.
+	StaleLockHolder string
+	// Hooks runs the uninstall hooks of VMs the doctor uninstalls.
+	Hooks HookRunner
+	// Fix reconciles the problems that can be fixed automatically.
+	Fix bool
+	// LookPath finds build tools. Defaults to exec.LookPath.
+	LookPath func(file string) (string, error)
+}
+
+func NewDoctor(config DoctorConfig) *Doctor {
+	lookPath := config.LookPath
+	if lookPath == nil {
+		lookPath = exec.LookPath
+	}
+
+	return &Doctor{
+		executor:         config.Executor,
+		stateFile:        config.StateFile,
+		fs:               config.Fs,
+		tmpPath:          config.TmpPath,
+		repositoriesPath: config.RepositoriesPath,
+		pluginPath:       config.PluginPath,
+		git:              config.Git,
+		auth:             config.Auth,
+		staleLockHolder:  config.StaleLockHolder,
+		hooks:            config.Hooks,
+		fix:              config.Fix,
+		lookPath:         lookPath,
+	}
+}
+
+// Doctor detects inconsistencies left behind by interrupted workflows and
+// problems with the environment apm runs in.
+type Doctor struct {
+	executor         Executor
+	stateFile        state.File
+	fs               afero.Fs
+	tmpPath          string
+	repositoriesPath string
+	pluginPath       string
+	git              git.Factory
+	auth             http.BasicAuth
+	staleLockHolder  string
+	hooks            HookRunner
+	fix              bool
+	lookPath         func(file string) (string, error)
+}
+
+// issue is a problem found by the doctor. fix is nil if it can't be fixed
+// automatically.
+type issue struct {
+	description string
+	// warning issues are reported but don't make the doctor fail.
+	warning bool
+	fix     func() error
+}
+
+func (d *Doctor) Execute() error {
+	checks := []func() ([]issue, error){
+		d.checkLock,
+		d.checkTmp,
+		d.checkRepositories,
+		d.checkRemotes,
+		d.checkRegistry,
+		d.checkPluginDir,
+		d.checkTools,
+	}
+
+	issues := make([]issue, 0)
+	for _, check := range checks {
+		found, err := check()
+		if err != nil {
+			return err
+		}
+		issues = append(issues, found...)
+	}
+
+	if len(issues) == 0 {
+		fmt.Printf("No problems found.\n")
+		return nil
+	}
+
+	unresolved := 0
+	for _, issue := range issues {
+		switch {
+		case issue.warning:
+			fmt.Printf("Warning - %s.\n", issue.description)
+			continue
+		case issue.fix == nil:
+			fmt.Printf("Problem - %s.\n", issue.description)
+			unresolved++
+			continue
+		case !d.fix:
+			fmt.Printf("Problem - %s (fixable with --fix).\n", issue.description)
+			unresolved++
+			continue
+		}
+
+		if err := issue.fix(); err != nil {
+			return fmt.Errorf("failed to fix %s: %w", issue.description, err)
+		}
+		fmt.Printf("Fixed - %s.\n", issue.description)
+	}
+
+	if unresolved > 0 {
+		return fmt.Errorf("%d %w", unresolved, ErrUnhealthy)
+	}
+
+	return nil
+}
+
+// checkLock reports an apm process that died while holding apm.lock. Taking
+// the lock already cleared it, so this is only a warning.
+func (d *Doctor) checkLock() ([]issue, error) {
+	if d.staleLockHolder == "" {
+		return nil, nil
+	}
+
+	return []issue{{
+		description: fmt.Sprintf("apm.lock was held by %s, which exited without releasing it", d.staleLockHolder),
+		warning:     true,
+	}}, nil
+}
+
+// checkTmp finds temporary files left behind by interrupted installs.
+func (d *Doctor) checkTmp() ([]issue, error) {
+	entries, err := afero.ReadDir(d.fs, d.tmpPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	return []issue{{
+		description: fmt.Sprintf("%s has temporary files left behind by an interrupted install", d.tmpPath),
+		fix: func() error {
+			for _, entry := range entries {
+				if err := d.fs.RemoveAll(filepath.Join(d.tmpPath, entry.Name())); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}}, nil
+}
+
+// checkRepositories finds repositories on disk that aren't tracked, tracked
+// repositories that aren't on disk, and stale git locks.
+func (d *Doctor) checkRepositories() ([]issue, error) {
+	result := make([]issue, 0)
+
+	organizations, err := afero.ReadDir(d.fs, d.repositoriesPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, organization := range organizations {
+		if !organization.IsDir() {
+			continue
+		}
+
+		repositories, err := afero.ReadDir(d.fs, filepath.Join(d.repositoriesPath, organization.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repository := range repositories {
+			alias := filepath.Join(organization.Name(), repository.Name())
+			path := filepath.Join(d.repositoriesPath, alias)
+			if _, ok := d.stateFile.Sources[alias]; !ok {
+				result = append(result, issue{
+					description: fmt.Sprintf("repository %s is on disk at %s but isn't tracked", alias, path),
+					fix: func() error {
+						return d.fs.RemoveAll(path)
+					},
+				})
+				continue
+			}
+
+			lockPath := filepath.Join(path, ".git", gitIndexLock)
+			if exists, err := afero.Exists(d.fs, lockPath); err != nil {
+				return nil, err
+			} else if exists {
+				result = append(result, issue{
+					description: fmt.Sprintf("repository %s has a stale git lock at %s", alias, lockPath),
+					fix: func() error {
+						return d.fs.Remove(lockPath)
+					},
+				})
+			}
+		}
+	}
+
+	for _, alias := range util.SortedKeys(d.stateFile.Sources) {
+		source := d.stateFile.Sources[alias]
+		if source.Commit == plumbing.ZeroHash.String() {
+			// Hasn't been synced yet, so it's not expected to be on disk.
+			continue
+		}
+
+		organization, repository := util.ParseAlias(alias)
+		exists, err := afero.DirExists(d.fs, filepath.Join(d.repositoriesPath, organization, repository))
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			continue
+		}
+
+		result = append(result, issue{
+			description: fmt.Sprintf("repository %s is tracked but missing from disk (run update after fixing)", alias),
+			fix: func() error {
+				// Mark it as unsynced so the next update downloads it again.
+				source.Commit = plumbing.ZeroHash.String()
+				return nil
+			},
+		})
+	}
+
+	return result, nil
+}
+
+// checkRemotes finds tracked repositories whose remotes can't be reached.
+func (d *Doctor) checkRemotes() ([]issue, error) {
+	result := make([]issue, 0)
+	for _, alias := range util.SortedKeys(d.stateFile.Sources) {
+		source := d.stateFile.Sources[alias]
+		if err := d.git.CheckRemote(source.URL, &d.auth); err != nil {
+			result = append(result, issue{
+				description: fmt.Sprintf("can't reach %s for repository %s: %s", source.URL, alias, err),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// checkRegistry finds installed VMs whose repository isn't tracked anymore.
+// They can be uninstalled unless another VM requires them.
+func (d *Doctor) checkRegistry() ([]issue, error) {
+	result := make([]issue, 0)
+	for _, name := range util.SortedKeys(d.stateFile.InstallationRegistry) {
+		alias, plugin := util.ParseQualifiedName(name)
+		if _, ok := d.stateFile.Sources[alias]; ok {
+			continue
+		}
+
+		if requiredBy := d.stateFile.InstallationRegistry[name].RequiredBy; len(requiredBy) > 0 {
+			result = append(result, issue{
+				description: fmt.Sprintf("%s is installed from repository %s, which isn't tracked anymore. Re-add the repository, or uninstall %s and then the vm", name, alias, strings.Join(requiredBy, ", ")),
+			})
+			continue
+		}
+
+		uninstall := NewUninstall(UninstallConfig{
+			Name:       name,
+			Plugin:     plugin,
+			RepoAlias:  alias,
+			StateFile:  d.stateFile,
+			Fs:         d.fs,
+			PluginPath: d.pluginPath,
+			Hooks:      d.hooks,
+		})
+		result = append(result, issue{
+			description: fmt.Sprintf("%s is installed from repository %s, which isn't tracked anymore. Re-add the repository or uninstall the vm", name, alias),
+			fix: func() error {
+				return d.executor.Execute(uninstall)
+			},
+		})
+	}
+
+	return result, nil
+}
+
+// checkPluginDir finds problems with the plugin directory and the binaries
+// installed in it.
+func (d *Doctor) checkPluginDir() ([]issue, error) {
+	info, err := d.fs.Stat(d.pluginPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return []issue{{
+			description: fmt.Sprintf("plugin directory %s doesn't exist", d.pluginPath),
+			fix: func() error {
+				return d.fs.MkdirAll(d.pluginPath, perms.ReadWriteExecute)
+			},
+		}}, nil
+	case err != nil:
+		return nil, err
+	case !info.IsDir():
+		return []issue{{
+			description: fmt.Sprintf("plugin directory %s isn't a directory", d.pluginPath),
+		}}, nil
+	}
+
+	result := make([]issue, 0)
+
+	file, err := afero.TempFile(d.fs, d.pluginPath, ".apm-doctor")
+	if err != nil {
+		result = append(result, issue{
+			description: fmt.Sprintf("plugin directory %s isn't writable: %s", d.pluginPath, err),
+		})
+	} else {
+		_ = file.Close()
+		if err := d.fs.Remove(file.Name()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range util.SortedKeys(d.stateFile.InstallationRegistry) {
+		path := filepath.Join(d.pluginPath, d.stateFile.InstallationRegistry[name].ID)
+		binary, err := d.fs.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// verify reports missing binaries.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if binary.Mode()&0o111 != 0 {
+			continue
+		}
+
+		mode := binary.Mode()
+		result = append(result, issue{
+			description: fmt.Sprintf("%s isn't executable", path),
+			fix: func() error {
+				return d.fs.Chmod(path, mode|0o111)
+			},
+		})
+	}
+
+	return result, nil
+}
+
+// checkTools finds build tools installs need that aren't installed.
+func (d *Doctor) checkTools() ([]issue, error) {
+	result := make([]issue, 0)
+	for _, tool := range requiredTools {
+		if _, err := d.lookPath(tool); err != nil {
+			result = append(result, issue{
+				description: fmt.Sprintf("%s wasn't found in your PATH, which installs may need", tool),
+				warning:     true,
+			})
+		}
+	}
+
+	return result, nil
+}
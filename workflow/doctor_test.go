@@ -0,0 +1,266 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/git"
+	"github.com/ava-labs/apm/state"
+)
+
+func TestDoctorExecute(t *testing.T) {
+	const (
+		alias            = "organization/repository"
+		name             = "organization/repository:vm"
+		url              = "url"
+		tmpPath          = "tmpPath"
+		repositoriesPath = "repositoriesPath"
+		pluginPath       = "pluginPath"
+	)
+
+	errWrong := errors.New("something went wrong")
+	repositoryPath := filepath.Join(repositoriesPath, "organization", "repository")
+	binaryPath := filepath.Join(pluginPath, "id")
+
+	unhealthy := func(t assert.TestingT, err error, i ...interface{}) bool {
+		return assert.ErrorIs(t, err, ErrUnhealthy)
+	}
+
+	tests := []struct {
+		name    string
+		fix     bool
+		missing []string
+		setup   func(afero.Fs, state.File)
+		// remoteErr is returned when checking the repository's remote.
+		remoteErr       error
+		staleLockHolder string
+		check           func(afero.Fs, state.File)
+		wantErr         assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "healthy",
+			setup:   func(afero.Fs, state.File) {},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "leftover temporary files",
+			setup: func(fs afero.Fs, _ state.File) {
+				require.NoError(t, afero.WriteFile(fs, filepath.Join(tmpPath, "archive.tar.gz"), nil, perms.ReadWrite))
+			},
+			wantErr: unhealthy,
+		},
+		{
+			name: "leftover temporary files fixed",
+			fix:  true,
+			setup: func(fs afero.Fs, _ state.File) {
+				require.NoError(t, afero.WriteFile(fs, filepath.Join(tmpPath, "archive.tar.gz"), nil, perms.ReadWrite))
+			},
+			check: func(fs afero.Fs, _ state.File) {
+				exists, err := afero.Exists(fs, filepath.Join(tmpPath, "archive.tar.gz"))
+				require.NoError(t, err)
+				assert.False(t, exists)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "untracked repository fixed",
+			fix:  true,
+			setup: func(fs afero.Fs, _ state.File) {
+				require.NoError(t, fs.MkdirAll(filepath.Join(repositoriesPath, "organization", "other"), perms.ReadWriteExecute))
+			},
+			check: func(fs afero.Fs, _ state.File) {
+				exists, err := afero.DirExists(fs, filepath.Join(repositoriesPath, "organization", "other"))
+				require.NoError(t, err)
+				assert.False(t, exists)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "stale git lock fixed",
+			fix:  true,
+			setup: func(fs afero.Fs, _ state.File) {
+				require.NoError(t, afero.WriteFile(fs, filepath.Join(repositoryPath, ".git", gitIndexLock), nil, perms.ReadWrite))
+			},
+			check: func(fs afero.Fs, _ state.File) {
+				exists, err := afero.Exists(fs, filepath.Join(repositoryPath, ".git", gitIndexLock))
+				require.NoError(t, err)
+				assert.False(t, exists)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "missing repository fixed",
+			fix:  true,
+			setup: func(fs afero.Fs, _ state.File) {
+				require.NoError(t, fs.RemoveAll(repositoryPath))
+			},
+			check: func(_ afero.Fs, stateFile state.File) {
+				assert.Equal(t, plumbing.ZeroHash.String(), stateFile.Sources[alias].Commit)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:      "unreachable remote",
+			fix:       true,
+			setup:     func(afero.Fs, state.File) {},
+			remoteErr: errWrong,
+			wantErr:   unhealthy,
+		},
+		{
+			name: "installed from untracked repository",
+			setup: func(_ afero.Fs, stateFile state.File) {
+				stateFile.InstallationRegistry["organization/other:vm"] = &state.InstallInfo{ID: "other"}
+			},
+			wantErr: unhealthy,
+		},
+		{
+			name: "installed from untracked repository fixed",
+			fix:  true,
+			setup: func(fs afero.Fs, stateFile state.File) {
+				stateFile.InstallationRegistry["organization/other:vm"] = &state.InstallInfo{ID: "other"}
+				require.NoError(t, afero.WriteFile(fs, filepath.Join(pluginPath, "other"), nil, perms.ReadWriteExecute))
+			},
+			check: func(fs afero.Fs, stateFile state.File) {
+				assert.NotContains(t, stateFile.InstallationRegistry, "organization/other:vm")
+				exists, err := afero.Exists(fs, filepath.Join(pluginPath, "other"))
+				require.NoError(t, err)
+				assert.False(t, exists)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "installed from untracked repository and still required",
+			fix:  true,
+			setup: func(_ afero.Fs, stateFile state.File) {
+				stateFile.InstallationRegistry["organization/other:vm"] = &state.InstallInfo{ID: "other", RequiredBy: []string{name}}
+			},
+			check: func(_ afero.Fs, stateFile state.File) {
+				assert.Contains(t, stateFile.InstallationRegistry, "organization/other:vm")
+			},
+			wantErr: unhealthy,
+		},
+		{
+			name:            "stale lock holder only warns",
+			staleLockHolder: "process 1 (apm upgrade)",
+			setup:           func(afero.Fs, state.File) {},
+			wantErr:         assert.NoError,
+		},
+		{
+			name: "missing plugin directory fixed",
+			fix:  true,
+			setup: func(fs afero.Fs, _ state.File) {
+				require.NoError(t, fs.RemoveAll(pluginPath))
+			},
+			check: func(fs afero.Fs, _ state.File) {
+				exists, err := afero.DirExists(fs, pluginPath)
+				require.NoError(t, err)
+				assert.True(t, exists)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "plugin directory is a file",
+			fix:  true,
+			setup: func(fs afero.Fs, _ state.File) {
+				require.NoError(t, fs.RemoveAll(pluginPath))
+				require.NoError(t, afero.WriteFile(fs, pluginPath, nil, perms.ReadWrite))
+			},
+			wantErr: unhealthy,
+		},
+		{
+			name: "binary not executable",
+			setup: func(fs afero.Fs, _ state.File) {
+				require.NoError(t, fs.Chmod(binaryPath, perms.ReadWrite))
+			},
+			wantErr: unhealthy,
+		},
+		{
+			name: "binary not executable fixed",
+			fix:  true,
+			setup: func(fs afero.Fs, _ state.File) {
+				require.NoError(t, fs.Chmod(binaryPath, perms.ReadWrite))
+			},
+			check: func(fs afero.Fs, _ state.File) {
+				info, err := fs.Stat(binaryPath)
+				require.NoError(t, err)
+				assert.NotZero(t, info.Mode()&0o111)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "missing build tools only warn",
+			missing: []string{"go"},
+			setup:   func(afero.Fs, state.File) {},
+			wantErr: assert.NoError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fs := afero.NewMemMapFs()
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+
+			stateFile.Sources[alias] = &state.SourceInfo{
+				URL:    url,
+				Commit: "commit",
+			}
+			stateFile.InstallationRegistry[name] = &state.InstallInfo{ID: "id"}
+			require.NoError(t, fs.MkdirAll(repositoryPath, perms.ReadWriteExecute))
+			require.NoError(t, afero.WriteFile(fs, binaryPath, nil, perms.ReadWriteExecute))
+
+			test.setup(fs, stateFile)
+
+			gitFactory := git.NewMockFactory(ctrl)
+			gitFactory.EXPECT().CheckRemote(url, gomock.Any()).Return(test.remoteErr)
+
+			missing := make(map[string]bool)
+			for _, tool := range test.missing {
+				missing[tool] = true
+			}
+
+			executor := NewMockExecutor(ctrl)
+			executor.EXPECT().Execute(gomock.AssignableToTypeOf(&Uninstall{})).DoAndReturn(func(wf Workflow) error {
+				return wf.Execute()
+			}).AnyTimes()
+
+			wf := NewDoctor(DoctorConfig{
+				Executor:         executor,
+				StateFile:        stateFile,
+				Fs:               fs,
+				TmpPath:          tmpPath,
+				RepositoriesPath: repositoriesPath,
+				PluginPath:       pluginPath,
+				Git:              gitFactory,
+				StaleLockHolder:  test.staleLockHolder,
+				Fix:              test.fix,
+				LookPath: func(file string) (string, error) {
+					if missing[file] {
+						return "", exec.ErrNotFound
+					}
+					return file, nil
+				},
+			})
+
+			test.wantErr(t, wf.Execute())
+			if test.check != nil {
+				test.check(fs, stateFile)
+			}
+		})
+	}
+}
@@ -14,10 +14,18 @@ import (
 	"github.com/spf13/afero"
 
 	"github.com/ava-labs/apm/checksum"
+	"github.com/ava-labs/apm/constant"
 	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/util"
 )
 
-var _ Workflow = &Install{}
+var (
+	_ Workflow = &Install{}
+	_ Planner  = &Install{}
+	_ Named    = &Install{}
+
+	ErrChecksumMismatch = errors.New("checksums did not match")
+)
 
 type InstallConfig struct {
 	Name         string
@@ -26,26 +34,39 @@ type InstallConfig struct {
 	Repo         string
 	TmpPath      string
 	PluginPath   string
+	// Reason is recorded in the installation registry if the VM isn't already
+	// installed. Explicit installs always mark the VM as explicitly installed.
+	Reason state.InstallReason
 
 	StateFile  state.File
 	Repository state.Repository
 	Fs         afero.Fs
 	Installer  Installer
+	// Compatibility checks that the VM can be loaded by the node before it's
+	// downloaded. If nil, compatibility isn't checked.
+	Compatibility CompatibilityChecker
 }
 
 func NewInstall(config InstallConfig) *Install {
+	compatibility := config.Compatibility
+	if compatibility == nil {
+		compatibility = skipCompatibility{}
+	}
+
 	return &Install{
-		name:         config.Name,
-		plugin:       config.Plugin,
-		organization: config.Organization,
-		repo:         config.Repo,
-		tmpPath:      config.TmpPath,
-		pluginPath:   config.PluginPath,
-		stateFile:    config.StateFile,
-		repository:   config.Repository,
-		fs:           config.Fs,
-		installer:    config.Installer,
-		checksummer:  checksum.NewSHA256(config.Fs),
+		name:          config.Name,
+		plugin:        config.Plugin,
+		organization:  config.Organization,
+		repo:          config.Repo,
+		tmpPath:       config.TmpPath,
+		pluginPath:    config.PluginPath,
+		reason:        config.Reason,
+		stateFile:     config.StateFile,
+		repository:    config.Repository,
+		fs:            config.Fs,
+		installer:     config.Installer,
+		compatibility: compatibility,
+		checksummer:   checksum.NewSHA256(config.Fs),
 	}
 }
 
@@ -56,12 +77,19 @@ type Install struct {
 	repo         string
 	tmpPath      string
 	pluginPath   string
+	reason       state.InstallReason
 
-	stateFile   state.File
-	repository  state.Repository
-	fs          afero.Fs
-	installer   Installer
-	checksummer checksum.Checksummer
+	stateFile     state.File
+	repository    state.Repository
+	fs            afero.Fs
+	installer     Installer
+	compatibility CompatibilityChecker
+	checksummer   checksum.Checksummer
+}
+
+// Name returns the fully qualified name of the virtual machine.
+func (i Install) Name() string {
+	return i.name
 }
 
 func (i Install) Execute() error {
@@ -72,6 +100,10 @@ func (i Install) Execute() error {
 
 	vm := definition.Definition
 
+	if err := i.compatibility.CheckVM(i.name, vm); err != nil {
+		return err
+	}
+
 	archiveFile := fmt.Sprintf("%s.tar.gz", i.plugin)
 	tmpPath := filepath.Join(i.tmpPath, i.organization, i.repo)
 	archiveFilePath := filepath.Join(tmpPath, archiveFile)
@@ -85,7 +117,7 @@ func (i Install) Execute() error {
 	fmt.Printf("Calculating checksums...\n")
 	hash := fmt.Sprintf("%x", i.checksummer.Checksum(archiveFilePath))
 	if hash != vm.SHA256 {
-		return fmt.Errorf("checksums did not match. Expected %s but saw %s", vm.SHA256, hash)
+		return fmt.Errorf("%w. Expected %s but saw %s", ErrChecksumMismatch, vm.SHA256, hash)
 	}
 
 	fmt.Printf("Saw expected checksum value of %s\n", hash)
@@ -105,6 +137,23 @@ func (i Install) Execute() error {
 		return err
 	}
 
+	hookEnv := HookEnv{
+		Name:       i.name,
+		ID:         vm.ID,
+		PluginPath: i.pluginPath,
+		Version:    definition.Commit,
+	}
+	if installInfo, ok := i.stateFile.InstallationRegistry[i.name]; ok {
+		hookEnv.PreviousVersion = installInfo.Commit
+	}
+
+	if vm.PreInstall != "" {
+		fmt.Printf("Running preInstall hook for %s...\n", i.name)
+		if err := i.installer.RunHook(workingDir, hookEnv.Environ(), vm.PreInstall); err != nil {
+			return fmt.Errorf("preInstall hook for %s failed: %w", i.name, err)
+		}
+	}
+
 	if vm.InstallScript != "" {
 		args := strings.Split(vm.InstallScript, " ")
 		fmt.Printf("Running install script at %s...\n", vm.InstallScript)
@@ -115,11 +164,57 @@ func (i Install) Execute() error {
 		fmt.Printf("No install script found for %s.\n", i.name)
 	}
 
+	binaryPath := filepath.Join(i.pluginPath, vm.ID)
 	fmt.Printf("Moving binary %s into plugin directory...\n", vm.ID)
-	if err := i.fs.Rename(filepath.Join(workingDir, vm.BinaryPath), filepath.Join(i.pluginPath, vm.ID)); err != nil {
+	if err := i.fs.Rename(filepath.Join(workingDir, vm.BinaryPath), binaryPath); err != nil {
 		return err
 	}
 
+	// Record what we installed so it can be verified later.
+	binary, err := i.fs.Stat(binaryPath)
+	if err != nil {
+		return err
+	}
+	binaryHash := fmt.Sprintf("%x", i.checksummer.Checksum(binaryPath))
+
+	fmt.Printf("Adding virtual machine %s to installation registry...\n", vm.ID)
+	installInfo, ok := i.stateFile.InstallationRegistry[i.name]
+	if !ok {
+		installInfo = &state.InstallInfo{
+			Reason: i.reason,
+		}
+		i.stateFile.InstallationRegistry[i.name] = installInfo
+	}
+	installInfo.ID = vm.ID
+	installInfo.Commit = definition.Commit
+	installInfo.SHA256 = binaryHash
+	installInfo.Size = binary.Size()
+	installInfo.PreUninstall = vm.PreUninstall
+	installInfo.PostUninstall = vm.PostUninstall
+	if i.reason == state.ExplicitInstall {
+		installInfo.Reason = state.ExplicitInstall
+	}
+
+	// Dependencies are installed first, so record that this VM requires them.
+	// This keeps them from being autoremoved while this VM is installed.
+	repoAlias := strings.Join([]string{i.organization, i.repo}, constant.AliasDelimiter)
+	for _, dependency := range vm.Dependencies {
+		dependencyInfo, ok := i.stateFile.InstallationRegistry[util.QualifyName(repoAlias, dependency)]
+		if ok && !dependencyInfo.IsRequiredBy(i.name) {
+			dependencyInfo.RequiredBy = append(dependencyInfo.RequiredBy, i.name)
+		}
+	}
+
+	// The VM is installed at this point, so a failing postInstall hook is
+	// reported but doesn't undo the install.
+	var hookErr error
+	if vm.PostInstall != "" {
+		fmt.Printf("Running postInstall hook for %s...\n", i.name)
+		if err := i.installer.RunHook(workingDir, hookEnv.Environ(), vm.PostInstall); err != nil {
+			hookErr = fmt.Errorf("installed %s but its postInstall hook failed: %w", i.name, err)
+		}
+	}
+
 	fmt.Printf("Cleaning up temporary files...\n")
 	if err := i.fs.Remove(filepath.Join(tmpPath, archiveFile)); err != nil {
 		return err
@@ -129,12 +224,85 @@ func (i Install) Execute() error {
 		return err
 	}
 
-	fmt.Printf("Adding virtual machine %s to installation registry...\n", vm.ID)
-	i.stateFile.InstallationRegistry[i.name] = &state.InstallInfo{
-		ID:     vm.ID,
-		Commit: definition.Commit,
+	if hookErr != nil {
+		return hookErr
 	}
 
-	fmt.Printf("Successfully installed %s@%s in %s\n", i.name, definition.Commit, filepath.Join(i.pluginPath, vm.ID))
+	fmt.Printf("Successfully installed %s@%s in %s\n", i.name, definition.Commit, binaryPath)
 	return nil
 }
+
+func (i Install) Plan() ([]Action, error) {
+	definition, err := i.repository.GetVM(i.plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	vm := definition.Definition
+
+	if err := i.compatibility.CheckVM(i.name, vm); err != nil {
+		return nil, err
+	}
+
+	tmpPath := filepath.Join(i.tmpPath, i.organization, i.repo)
+	archiveFilePath := filepath.Join(tmpPath, fmt.Sprintf("%s.tar.gz", i.plugin))
+	workingDir := filepath.Join(tmpPath, i.plugin)
+	binaryPath := filepath.Join(i.pluginPath, vm.ID)
+
+	actions := []Action{
+		{
+			Type:        DownloadAction,
+			Name:        i.name,
+			URL:         vm.URL,
+			SHA256:      vm.SHA256,
+			Path:        archiveFilePath,
+			Description: fmt.Sprintf("download %s and verify its sha256 is %s", vm.URL, vm.SHA256),
+		},
+	}
+
+	if vm.PreInstall != "" {
+		actions = append(actions, Action{
+			Type:        RunScriptAction,
+			Name:        i.name,
+			Command:     vm.PreInstall,
+			Path:        workingDir,
+			Description: fmt.Sprintf("run preInstall hook %q in %s", vm.PreInstall, workingDir),
+		})
+	}
+
+	if vm.InstallScript != "" {
+		actions = append(actions, Action{
+			Type:        RunScriptAction,
+			Name:        i.name,
+			Command:     vm.InstallScript,
+			Path:        workingDir,
+			Description: fmt.Sprintf("run %s in %s", vm.InstallScript, workingDir),
+		})
+	}
+
+	actions = append(actions,
+		Action{
+			Type:        ReplaceFileAction,
+			Name:        i.name,
+			Path:        binaryPath,
+			Description: fmt.Sprintf("move %s to %s", vm.BinaryPath, binaryPath),
+		},
+		Action{
+			Type:        StateChangeAction,
+			Name:        i.name,
+			Description: fmt.Sprintf("record %s@%s in the installation registry", vm.ID, definition.Commit),
+		},
+	)
+
+	if vm.PostInstall != "" {
+		actions = append(actions, Action{
+			Type:        RunScriptAction,
+			Name:        i.name,
+			Command:     vm.PostInstall,
+			Path:        workingDir,
+			Description: fmt.Sprintf("run postInstall hook %q in %s", vm.PostInstall, workingDir),
+		})
+	}
+
+	return actions, nil
+}
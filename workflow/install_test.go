@@ -54,27 +54,64 @@ func TestInstallExecute(t *testing.T) {
 	}
 	noInstallScriptVM := noInstallScriptDefinition.Definition
 
+	hooksDefinition := state.Definition[types.VM]{
+		Definition: types.VM{
+			ID:            "id",
+			Alias:         "alias",
+			BinaryPath:    "./path/to/binary",
+			URL:           "www.website.com",
+			SHA256:        "666f6f626172",
+			PreInstall:    "./migrate.sh",
+			PostInstall:   "cp config.json $APM_PLUGIN_PATH",
+			PreUninstall:  "./stop.sh",
+			PostUninstall: "./cleanup.sh",
+		},
+		Commit: "commit",
+	}
+	hooksVM := hooksDefinition.Definition
+	hooksEnv := HookEnv{
+		Name:            "name",
+		ID:              "id",
+		PluginPath:      "pluginPath",
+		Version:         "commit",
+		PreviousVersion: "previous commit",
+	}.Environ()
+
 	installPath := filepath.Join("tmpPath", "organization", "repo")
 	workingDir := filepath.Join("tmpPath", "organization", "repo", "plugin")
 	tarPath := filepath.Join(installPath, "plugin.tar.gz")
 	errWrong := fmt.Errorf("something went wrong")
 
 	type mocks struct {
-		stateFile   state.File
-		repository  *state.MockRepository
-		installer   *MockInstaller
-		checksummer *checksum.MockChecksummer
-		fs          afero.Fs
+		stateFile     state.File
+		repository    *state.MockRepository
+		installer     *MockInstaller
+		checksummer   *checksum.MockChecksummer
+		compatibility *MockCompatibilityChecker
+		fs            afero.Fs
 	}
 	tests := []struct {
 		name    string
 		setup   func(mocks)
 		wantErr assert.ErrorAssertionFunc
+		// validate is called after a successful install, if set.
+		validate func(*testing.T, state.File)
 	}{
+		{
+			name: "incompatible vm",
+			setup: func(mocks mocks) {
+				mocks.repository.EXPECT().GetVM("plugin").Return(definition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", vm).Return(ErrIncompatible)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrIncompatible)
+			},
+		},
 		{
 			name: "download fails",
 			setup: func(mocks mocks) {
 				mocks.repository.EXPECT().GetVM("plugin").Return(definition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", vm).Return(nil)
 				mocks.installer.EXPECT().Download(vm.URL, tarPath).Return(errWrong)
 			},
 			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
@@ -85,19 +122,21 @@ func TestInstallExecute(t *testing.T) {
 			name: "wrong checksum",
 			setup: func(mocks mocks) {
 				mocks.repository.EXPECT().GetVM("plugin").Return(definition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", vm).Return(nil)
 				mocks.installer.EXPECT().Download(vm.URL, tarPath).Do(func(string, string) error {
 					return afero.WriteFile(mocks.fs, tarPath, nil, perms.ReadWrite)
 				})
 				mocks.checksummer.EXPECT().Checksum(tarPath).Return([]byte("wrong checksum"))
 			},
 			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
-				return assert.Error(t, err)
+				return assert.ErrorIs(t, err, ErrChecksumMismatch)
 			},
 		},
 		{
 			name: "decompress fails",
 			setup: func(mocks mocks) {
 				mocks.repository.EXPECT().GetVM("plugin").Return(definition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", vm).Return(nil)
 				mocks.installer.EXPECT().Download(vm.URL, tarPath).Do(func(string, string) error {
 					return afero.WriteFile(mocks.fs, tarPath, nil, perms.ReadWrite)
 				})
@@ -112,6 +151,7 @@ func TestInstallExecute(t *testing.T) {
 			name: "install fails",
 			setup: func(mocks mocks) {
 				mocks.repository.EXPECT().GetVM("plugin").Return(definition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", vm).Return(nil)
 				mocks.installer.EXPECT().Download(vm.URL, tarPath).Do(func(string, string) error {
 					return afero.WriteFile(mocks.fs, tarPath, nil, perms.ReadWrite)
 				})
@@ -130,6 +170,7 @@ func TestInstallExecute(t *testing.T) {
 			name: "happy case clean install",
 			setup: func(mocks mocks) {
 				mocks.repository.EXPECT().GetVM("plugin").Return(definition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", vm).Return(nil)
 				mocks.installer.EXPECT().Download(vm.URL, tarPath).Do(func(string, string) error {
 					return afero.WriteFile(mocks.fs, tarPath, nil, perms.ReadWrite)
 				})
@@ -138,6 +179,7 @@ func TestInstallExecute(t *testing.T) {
 					return afero.WriteFile(mocks.fs, filepath.Join(workingDir, vm.BinaryPath), nil, perms.ReadWrite)
 				})
 				mocks.installer.EXPECT().Install(workingDir, vm.InstallScript).Return(nil)
+				mocks.checksummer.EXPECT().Checksum(filepath.Join("pluginPath", vm.ID)).Return(hash)
 			},
 			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
 				return assert.Nil(t, err)
@@ -147,6 +189,7 @@ func TestInstallExecute(t *testing.T) {
 			name: "happy case no install script",
 			setup: func(mocks mocks) {
 				mocks.repository.EXPECT().GetVM("plugin").Return(noInstallScriptDefinition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", noInstallScriptVM).Return(nil)
 				mocks.installer.EXPECT().Download(noInstallScriptVM.URL, tarPath).Do(func(string, string) error {
 					return afero.WriteFile(mocks.fs, tarPath, nil, perms.ReadWrite)
 				})
@@ -154,11 +197,84 @@ func TestInstallExecute(t *testing.T) {
 				mocks.installer.EXPECT().Decompress(tarPath, workingDir).Do(func(string, string) error {
 					return afero.WriteFile(mocks.fs, filepath.Join(workingDir, noInstallScriptVM.BinaryPath), nil, perms.ReadWrite)
 				})
+				mocks.checksummer.EXPECT().Checksum(filepath.Join("pluginPath", noInstallScriptVM.ID)).Return(hash)
 			},
 			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
 				return assert.Nil(t, err)
 			},
 		},
+		{
+			name: "preInstall hook fails",
+			setup: func(mocks mocks) {
+				mocks.stateFile.InstallationRegistry["name"] = &state.InstallInfo{ID: "id", Commit: "previous commit"}
+				mocks.repository.EXPECT().GetVM("plugin").Return(hooksDefinition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", hooksVM).Return(nil)
+				mocks.installer.EXPECT().Download(hooksVM.URL, tarPath).Do(func(string, string) error {
+					return afero.WriteFile(mocks.fs, tarPath, nil, perms.ReadWrite)
+				})
+				mocks.checksummer.EXPECT().Checksum(tarPath).Return(hash)
+				mocks.installer.EXPECT().Decompress(tarPath, workingDir).Return(nil)
+				mocks.installer.EXPECT().RunHook(workingDir, hooksEnv, hooksVM.PreInstall).Return(errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, errWrong)
+			},
+		},
+		{
+			name: "postInstall hook fails",
+			setup: func(mocks mocks) {
+				mocks.stateFile.InstallationRegistry["name"] = &state.InstallInfo{ID: "id", Commit: "previous commit"}
+				mocks.repository.EXPECT().GetVM("plugin").Return(hooksDefinition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", hooksVM).Return(nil)
+				mocks.installer.EXPECT().Download(hooksVM.URL, tarPath).Do(func(string, string) error {
+					return afero.WriteFile(mocks.fs, tarPath, nil, perms.ReadWrite)
+				})
+				mocks.checksummer.EXPECT().Checksum(tarPath).Return(hash)
+				mocks.installer.EXPECT().Decompress(tarPath, workingDir).Do(func(string, string) error {
+					return afero.WriteFile(mocks.fs, filepath.Join(workingDir, hooksVM.BinaryPath), nil, perms.ReadWrite)
+				})
+				mocks.installer.EXPECT().RunHook(workingDir, hooksEnv, hooksVM.PreInstall).Return(nil)
+				mocks.checksummer.EXPECT().Checksum(filepath.Join("pluginPath", hooksVM.ID)).Return(hash)
+				mocks.installer.EXPECT().RunHook(workingDir, hooksEnv, hooksVM.PostInstall).Return(errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, errWrong)
+			},
+			// the vm is still installed
+			validate: func(t *testing.T, stateFile state.File) {
+				assert.Equal(t, "commit", stateFile.InstallationRegistry["name"].Commit)
+			},
+		},
+		{
+			name: "happy case with hooks",
+			setup: func(mocks mocks) {
+				mocks.stateFile.InstallationRegistry["name"] = &state.InstallInfo{ID: "id", Commit: "previous commit"}
+				mocks.repository.EXPECT().GetVM("plugin").Return(hooksDefinition, nil)
+				mocks.compatibility.EXPECT().CheckVM("name", hooksVM).Return(nil)
+				mocks.installer.EXPECT().Download(hooksVM.URL, tarPath).Do(func(string, string) error {
+					return afero.WriteFile(mocks.fs, tarPath, nil, perms.ReadWrite)
+				})
+				mocks.checksummer.EXPECT().Checksum(tarPath).Return(hash)
+				mocks.installer.EXPECT().Decompress(tarPath, workingDir).Do(func(string, string) error {
+					return afero.WriteFile(mocks.fs, filepath.Join(workingDir, hooksVM.BinaryPath), nil, perms.ReadWrite)
+				})
+				gomock.InOrder(
+					mocks.installer.EXPECT().RunHook(workingDir, hooksEnv, hooksVM.PreInstall).Return(nil),
+					mocks.installer.EXPECT().RunHook(workingDir, hooksEnv, hooksVM.PostInstall).Return(nil),
+				)
+				mocks.checksummer.EXPECT().Checksum(filepath.Join("pluginPath", hooksVM.ID)).Return(hash)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Nil(t, err)
+			},
+			// uninstall hooks are recorded so they can run later
+			validate: func(t *testing.T, stateFile state.File) {
+				installInfo := stateFile.InstallationRegistry["name"]
+				assert.Equal(t, "commit", installInfo.Commit)
+				assert.Equal(t, hooksVM.PreUninstall, installInfo.PreUninstall)
+				assert.Equal(t, hooksVM.PostUninstall, installInfo.PostUninstall)
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -172,32 +288,126 @@ func TestInstallExecute(t *testing.T) {
 			fs := afero.NewMemMapFs()
 			checksummer := checksum.NewMockChecksummer(ctrl)
 			repository := state.NewMockRepository(ctrl)
+			compatibility := NewMockCompatibilityChecker(ctrl)
 
 			test.setup(mocks{
-				stateFile:   stateFile,
-				repository:  repository,
-				installer:   installer,
-				fs:          fs,
-				checksummer: checksummer,
+				stateFile:     stateFile,
+				repository:    repository,
+				installer:     installer,
+				fs:            fs,
+				checksummer:   checksummer,
+				compatibility: compatibility,
 			})
 
 			wf := NewInstall(
 				InstallConfig{
-					Name:         "name",
-					Plugin:       "plugin",
-					Organization: "organization",
-					Repo:         "repo",
-					TmpPath:      "tmpPath",
-					PluginPath:   "pluginPath",
-					StateFile:    stateFile,
-					Repository:   repository,
-					Fs:           fs,
-					Installer:    installer,
+					Name:          "name",
+					Plugin:        "plugin",
+					Organization:  "organization",
+					Repo:          "repo",
+					TmpPath:       "tmpPath",
+					PluginPath:    "pluginPath",
+					StateFile:     stateFile,
+					Repository:    repository,
+					Fs:            fs,
+					Installer:     installer,
+					Compatibility: compatibility,
 				},
 			)
 			wf.checksummer = checksummer
 
 			test.wantErr(t, wf.Execute())
+			if test.validate != nil {
+				test.validate(t, stateFile)
+			}
 		})
 	}
 }
+
+func TestInstallPlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	definition := state.Definition[types.VM]{
+		Definition: types.VM{
+			ID:            "id",
+			InstallScript: "./scripts/build.sh",
+			BinaryPath:    "./build/binary",
+			URL:           "www.website.com",
+			SHA256:        "666f6f626172",
+		},
+		Commit: "commit",
+	}
+
+	stateFile, err := state.New("stateFilePath")
+	require.NoError(t, err)
+
+	// nothing is downloaded or installed
+	installer := NewMockInstaller(ctrl)
+	repository := state.NewMockRepository(ctrl)
+	compatibility := NewMockCompatibilityChecker(ctrl)
+	repository.EXPECT().GetVM("plugin").Return(definition, nil)
+	compatibility.EXPECT().CheckVM("name", definition.Definition).Return(nil)
+
+	wf := NewInstall(
+		InstallConfig{
+			Name:          "name",
+			Plugin:        "plugin",
+			Organization:  "organization",
+			Repo:          "repo",
+			TmpPath:       "tmpPath",
+			PluginPath:    "pluginPath",
+			StateFile:     stateFile,
+			Repository:    repository,
+			Fs:            afero.NewMemMapFs(),
+			Installer:     installer,
+			Compatibility: compatibility,
+		},
+	)
+
+	actions, err := wf.Plan()
+	require.NoError(t, err)
+
+	got := make([]ActionType, 0, len(actions))
+	for _, action := range actions {
+		got = append(got, action.Type)
+	}
+	assert.Equal(t, []ActionType{DownloadAction, RunScriptAction, ReplaceFileAction, StateChangeAction}, got)
+	assert.Equal(t, "www.website.com", actions[0].URL)
+	assert.Equal(t, "666f6f626172", actions[0].SHA256)
+	assert.Equal(t, filepath.Join("pluginPath", "id"), actions[2].Path)
+	assert.Empty(t, stateFile.InstallationRegistry)
+}
+
+func TestInstallWithoutCompatibility(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	definition := state.Definition[types.VM]{
+		Definition: types.VM{
+			ID:     "id",
+			URL:    "www.website.com",
+			SHA256: "666f6f626172",
+		},
+	}
+
+	stateFile, err := state.New("stateFilePath")
+	require.NoError(t, err)
+
+	repository := state.NewMockRepository(ctrl)
+	repository.EXPECT().GetVM("plugin").Return(definition, nil)
+
+	wf := NewInstall(
+		InstallConfig{
+			Name:       "name",
+			Plugin:     "plugin",
+			TmpPath:    "tmpPath",
+			PluginPath: "pluginPath",
+			StateFile:  stateFile,
+			Repository: repository,
+			Fs:         afero.NewMemMapFs(),
+			Installer:  NewMockInstaller(ctrl),
+		},
+	)
+
+	_, err = wf.Plan()
+	assert.NoError(t, err)
+}
@@ -4,15 +4,50 @@
 package workflow
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 
 	"github.com/spf13/afero"
 
 	"github.com/ava-labs/apm/url"
 )
 
+// HookRunner runs the lifecycle hooks of virtual machines.
+type HookRunner interface {
+	// RunHook runs command with sh -c in workingDir. env is added to the
+	// environment.
+	RunHook(workingDir string, env []string, command string) error
+}
+
+// HookEnv is the environment lifecycle hooks are run with.
+type HookEnv struct {
+	// Name is the fully qualified name of the virtual machine.
+	Name       string
+	ID         string
+	PluginPath string
+	// Version is the commit of the definition being installed or uninstalled.
+	Version string
+	// PreviousVersion is the commit that was installed before an upgrade. It's
+	// empty for new installs and uninstalls.
+	PreviousVersion string
+}
+
+// Environ returns the environment variables of the hook.
+func (h HookEnv) Environ() []string {
+	return []string{
+		fmt.Sprintf("APM_VM_NAME=%s", h.Name),
+		fmt.Sprintf("APM_VM_ID=%s", h.ID),
+		fmt.Sprintf("APM_PLUGIN_PATH=%s", h.PluginPath),
+		fmt.Sprintf("APM_BINARY_PATH=%s", filepath.Join(h.PluginPath, h.ID)),
+		fmt.Sprintf("APM_VERSION=%s", h.Version),
+		fmt.Sprintf("APM_PREVIOUS_VERSION=%s", h.PreviousVersion),
+	}
+}
+
 type Installer interface {
+	HookRunner
 	Download(url string, path string) error
 	Decompress(source string, dest string) error
 	// Install installs the VM. installScriptPath is a path relative to
@@ -20,7 +55,10 @@ type Installer interface {
 	Install(workingDir string, args ...string) error
 }
 
-var _ Installer = &VMInstaller{}
+var (
+	_ Installer  = &VMInstaller{}
+	_ HookRunner = &shellHookRunner{}
+)
 
 type VMInstallerConfig struct {
 	Fs        afero.Fs
@@ -52,3 +90,19 @@ func (t VMInstaller) Install(workingDir string, args ...string) error {
 
 	return cmd.Run()
 }
+
+func (t VMInstaller) RunHook(workingDir string, env []string, command string) error {
+	return shellHookRunner{}.RunHook(workingDir, env, command)
+}
+
+type shellHookRunner struct{}
+
+func (shellHookRunner) RunHook(workingDir string, env []string, command string) error {
+	cmd := exec.Command("sh", "-c", command) // #nosec G204 hooks are assumed to be trusted if a user is tracking a plugin repository
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = workingDir
+	cmd.Env = append(os.Environ(), env...)
+
+	return cmd.Run()
+}
@@ -0,0 +1,234 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/ava-labs/apm/admin"
+	"github.com/ava-labs/apm/node"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+)
+
+var (
+	_ Workflow = &JoinSubnet{}
+	_ Planner  = &JoinSubnet{}
+	_ Named    = &JoinSubnet{}
+)
+
+type JoinSubnetConfig struct {
+	Executor Executor
+
+	// Name is the fully qualified name of the subnet.
+	Name     string
+	SubnetID string
+	Network  string
+	// Subnet is the subnet's definition. Its subnet and chain configs are
+	// written to ConfigDir.
+	Subnet types.Subnet
+	// VMs are the fully qualified names of every VM the subnet requires.
+	VMs []string
+	// Installs are the workflows to install each VM the subnet requires that
+	// isn't already installed, ordered by their dependencies.
+	Installs  []Workflow
+	StateFile state.File
+
+	AdminClient      admin.Client
+	AdminAPIEndpoint string
+	NodeConfig       node.Config
+	ConfigDir        node.ConfigDir
+}
+
+func NewJoinSubnet(config JoinSubnetConfig) *JoinSubnet {
+	return &JoinSubnet{
+		executor:         config.Executor,
+		name:             config.Name,
+		subnetID:         config.SubnetID,
+		network:          config.Network,
+		subnet:           config.Subnet,
+		vms:              config.VMs,
+		installs:         config.Installs,
+		stateFile:        config.StateFile,
+		adminClient:      config.AdminClient,
+		adminAPIEndpoint: config.AdminAPIEndpoint,
+		nodeConfig:       config.NodeConfig,
+		configDir:        config.ConfigDir,
+	}
+}
+
+type JoinSubnet struct {
+	executor Executor
+
+	name      string
+	subnetID  string
+	network   string
+	subnet    types.Subnet
+	vms       []string
+	installs  []Workflow
+	stateFile state.File
+
+	adminClient      admin.Client
+	adminAPIEndpoint string
+	nodeConfig       node.Config
+	configDir        node.ConfigDir
+}
+
+// Name returns the fully qualified name of the subnet.
+func (j *JoinSubnet) Name() string {
+	return j.name
+}
+
+func (j *JoinSubnet) Execute() error {
+	// TODO prompt user, add force flag
+	fmt.Printf("Installing virtual machines for subnet %s.\n", j.subnetID)
+	for _, install := range j.installs {
+		if err := j.executor.Execute(install); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Updating virtual machines...\n")
+	loaded, err := j.adminClient.LoadVMs()
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		fmt.Printf("Node at %s was offline. Virtual machines will be available upon node startup.\n", j.adminAPIEndpoint)
+	} else if err != nil {
+		return err
+	} else if failed := j.failedVMs(loaded); len(failed) > 0 {
+		// Don't track a subnet the node can't validate.
+		return fmt.Errorf("%w: %s", ErrLoadFailed, strings.Join(failed, ", "))
+	}
+
+	fmt.Printf("Tracking subnet %s...\n", j.subnetID)
+	changed, err := j.nodeConfig.TrackSubnet(j.subnetID)
+	if err != nil {
+		return err
+	}
+	if changed {
+		fmt.Printf("Updated the node config. Your node will start tracking subnet %s after it restarts.\n", j.subnetID)
+	}
+
+	chains, err := j.writeConfigs()
+	if err != nil {
+		return err
+	}
+
+	// Record why each VM is installed so we know which ones can be removed if
+	// we leave this subnet.
+	for _, vm := range j.vms {
+		installInfo, ok := j.stateFile.InstallationRegistry[vm]
+		if ok && !installInfo.IsRequiredBy(j.name) {
+			installInfo.RequiredBy = append(installInfo.RequiredBy, j.name)
+		}
+	}
+	j.stateFile.Subnets[j.name] = &state.SubnetInfo{
+		ID:      j.subnetID,
+		Network: j.network,
+		Chains:  chains,
+	}
+
+	fmt.Printf("Finished installing virtual machines for subnet %s.\n", j.subnetID)
+	return nil
+}
+
+// failedVMs describes each of the subnet's virtual machines that the node
+// failed to load.
+func (j *JoinSubnet) failedVMs(loaded admin.LoadedVMs) []string {
+	result := make([]string, 0)
+	for _, vm := range j.vms {
+		installInfo, ok := j.stateFile.InstallationRegistry[vm]
+		if !ok {
+			continue
+		}
+
+		if reason, ok := loaded.FailedVMs[installInfo.ID]; ok {
+			result = append(result, fmt.Sprintf("%s (%s): %s", vm, installInfo.ID, reason))
+		}
+	}
+
+	return result
+}
+
+// writeConfigs writes the subnet's config and the configs of its chains on
+// this network. Returns the ids of the chains whose configs were written.
+func (j *JoinSubnet) writeConfigs() ([]string, error) {
+	if len(j.subnet.Config) > 0 {
+		fmt.Printf("Writing config for subnet %s...\n", j.subnetID)
+		if _, err := j.configDir.WriteSubnetConfig(j.subnetID, j.subnet.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	chains := make([]string, 0)
+	for _, chain := range j.subnet.Chains {
+		if len(chain.Config) == 0 && len(chain.Upgrade) == 0 {
+			continue
+		}
+
+		chainID, ok := chain.GetID(j.network)
+		if !ok {
+			fmt.Printf("Chain %s isn't available on %s. Skipping its config.\n", chain.Alias, j.network)
+			continue
+		}
+
+		fmt.Printf("Writing config for chain %s...\n", chainID)
+		if _, err := j.configDir.WriteChainConfig(chainID, chain.Config, chain.Upgrade); err != nil {
+			return nil, err
+		}
+		chains = append(chains, chainID)
+	}
+
+	return chains, nil
+}
+
+func (j *JoinSubnet) Plan() ([]Action, error) {
+	for _, install := range j.installs {
+		if err := j.executor.Execute(install); err != nil {
+			return nil, err
+		}
+	}
+
+	actions := []Action{
+		{
+			Type:        AdminAPIAction,
+			Name:        j.name,
+			Method:      "admin.loadVMs",
+			Description: fmt.Sprintf("call admin.loadVMs on %s", j.adminAPIEndpoint),
+		},
+		{
+			Type:        NodeConfigAction,
+			Name:        j.name,
+			Description: fmt.Sprintf("add subnet %s to the node's tracked subnets", j.subnetID),
+		},
+	}
+
+	if len(j.subnet.Config) > 0 {
+		actions = append(actions, Action{
+			Type:        NodeConfigAction,
+			Name:        j.name,
+			Description: fmt.Sprintf("merge the subnet config into the config of subnet %s", j.subnetID),
+		})
+	}
+	for _, chain := range j.subnet.Chains {
+		chainID, ok := chain.GetID(j.network)
+		if !ok || (len(chain.Config) == 0 && len(chain.Upgrade) == 0) {
+			continue
+		}
+
+		actions = append(actions, Action{
+			Type:        NodeConfigAction,
+			Name:        j.name,
+			Description: fmt.Sprintf("merge the chain config into the config of chain %s", chainID),
+		})
+	}
+
+	return append(actions, Action{
+		Type:        StateChangeAction,
+		Name:        j.name,
+		Description: fmt.Sprintf("record subnet %s on %s as joined", j.subnetID, j.network),
+	}), nil
+}
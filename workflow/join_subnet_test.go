@@ -0,0 +1,227 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/admin"
+	"github.com/ava-labs/apm/node"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+)
+
+func TestJoinSubnetExecute(t *testing.T) {
+	const (
+		name     = "organization/repository:subnet"
+		subnetID = "subnetID"
+		vm       = "organization/repository:vm"
+	)
+
+	errWrong := fmt.Errorf("something went wrong")
+
+	subnet := types.Subnet{
+		ID: map[string]string{"fuji": subnetID},
+		Config: map[string]interface{}{
+			"validatorOnly": true,
+		},
+		Chains: []types.Chain{
+			{
+				ID:     map[string]string{"fuji": "chainID"},
+				Alias:  "chain",
+				Config: map[string]interface{}{"pruning-enabled": true},
+			},
+			{
+				ID:     map[string]string{"mainnet": "mainnetChainID"},
+				Alias:  "mainnet-only",
+				Config: map[string]interface{}{"pruning-enabled": true},
+			},
+		},
+	}
+
+	type mocks struct {
+		executor    *MockExecutor
+		adminClient *admin.MockClient
+		nodeConfig  *node.MockConfig
+		configDir   *node.MockConfigDir
+		install     *MockWorkflow
+		stateFile   state.File
+	}
+	tests := []struct {
+		name    string
+		setup   func(mocks)
+		wantErr assert.ErrorAssertionFunc
+		check   func(*testing.T, state.File)
+	}{
+		{
+			name: "install fails",
+			setup: func(mocks mocks) {
+				mocks.executor.EXPECT().Execute(mocks.install).Return(errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "load vms fails",
+			setup: func(mocks mocks) {
+				mocks.executor.EXPECT().Execute(mocks.install).Return(nil)
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{}, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "vm fails to load",
+			setup: func(mocks mocks) {
+				mocks.executor.EXPECT().Execute(mocks.install).Return(nil)
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{
+					FailedVMs: map[string]string{"id": "bad plugin"},
+				}, nil)
+				mocks.stateFile.InstallationRegistry[vm] = &state.InstallInfo{
+					ID:     "id",
+					Reason: state.DependencyInstall,
+				}
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrLoadFailed)
+			},
+		},
+		{
+			name: "track subnet fails",
+			setup: func(mocks mocks) {
+				mocks.executor.EXPECT().Execute(mocks.install).Return(nil)
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{}, nil)
+				mocks.nodeConfig.EXPECT().TrackSubnet(subnetID).Return(false, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "node offline",
+			setup: func(mocks mocks) {
+				mocks.executor.EXPECT().Execute(mocks.install).Return(nil)
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{}, syscall.ECONNREFUSED)
+				mocks.nodeConfig.EXPECT().TrackSubnet(subnetID).Return(true, nil)
+				mocks.configDir.EXPECT().WriteSubnetConfig(subnetID, subnet.Config).Return(true, nil)
+				mocks.configDir.EXPECT().WriteChainConfig("chainID", subnet.Chains[0].Config, nil).Return(true, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.NoError(t, err)
+			},
+		},
+		{
+			name: "write config fails",
+			setup: func(mocks mocks) {
+				mocks.executor.EXPECT().Execute(mocks.install).Return(nil)
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{}, nil)
+				mocks.nodeConfig.EXPECT().TrackSubnet(subnetID).Return(true, nil)
+				mocks.configDir.EXPECT().WriteSubnetConfig(subnetID, subnet.Config).Return(false, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "success",
+			setup: func(mocks mocks) {
+				mocks.executor.EXPECT().Execute(mocks.install).Return(nil)
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{}, nil)
+				mocks.nodeConfig.EXPECT().TrackSubnet(subnetID).Return(false, nil)
+				// chains that aren't on this network are skipped
+				mocks.configDir.EXPECT().WriteSubnetConfig(subnetID, subnet.Config).Return(false, nil)
+				mocks.configDir.EXPECT().WriteChainConfig("chainID", subnet.Chains[0].Config, nil).Return(false, nil)
+				mocks.stateFile.InstallationRegistry[vm] = &state.InstallInfo{
+					ID:     "id",
+					Reason: state.DependencyInstall,
+				}
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.NoError(t, err)
+			},
+			check: func(t *testing.T, stateFile state.File) {
+				assert.Equal(t, []string{name}, stateFile.InstallationRegistry[vm].RequiredBy)
+				assert.Equal(t, &state.SubnetInfo{ID: subnetID, Network: "fuji", Chains: []string{"chainID"}}, stateFile.Subnets[name])
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			executor := NewMockExecutor(ctrl)
+			adminClient := admin.NewMockClient(ctrl)
+			nodeConfig := node.NewMockConfig(ctrl)
+			configDir := node.NewMockConfigDir(ctrl)
+			install := NewMockWorkflow(ctrl)
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+
+			test.setup(mocks{
+				executor:    executor,
+				adminClient: adminClient,
+				nodeConfig:  nodeConfig,
+				configDir:   configDir,
+				install:     install,
+				stateFile:   stateFile,
+			})
+
+			wf := NewJoinSubnet(JoinSubnetConfig{
+				Executor:    executor,
+				Name:        name,
+				SubnetID:    subnetID,
+				Network:     "fuji",
+				Subnet:      subnet,
+				VMs:         []string{vm},
+				Installs:    []Workflow{install},
+				StateFile:   stateFile,
+				AdminClient: adminClient,
+				NodeConfig:  nodeConfig,
+				ConfigDir:   configDir,
+			})
+
+			test.wantErr(t, wf.Execute())
+			if test.check != nil {
+				test.check(t, stateFile)
+			}
+		})
+	}
+}
+
+func TestJoinSubnetPlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	executor := NewMockExecutor(ctrl)
+	adminClient := admin.NewMockClient(ctrl)
+	nodeConfig := node.NewMockConfig(ctrl)
+	install := NewMockWorkflow(ctrl)
+
+	// installs are planned through the executor, and the node isn't touched
+	executor.EXPECT().Execute(install).Return(nil)
+
+	wf := NewJoinSubnet(JoinSubnetConfig{
+		Executor:    executor,
+		Name:        "organization/repository:subnet",
+		SubnetID:    "subnetID",
+		Installs:    []Workflow{install},
+		AdminClient: adminClient,
+		NodeConfig:  nodeConfig,
+	})
+
+	actions, err := wf.Plan()
+	assert.NoError(t, err)
+	require.Len(t, actions, 3)
+	assert.Equal(t, AdminAPIAction, actions[0].Type)
+	assert.Equal(t, NodeConfigAction, actions[1].Type)
+	assert.Equal(t, StateChangeAction, actions[2].Type)
+}
@@ -0,0 +1,234 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/ava-labs/apm/node"
+	"github.com/ava-labs/apm/state"
+)
+
+var (
+	_ Workflow = &LeaveSubnet{}
+	_ Planner  = &LeaveSubnet{}
+	_ Named    = &LeaveSubnet{}
+
+	ErrNotJoined = errors.New("subnet is not joined")
+)
+
+type LeaveSubnetConfig struct {
+	Executor Executor
+
+	// Name is the fully qualified name of the subnet. Its id and chains are
+	// looked up in the subnets recorded as joined in StateFile.
+	Name       string
+	NodeConfig node.Config
+	ConfigDir  node.ConfigDir
+	StateFile  state.File
+
+	Fs         afero.Fs
+	PluginPath string
+}
+
+func NewLeaveSubnet(config LeaveSubnetConfig) *LeaveSubnet {
+	return &LeaveSubnet{
+		executor:   config.Executor,
+		name:       config.Name,
+		nodeConfig: config.NodeConfig,
+		configDir:  config.ConfigDir,
+		stateFile:  config.StateFile,
+		fs:         config.Fs,
+		pluginPath: config.PluginPath,
+	}
+}
+
+type LeaveSubnet struct {
+	executor Executor
+
+	name       string
+	nodeConfig node.Config
+	configDir  node.ConfigDir
+	stateFile  state.File
+
+	fs         afero.Fs
+	pluginPath string
+}
+
+// Name returns the fully qualified name of the subnet.
+func (l *LeaveSubnet) Name() string {
+	return l.name
+}
+
+func (l *LeaveSubnet) Execute() error {
+	subnet, err := l.joined()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Untracking subnet %s...\n", subnet.ID)
+	changed, err := l.nodeConfig.UntrackSubnet(subnet.ID)
+	if err != nil {
+		return err
+	}
+	if changed {
+		fmt.Printf("Updated the node config. Your node will stop tracking subnet %s after it restarts.\n", subnet.ID)
+	}
+
+	if err := l.removeConfigs(subnet); err != nil {
+		return err
+	}
+
+	unneeded := l.unneeded()
+
+	for _, installInfo := range l.stateFile.InstallationRegistry {
+		installInfo.RequiredBy = remove(installInfo.RequiredBy, l.name)
+	}
+	delete(l.stateFile.Subnets, l.name)
+
+	for _, name := range unneeded {
+		fmt.Printf("Uninstalling %s, which is no longer required...\n", name)
+		if err := l.executor.Execute(newUninstall(name, l.stateFile, l.fs, l.pluginPath)); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Finished leaving subnet %s.\n", subnet.ID)
+	return nil
+}
+
+// joined returns the subnet as it was recorded when it was joined.
+func (l *LeaveSubnet) joined() (*state.SubnetInfo, error) {
+	subnet, ok := l.stateFile.Subnets[l.name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotJoined, l.name)
+	}
+
+	return subnet, nil
+}
+
+// removeConfigs removes the subnet and chain configs written when the subnet
+// was joined.
+func (l *LeaveSubnet) removeConfigs(subnet *state.SubnetInfo) error {
+	removed, err := l.configDir.RemoveSubnetConfig(subnet.ID)
+	if err != nil {
+		return err
+	}
+	if removed {
+		fmt.Printf("Removed the config of subnet %s.\n", subnet.ID)
+	}
+
+	for _, chainID := range subnet.Chains {
+		removed, err := l.configDir.RemoveChainConfig(chainID)
+		if err != nil {
+			return err
+		}
+		if removed {
+			fmt.Printf("Removed the config of chain %s.\n", chainID)
+		}
+	}
+
+	return nil
+}
+
+// Plan returns the actions in the order Execute takes them, so the uninstalls
+// of VMs that are no longer required are planned here rather than through the
+// executor.
+func (l *LeaveSubnet) Plan() ([]Action, error) {
+	subnet, err := l.joined()
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []Action{
+		{
+			Type:        NodeConfigAction,
+			Name:        l.name,
+			Description: fmt.Sprintf("remove subnet %s from the node's tracked subnets", subnet.ID),
+		},
+		{
+			Type:        DeleteFileAction,
+			Name:        l.name,
+			Description: fmt.Sprintf("remove the config of subnet %s, if any", subnet.ID),
+		},
+	}
+	for _, chainID := range subnet.Chains {
+		actions = append(actions, Action{
+			Type:        DeleteFileAction,
+			Name:        l.name,
+			Description: fmt.Sprintf("remove the config of chain %s", chainID),
+		})
+	}
+	actions = append(actions, Action{
+		Type:        StateChangeAction,
+		Name:        l.name,
+		Description: fmt.Sprintf("record subnet %s on %s as no longer joined", subnet.ID, subnet.Network),
+	})
+
+	for _, name := range l.unneeded() {
+		uninstallActions, err := newUninstall(name, l.stateFile, l.fs, l.pluginPath).Plan()
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, uninstallActions...)
+	}
+
+	return actions, nil
+}
+
+// unneeded returns the VMs that are only required because of this subnet, in
+// a deterministic order.
+func (l *LeaveSubnet) unneeded() []string {
+	// VMs that were already orphaned aren't our concern here; that's what
+	// autoremove is for.
+	orphaned := make(map[string]bool)
+	for _, name := range orphans(l.stateFile, "") {
+		orphaned[name] = true
+	}
+
+	result := make([]string, 0)
+	removed := make(map[string]bool)
+	for _, name := range orphans(l.stateFile, l.name) {
+		if !orphaned[name] {
+			result = append(result, name)
+			removed[name] = true
+		}
+	}
+
+	kept := make([]string, 0)
+	for name, installInfo := range l.stateFile.InstallationRegistry {
+		if installInfo.IsRequiredBy(l.name) && !removed[name] {
+			kept = append(kept, name)
+		}
+	}
+	sort.Strings(kept)
+
+	for _, name := range kept {
+		installInfo := l.stateFile.InstallationRegistry[name]
+		if installInfo.IsExplicit() {
+			fmt.Printf("Keeping %s, which was explicitly installed.\n", name)
+		} else {
+			fmt.Printf("Keeping %s, which is still required by %s.\n", name, strings.Join(remove(installInfo.RequiredBy, l.name), ", "))
+		}
+	}
+
+	return result
+}
+
+// remove returns a copy of names without name.
+func remove(names []string, name string) []string {
+	result := make([]string, 0, len(names))
+	for _, n := range names {
+		if n != name {
+			result = append(result, n)
+		}
+	}
+
+	return result
+}
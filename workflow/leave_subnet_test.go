@@ -0,0 +1,201 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/node"
+	"github.com/ava-labs/apm/state"
+)
+
+func TestLeaveSubnetExecute(t *testing.T) {
+	const (
+		name     = "organization/repository:subnet"
+		other    = "organization/repository:other"
+		subnetID = "subnetID"
+		chainID  = "chainID"
+
+		unneeded = "organization/repository:unneeded"
+		explicit = "organization/repository:explicit"
+		shared   = "organization/repository:shared"
+	)
+
+	errWrong := fmt.Errorf("something went wrong")
+
+	type mocks struct {
+		executor   *MockExecutor
+		nodeConfig *node.MockConfig
+		configDir  *node.MockConfigDir
+		stateFile  state.File
+	}
+	tests := []struct {
+		name    string
+		setup   func(mocks)
+		wantErr assert.ErrorAssertionFunc
+		check   func(*testing.T, state.File)
+	}{
+		{
+			name: "not joined",
+			setup: func(mocks mocks) {
+				delete(mocks.stateFile.Subnets, name)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrNotJoined)
+			},
+		},
+		{
+			name: "untrack subnet fails",
+			setup: func(mocks mocks) {
+				mocks.nodeConfig.EXPECT().UntrackSubnet(subnetID).Return(false, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "uninstall fails",
+			setup: func(mocks mocks) {
+				mocks.nodeConfig.EXPECT().UntrackSubnet(subnetID).Return(true, nil)
+				mocks.configDir.EXPECT().RemoveSubnetConfig(subnetID).Return(false, nil)
+				mocks.configDir.EXPECT().RemoveChainConfig(chainID).Return(false, nil)
+				mocks.executor.EXPECT().Execute(gomock.Any()).Return(errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "remove config fails",
+			setup: func(mocks mocks) {
+				mocks.nodeConfig.EXPECT().UntrackSubnet(subnetID).Return(true, nil)
+				mocks.configDir.EXPECT().RemoveSubnetConfig(subnetID).Return(false, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "success",
+			setup: func(mocks mocks) {
+				mocks.nodeConfig.EXPECT().UntrackSubnet(subnetID).Return(true, nil)
+				// the configs written when the subnet was joined are removed
+				mocks.configDir.EXPECT().RemoveSubnetConfig(subnetID).Return(true, nil)
+				mocks.configDir.EXPECT().RemoveChainConfig(chainID).Return(true, nil)
+				// only the vm nothing else needs is uninstalled
+				mocks.executor.EXPECT().Execute(gomock.Any()).DoAndReturn(func(wf Workflow) error {
+					uninstall, ok := wf.(*Uninstall)
+					require.True(t, ok)
+					assert.Equal(t, unneeded, uninstall.name)
+					return nil
+				})
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.NoError(t, err)
+			},
+			check: func(t *testing.T, stateFile state.File) {
+				assert.NotContains(t, stateFile.Subnets, name)
+				assert.Contains(t, stateFile.Subnets, other)
+				assert.Empty(t, stateFile.InstallationRegistry[explicit].RequiredBy)
+				assert.Equal(t, []string{other}, stateFile.InstallationRegistry[shared].RequiredBy)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			executor := NewMockExecutor(ctrl)
+			nodeConfig := node.NewMockConfig(ctrl)
+			configDir := node.NewMockConfigDir(ctrl)
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+
+			// the subnet was joined on another network than the apm's
+			stateFile.Subnets[name] = &state.SubnetInfo{ID: subnetID, Network: "mainnet", Chains: []string{chainID}}
+			stateFile.Subnets[other] = &state.SubnetInfo{ID: "otherID", Network: "fuji"}
+			stateFile.InstallationRegistry[unneeded] = &state.InstallInfo{
+				Reason:     state.DependencyInstall,
+				RequiredBy: []string{name},
+			}
+			stateFile.InstallationRegistry[explicit] = &state.InstallInfo{
+				Reason:     state.ExplicitInstall,
+				RequiredBy: []string{name},
+			}
+			stateFile.InstallationRegistry[shared] = &state.InstallInfo{
+				Reason:     state.DependencyInstall,
+				RequiredBy: []string{name, other},
+			}
+
+			test.setup(mocks{
+				executor:   executor,
+				nodeConfig: nodeConfig,
+				configDir:  configDir,
+				stateFile:  stateFile,
+			})
+
+			wf := NewLeaveSubnet(LeaveSubnetConfig{
+				Executor:   executor,
+				Name:       name,
+				NodeConfig: nodeConfig,
+				ConfigDir:  configDir,
+				StateFile:  stateFile,
+				Fs:         afero.NewMemMapFs(),
+				PluginPath: "pluginPath",
+			})
+
+			test.wantErr(t, wf.Execute())
+			if test.check != nil {
+				test.check(t, stateFile)
+			}
+		})
+	}
+}
+
+func TestLeaveSubnetPlan(t *testing.T) {
+	const (
+		name     = "organization/repository:subnet"
+		unneeded = "organization/repository:unneeded"
+	)
+	ctrl := gomock.NewController(t)
+
+	stateFile, err := state.New("stateFilePath")
+	require.NoError(t, err)
+	stateFile.Subnets[name] = &state.SubnetInfo{ID: "subnetID", Network: "mainnet", Chains: []string{"chainID"}}
+	stateFile.InstallationRegistry[unneeded] = &state.InstallInfo{
+		ID:         "id",
+		Reason:     state.DependencyInstall,
+		RequiredBy: []string{name},
+	}
+
+	// nothing is executed
+	wf := NewLeaveSubnet(LeaveSubnetConfig{
+		Executor:   NewMockExecutor(ctrl),
+		Name:       name,
+		NodeConfig: node.NewMockConfig(ctrl),
+		ConfigDir:  node.NewMockConfigDir(ctrl),
+		StateFile:  stateFile,
+		Fs:         afero.NewMemMapFs(),
+		PluginPath: "pluginPath",
+	})
+
+	actions, err := wf.Plan()
+	require.NoError(t, err)
+
+	// actions are in the order they're executed
+	got := make([]ActionType, 0, len(actions))
+	for _, action := range actions {
+		got = append(got, action.Type)
+	}
+	assert.Equal(t, []ActionType{NodeConfigAction, DeleteFileAction, DeleteFileAction, StateChangeAction, StateChangeAction}, got)
+	assert.Equal(t, unneeded, actions[4].Name)
+	assert.Contains(t, stateFile.Subnets, name)
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/ava-labs/apm/state"
+)
+
+var (
+	_ Workflow = &MarkExplicit{}
+	_ Planner  = &MarkExplicit{}
+	_ Named    = &MarkExplicit{}
+)
+
+type MarkExplicitConfig struct {
+	// Name is the fully qualified name of an installed virtual machine.
+	Name      string
+	StateFile state.File
+}
+
+func NewMarkExplicit(config MarkExplicitConfig) *MarkExplicit {
+	return &MarkExplicit{
+		name:      config.Name,
+		stateFile: config.StateFile,
+	}
+}
+
+// MarkExplicit records that the user asked for a virtual machine that was
+// installed as a dependency, so it isn't autoremoved when nothing requires it
+// anymore.
+type MarkExplicit struct {
+	name      string
+	stateFile state.File
+}
+
+// Name returns the fully qualified name of the virtual machine.
+func (m *MarkExplicit) Name() string {
+	return m.name
+}
+
+func (m *MarkExplicit) Execute() error {
+	installInfo, ok := m.stateFile.InstallationRegistry[m.name]
+	if !ok {
+		return fmt.Errorf("%s is not installed", m.name)
+	}
+	if installInfo.Reason == state.ExplicitInstall {
+		return nil
+	}
+
+	installInfo.Reason = state.ExplicitInstall
+	fmt.Printf("Marked %s as explicitly installed.\n", m.name)
+	return nil
+}
+
+func (m *MarkExplicit) Plan() ([]Action, error) {
+	installInfo, ok := m.stateFile.InstallationRegistry[m.name]
+	if !ok {
+		return nil, fmt.Errorf("%s is not installed", m.name)
+	}
+	if installInfo.Reason == state.ExplicitInstall {
+		return nil, nil
+	}
+
+	return []Action{{
+		Type:        StateChangeAction,
+		Name:        m.name,
+		Description: "mark as explicitly installed",
+	}}, nil
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/state"
+)
+
+func TestMarkExplicit(t *testing.T) {
+	const name = "organization/repository:vm"
+
+	stateFile, err := state.New("stateFilePath")
+	require.NoError(t, err)
+	stateFile.InstallationRegistry[name] = &state.InstallInfo{Reason: state.DependencyInstall}
+
+	wf := NewMarkExplicit(MarkExplicitConfig{Name: name, StateFile: stateFile})
+
+	// planning doesn't change the state
+	actions, err := wf.Plan()
+	require.NoError(t, err)
+	assert.Equal(t, []Action{{Type: StateChangeAction, Name: name, Description: "mark as explicitly installed"}}, actions)
+	assert.Equal(t, state.DependencyInstall, stateFile.InstallationRegistry[name].Reason)
+
+	require.NoError(t, wf.Execute())
+	assert.Equal(t, state.ExplicitInstall, stateFile.InstallationRegistry[name].Reason)
+
+	// there's nothing left to do
+	actions, err = wf.Plan()
+	require.NoError(t, err)
+	assert.Empty(t, actions)
+
+	assert.Error(t, NewMarkExplicit(MarkExplicitConfig{Name: "organization/repository:other", StateFile: stateFile}).Execute())
+}
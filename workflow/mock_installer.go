@@ -13,6 +13,43 @@ import (
 	gomock "github.com/golang/mock/gomock"
 )
 
+// MockHookRunner is a mock of HookRunner interface.
+type MockHookRunner struct {
+	ctrl     *gomock.Controller
+	recorder *MockHookRunnerMockRecorder
+}
+
+// MockHookRunnerMockRecorder is the mock recorder for MockHookRunner.
+type MockHookRunnerMockRecorder struct {
+	mock *MockHookRunner
+}
+
+// NewMockHookRunner creates a new mock instance.
+func NewMockHookRunner(ctrl *gomock.Controller) *MockHookRunner {
+	mock := &MockHookRunner{ctrl: ctrl}
+	mock.recorder = &MockHookRunnerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHookRunner) EXPECT() *MockHookRunnerMockRecorder {
+	return m.recorder
+}
+
+// RunHook mocks base method.
+func (m *MockHookRunner) RunHook(workingDir string, env []string, command string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunHook", workingDir, env, command)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RunHook indicates an expected call of RunHook.
+func (mr *MockHookRunnerMockRecorder) RunHook(workingDir, env, command interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunHook", reflect.TypeOf((*MockHookRunner)(nil).RunHook), workingDir, env, command)
+}
+
 // MockInstaller is a mock of Installer interface.
 type MockInstaller struct {
 	ctrl     *gomock.Controller
@@ -82,3 +119,17 @@ func (mr *MockInstallerMockRecorder) Install(workingDir interface{}, args ...int
 	varargs := append([]interface{}{workingDir}, args...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Install", reflect.TypeOf((*MockInstaller)(nil).Install), varargs...)
 }
+
+// RunHook mocks base method.
+func (m *MockInstaller) RunHook(workingDir string, env []string, command string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunHook", workingDir, env, command)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RunHook indicates an expected call of RunHook.
+func (mr *MockInstallerMockRecorder) RunHook(workingDir, env, command interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunHook", reflect.TypeOf((*MockInstaller)(nil).RunHook), workingDir, env, command)
+}
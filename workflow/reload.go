@@ -0,0 +1,192 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/spf13/afero"
+
+	"github.com/ava-labs/apm/admin"
+	"github.com/ava-labs/apm/state"
+)
+
+var (
+	_ Workflow = &Reload{}
+	_ Planner  = &Reload{}
+
+	ErrLoadFailed = errors.New("virtual machines failed to load")
+)
+
+type ReloadConfig struct {
+	Executor Executor
+
+	// Installed are the fully qualified names of the virtual machines that
+	// were newly installed. They're uninstalled if the node fails to load
+	// them.
+	Installed []string
+	// Upgraded are the fully qualified names of the virtual machines whose
+	// binaries were replaced. The node keeps running the old binary of a
+	// registered virtual machine until it restarts. They're restored from
+	// Backups if the node fails to load them.
+	Upgraded  []string
+	Backups   map[string]Backup
+	StateFile state.File
+
+	AdminClient      admin.Client
+	AdminAPIEndpoint string
+	Fs               afero.Fs
+	PluginPath       string
+}
+
+func NewReload(config ReloadConfig) *Reload {
+	return &Reload{
+		executor:         config.Executor,
+		installed:        config.Installed,
+		upgraded:         config.Upgraded,
+		backups:          config.Backups,
+		stateFile:        config.StateFile,
+		adminClient:      config.AdminClient,
+		adminAPIEndpoint: config.AdminAPIEndpoint,
+		fs:               config.Fs,
+		pluginPath:       config.PluginPath,
+	}
+}
+
+// Reload asks the node to load newly installed virtual machines and checks
+// that they're registered.
+type Reload struct {
+	executor Executor
+
+	installed []string
+	upgraded  []string
+	backups   map[string]Backup
+	stateFile state.File
+
+	adminClient      admin.Client
+	adminAPIEndpoint string
+	fs               afero.Fs
+	pluginPath       string
+}
+
+func (r *Reload) Execute() error {
+	if len(r.installed) == 0 && len(r.upgraded) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Loading virtual machines...\n")
+	loaded, err := r.adminClient.LoadVMs()
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		fmt.Printf("Node at %s was offline. Virtual machines will be available upon node startup.\n", r.adminAPIEndpoint)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	registered, err := r.adminClient.GetVMs()
+	if err != nil {
+		return err
+	}
+
+	// Look up every id before rolling back, since uninstalling removes the
+	// registry entry.
+	ids := make(map[string]string, len(r.installed)+len(r.upgraded))
+	for _, name := range append(append([]string{}, r.installed...), r.upgraded...) {
+		if installInfo, ok := r.stateFile.InstallationRegistry[name]; ok {
+			ids[name] = installInfo.ID
+		}
+	}
+
+	failures := 0
+	for _, name := range r.installed {
+		vmID := ids[name]
+		if reason, ok := loaded.FailedVMs[vmID]; ok {
+			failures++
+			fmt.Printf("%s (%s) failed to load: %s. Rolling back...\n", name, vmID, reason)
+			if err := r.executor.Execute(newUninstall(name, r.stateFile, r.fs, r.pluginPath)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, ok := registered[vmID]; !ok {
+			failures++
+			fmt.Printf("%s (%s) isn't registered with the node. Check that the node's plugin directory is %s.\n", name, vmID, r.pluginPath)
+			continue
+		}
+
+		fmt.Printf("Loaded %s (%s).\n", name, vmID)
+	}
+
+	for _, name := range r.upgraded {
+		vmID := ids[name]
+		if reason, ok := loaded.FailedVMs[vmID]; ok {
+			failures++
+			backup, ok := r.backups[name]
+			if !ok {
+				fmt.Printf("%s (%s) failed to load: %s. No backup of it was taken, so it can't be rolled back.\n", name, vmID, reason)
+				continue
+			}
+
+			fmt.Printf("%s (%s) failed to load: %s. Rolling back...\n", name, vmID, reason)
+			if err := restoreBackup(r.fs, r.stateFile, r.pluginPath, name, backup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, ok := loaded.NewVMs[vmID]; ok {
+			fmt.Printf("Loaded %s (%s).\n", name, vmID)
+			continue
+		}
+
+		if _, ok := registered[vmID]; !ok {
+			failures++
+			fmt.Printf("%s (%s) isn't registered with the node. Check that the node's plugin directory is %s.\n", name, vmID, r.pluginPath)
+			continue
+		}
+
+		fmt.Printf("%s (%s) was already registered. Restart your node to run the upgraded binary.\n", name, vmID)
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d %w", failures, ErrLoadFailed)
+	}
+
+	return nil
+}
+
+func (r *Reload) Plan() ([]Action, error) {
+	if len(r.installed) == 0 && len(r.upgraded) == 0 {
+		return nil, nil
+	}
+
+	actions := []Action{
+		{
+			Type:        AdminAPIAction,
+			Method:      "admin.loadVMs",
+			Description: fmt.Sprintf("call admin.loadVMs on %s", r.adminAPIEndpoint),
+		},
+	}
+	for _, name := range r.installed {
+		actions = append(actions, Action{
+			Type:        AdminAPIAction,
+			Name:        name,
+			Method:      "info.getVMs",
+			Description: fmt.Sprintf("check that %s is registered, and uninstall it if it failed to load", name),
+		})
+	}
+	for _, name := range r.upgraded {
+		actions = append(actions, Action{
+			Type:        AdminAPIAction,
+			Name:        name,
+			Method:      "info.getVMs",
+			Description: fmt.Sprintf("check that %s is registered, and roll it back if it failed to load", name),
+		})
+	}
+
+	return actions, nil
+}
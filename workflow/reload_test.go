@@ -0,0 +1,204 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/admin"
+	"github.com/ava-labs/apm/state"
+)
+
+func TestReloadExecute(t *testing.T) {
+	const (
+		installed = "organization/repository:installed"
+		upgraded  = "organization/repository:upgraded"
+	)
+
+	errWrong := fmt.Errorf("something went wrong")
+
+	backups := map[string]Backup{
+		upgraded: {
+			InstallInfo: state.InstallInfo{ID: "upgradedID", Commit: "old"},
+			Path:        filepath.Join("backups", "upgradedID"),
+		},
+	}
+
+	type mocks struct {
+		executor    *MockExecutor
+		adminClient *admin.MockClient
+		fs          afero.Fs
+	}
+	tests := []struct {
+		name    string
+		backups map[string]Backup
+		setup   func(mocks)
+		wantErr assert.ErrorAssertionFunc
+		check   func(*testing.T, afero.Fs, state.File)
+	}{
+		{
+			name: "node offline",
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{}, syscall.ECONNREFUSED)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "load vms fails",
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{}, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "get vms fails",
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{}, nil)
+				mocks.adminClient.EXPECT().GetVMs().Return(nil, errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Equal(t, errWrong, err)
+			},
+		},
+		{
+			name: "success",
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{
+					NewVMs: map[string][]string{"installedID": nil},
+				}, nil)
+				mocks.adminClient.EXPECT().GetVMs().Return(map[string][]string{
+					"installedID": nil,
+					"upgradedID":  nil,
+				}, nil)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "install fails to load",
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{
+					FailedVMs: map[string]string{"installedID": "bad plugin"},
+				}, nil)
+				mocks.adminClient.EXPECT().GetVMs().Return(map[string][]string{
+					"upgradedID": nil,
+				}, nil)
+				mocks.executor.EXPECT().Execute(gomock.Any()).DoAndReturn(func(wf Workflow) error {
+					return wf.Execute()
+				})
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrLoadFailed)
+			},
+			check: func(t *testing.T, _ afero.Fs, stateFile state.File) {
+				assert.NotContains(t, stateFile.InstallationRegistry, installed)
+				assert.Contains(t, stateFile.InstallationRegistry, upgraded)
+			},
+		},
+		{
+			name: "upgrade fails to load",
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{
+					NewVMs:    map[string][]string{"installedID": nil},
+					FailedVMs: map[string]string{"upgradedID": "bad plugin"},
+				}, nil)
+				mocks.adminClient.EXPECT().GetVMs().Return(map[string][]string{
+					"installedID": nil,
+				}, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrLoadFailed)
+			},
+			check: func(t *testing.T, _ afero.Fs, stateFile state.File) {
+				// There's no older binary to roll back to.
+				assert.Equal(t, "new", stateFile.InstallationRegistry[upgraded].Commit)
+			},
+		},
+		{
+			name:    "upgrade fails to load rolled back",
+			backups: backups,
+			setup: func(mocks mocks) {
+				require.NoError(t, afero.WriteFile(mocks.fs, filepath.Join("backups", "upgradedID"), []byte("old"), perms.ReadWriteExecute))
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{
+					NewVMs:    map[string][]string{"installedID": nil},
+					FailedVMs: map[string]string{"upgradedID": "bad plugin"},
+				}, nil)
+				mocks.adminClient.EXPECT().GetVMs().Return(map[string][]string{
+					"installedID": nil,
+				}, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrLoadFailed)
+			},
+			check: func(t *testing.T, fs afero.Fs, stateFile state.File) {
+				assert.Equal(t, "old", stateFile.InstallationRegistry[upgraded].Commit)
+
+				binary, err := afero.ReadFile(fs, filepath.Join("pluginPath", "upgradedID"))
+				require.NoError(t, err)
+				assert.Equal(t, "old", string(binary))
+			},
+		},
+		{
+			name: "not registered",
+			setup: func(mocks mocks) {
+				mocks.adminClient.EXPECT().LoadVMs().Return(admin.LoadedVMs{}, nil)
+				mocks.adminClient.EXPECT().GetVMs().Return(map[string][]string{
+					"upgradedID": nil,
+				}, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrLoadFailed)
+			},
+			check: func(t *testing.T, _ afero.Fs, stateFile state.File) {
+				assert.Contains(t, stateFile.InstallationRegistry, installed)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+			stateFile.InstallationRegistry[installed] = &state.InstallInfo{ID: "installedID"}
+			stateFile.InstallationRegistry[upgraded] = &state.InstallInfo{ID: "upgradedID", Commit: "new"}
+
+			fs := afero.NewMemMapFs()
+			mocks := mocks{
+				executor:    NewMockExecutor(ctrl),
+				adminClient: admin.NewMockClient(ctrl),
+				fs:          fs,
+			}
+			test.setup(mocks)
+
+			wf := NewReload(ReloadConfig{
+				Executor:    mocks.executor,
+				Installed:   []string{installed},
+				Upgraded:    []string{upgraded},
+				Backups:     test.backups,
+				StateFile:   stateFile,
+				AdminClient: mocks.adminClient,
+				Fs:          fs,
+				PluginPath:  "pluginPath",
+			})
+
+			test.wantErr(t, wf.Execute())
+			if test.check != nil {
+				test.check(t, fs, stateFile)
+			}
+		})
+	}
+}
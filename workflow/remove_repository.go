@@ -12,7 +12,10 @@ import (
 	"github.com/ava-labs/apm/state"
 )
 
-var _ Workflow = RemoveRepository{}
+var (
+	_ Workflow = RemoveRepository{}
+	_ Named    = RemoveRepository{}
+)
 
 func NewRemoveRepository(config RemoveRepositoryConfig) *RemoveRepository {
 	return &RemoveRepository{
@@ -34,6 +37,11 @@ type RemoveRepository struct {
 	alias            string
 }
 
+// Name returns the alias of the repository.
+func (r RemoveRepository) Name() string {
+	return r.alias
+}
+
 func (r RemoveRepository) Execute() error {
 	if r.alias == constant.CoreAlias {
 		fmt.Printf("Can't remove %s (required repository).\n", constant.CoreAlias)
@@ -0,0 +1,237 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/spf13/afero"
+
+	"github.com/ava-labs/apm/admin"
+	"github.com/ava-labs/apm/node"
+	"github.com/ava-labs/apm/state"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+var (
+	_ Workflow = &Restart{}
+	_ Planner  = &Restart{}
+
+	ErrNodeUnhealthy = errors.New("node didn't become healthy")
+)
+
+// Backup is a copy of an installed virtual machine, so an upgrade can be
+// rolled back.
+type Backup struct {
+	InstallInfo state.InstallInfo
+	// Path is where the binary was copied to.
+	Path string
+}
+
+// BackupBinaries copies the binary of every installed virtual machine into
+// dir. Virtual machines whose binary is missing aren't backed up.
+func BackupBinaries(fs afero.Fs, stateFile state.File, pluginPath string, dir string) (map[string]Backup, error) {
+	if err := fs.MkdirAll(dir, perms.ReadWriteExecute); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Backup, len(stateFile.InstallationRegistry))
+	for name, installInfo := range stateFile.InstallationRegistry {
+		path := filepath.Join(dir, installInfo.ID)
+		if err := copyFile(fs, filepath.Join(pluginPath, installInfo.ID), path); errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		result[name] = Backup{
+			InstallInfo: *installInfo,
+			Path:        path,
+		}
+	}
+
+	return result, nil
+}
+
+type RestartConfig struct {
+	Restarter   node.Restarter
+	AdminClient admin.Client
+	// HealthTimeout is how long to wait for the node to become healthy after
+	// it's restarted.
+	HealthTimeout time.Duration
+	// PollInterval is how often the node's health is checked. Defaults to 5
+	// seconds.
+	PollInterval time.Duration
+
+	// Upgraded are the fully qualified names of the virtual machines whose
+	// binaries were replaced. They're restored from Backups if the node
+	// doesn't become healthy.
+	Upgraded  []string
+	Backups   map[string]Backup
+	StateFile state.File
+
+	Fs         afero.Fs
+	PluginPath string
+}
+
+func NewRestart(config RestartConfig) *Restart {
+	pollInterval := config.PollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	return &Restart{
+		restarter:     config.Restarter,
+		adminClient:   config.AdminClient,
+		healthTimeout: config.HealthTimeout,
+		pollInterval:  pollInterval,
+		upgraded:      config.Upgraded,
+		backups:       config.Backups,
+		stateFile:     config.StateFile,
+		fs:            config.Fs,
+		pluginPath:    config.PluginPath,
+	}
+}
+
+// Restart restarts the node so it runs upgraded binaries, and rolls the
+// binaries back if the node doesn't become healthy.
+type Restart struct {
+	restarter     node.Restarter
+	adminClient   admin.Client
+	healthTimeout time.Duration
+	pollInterval  time.Duration
+
+	upgraded  []string
+	backups   map[string]Backup
+	stateFile state.File
+
+	fs         afero.Fs
+	pluginPath string
+}
+
+func (r *Restart) Execute() error {
+	if len(r.upgraded) == 0 {
+		return nil
+	}
+
+	err := r.restart()
+	if err == nil {
+		return nil
+	}
+
+	fmt.Printf("Node didn't become healthy: %s. Rolling back %s...\n", err, strings.Join(r.upgraded, ", "))
+	if err := r.rollback(); err != nil {
+		return err
+	}
+	if err := r.restart(); err != nil {
+		fmt.Printf("Node didn't become healthy after rolling back: %s.\n", err)
+	}
+
+	return fmt.Errorf("%w after upgrading %s", ErrNodeUnhealthy, strings.Join(r.upgraded, ", "))
+}
+
+// restart restarts the node and waits for it to become healthy.
+func (r *Restart) restart() error {
+	fmt.Printf("Restarting the node...\n")
+	if err := r.restarter.Restart(); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(r.healthTimeout)
+	for {
+		// The node refuses connections until its apis are up.
+		healthy, err := r.adminClient.Healthy()
+		if err == nil && healthy {
+			fmt.Printf("Node is healthy.\n")
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("timed out after %s: %w", r.healthTimeout, err)
+			}
+			return fmt.Errorf("timed out after %s", r.healthTimeout)
+		}
+
+		time.Sleep(r.pollInterval)
+	}
+}
+
+// rollback restores the binaries and installation registry entries of the
+// upgraded virtual machines.
+func (r *Restart) rollback() error {
+	for _, name := range r.upgraded {
+		backup, ok := r.backups[name]
+		if !ok {
+			fmt.Printf("No backup of %s was taken. Skipping.\n", name)
+			continue
+		}
+
+		if err := restoreBackup(r.fs, r.stateFile, r.pluginPath, name, backup); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Restart) Plan() ([]Action, error) {
+	if len(r.upgraded) == 0 {
+		return nil, nil
+	}
+
+	return []Action{
+		{
+			Type:        RestartAction,
+			Description: fmt.Sprintf("restart the node with %s", r.restarter),
+		},
+		{
+			Type:        AdminAPIAction,
+			Method:      "health.health",
+			Description: fmt.Sprintf("wait up to %s for the node to become healthy, rolling back %s if it doesn't", r.healthTimeout, strings.Join(r.upgraded, ", ")),
+		},
+	}, nil
+}
+
+// restoreBackup restores the binary and installation registry entry of an
+// upgraded virtual machine from its backup.
+func restoreBackup(fs afero.Fs, stateFile state.File, pluginPath string, name string, backup Backup) error {
+	// The upgrade could have changed the binary's name.
+	if installInfo, ok := stateFile.InstallationRegistry[name]; ok && installInfo.ID != backup.InstallInfo.ID {
+		if err := fs.Remove(filepath.Join(pluginPath, installInfo.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := copyFile(fs, backup.Path, filepath.Join(pluginPath, backup.InstallInfo.ID)); err != nil {
+		return err
+	}
+
+	installInfo := backup.InstallInfo
+	stateFile.InstallationRegistry[name] = &installInfo
+	fmt.Printf("Rolled back %s to %s.\n", name, installInfo.Commit)
+	return nil
+}
+
+// copyFile copies src to dst, keeping its permissions.
+func copyFile(fs afero.Fs, src string, dst string) error {
+	info, err := fs.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := afero.ReadFile(fs, src)
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(fs, dst, bytes, info.Mode())
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/admin"
+	"github.com/ava-labs/apm/node"
+	"github.com/ava-labs/apm/state"
+)
+
+func TestRestartExecute(t *testing.T) {
+	const (
+		name       = "organization/repository:vm"
+		pluginPath = "pluginPath"
+		backupPath = "backupPath"
+	)
+
+	errWrong := fmt.Errorf("something went wrong")
+
+	type mocks struct {
+		restarter   *node.MockRestarter
+		adminClient *admin.MockClient
+	}
+	tests := []struct {
+		name     string
+		upgraded []string
+		setup    func(mocks)
+		wantErr  assert.ErrorAssertionFunc
+		// wantBinary is the binary expected in the plugin directory afterwards.
+		wantBinary string
+		wantCommit string
+	}{
+		{
+			name:       "nothing upgraded",
+			setup:      func(mocks) {},
+			wantErr:    assert.NoError,
+			wantBinary: "new",
+			wantCommit: "new",
+		},
+		{
+			name:     "healthy",
+			upgraded: []string{name},
+			setup: func(mocks mocks) {
+				mocks.restarter.EXPECT().Restart().Return(nil)
+				gomock.InOrder(
+					mocks.adminClient.EXPECT().Healthy().Return(false, syscall.ECONNREFUSED),
+					mocks.adminClient.EXPECT().Healthy().Return(false, nil),
+					mocks.adminClient.EXPECT().Healthy().Return(true, nil),
+				)
+			},
+			wantErr:    assert.NoError,
+			wantBinary: "new",
+			wantCommit: "new",
+		},
+		{
+			name:     "unhealthy",
+			upgraded: []string{name},
+			setup: func(mocks mocks) {
+				// The node only becomes healthy once it's rolled back.
+				restarts := 0
+				mocks.restarter.EXPECT().Restart().DoAndReturn(func() error {
+					restarts++
+					return nil
+				}).Times(2)
+				mocks.adminClient.EXPECT().Healthy().DoAndReturn(func() (bool, error) {
+					return restarts > 1, nil
+				}).MinTimes(2)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrNodeUnhealthy)
+			},
+			wantBinary: "old",
+			wantCommit: "old",
+		},
+		{
+			name:     "restart fails",
+			upgraded: []string{name},
+			setup: func(mocks mocks) {
+				gomock.InOrder(
+					mocks.restarter.EXPECT().Restart().Return(errWrong),
+					mocks.restarter.EXPECT().Restart().Return(nil),
+				)
+				mocks.adminClient.EXPECT().Healthy().Return(true, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrNodeUnhealthy)
+			},
+			wantBinary: "old",
+			wantCommit: "old",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fs := afero.NewMemMapFs()
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+
+			// Back up the old binary, then upgrade it.
+			stateFile.InstallationRegistry[name] = &state.InstallInfo{ID: "id", Commit: "old"}
+			binaryPath := filepath.Join(pluginPath, "id")
+			require.NoError(t, afero.WriteFile(fs, binaryPath, []byte("old"), perms.ReadWriteExecute))
+			backups, err := BackupBinaries(fs, stateFile, pluginPath, backupPath)
+			require.NoError(t, err)
+
+			stateFile.InstallationRegistry[name] = &state.InstallInfo{ID: "id", Commit: "new"}
+			require.NoError(t, afero.WriteFile(fs, binaryPath, []byte("new"), perms.ReadWriteExecute))
+
+			mocks := mocks{
+				restarter:   node.NewMockRestarter(ctrl),
+				adminClient: admin.NewMockClient(ctrl),
+			}
+			test.setup(mocks)
+
+			wf := NewRestart(RestartConfig{
+				Restarter:     mocks.restarter,
+				AdminClient:   mocks.adminClient,
+				HealthTimeout: 10 * time.Millisecond,
+				PollInterval:  time.Millisecond,
+				Upgraded:      test.upgraded,
+				Backups:       backups,
+				StateFile:     stateFile,
+				Fs:            fs,
+				PluginPath:    pluginPath,
+			})
+
+			test.wantErr(t, wf.Execute())
+
+			binary, err := afero.ReadFile(fs, binaryPath)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantBinary, string(binary))
+			assert.Equal(t, test.wantCommit, stateFile.InstallationRegistry[name].Commit)
+		})
+	}
+}
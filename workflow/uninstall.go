@@ -14,9 +14,18 @@ import (
 	"github.com/ava-labs/apm/state"
 )
 
-var _ Workflow = &Uninstall{}
+var (
+	_ Workflow = &Uninstall{}
+	_ Planner  = &Uninstall{}
+	_ Named    = &Uninstall{}
+)
 
 func NewUninstall(config UninstallConfig) *Uninstall {
+	hooks := config.Hooks
+	if hooks == nil {
+		hooks = shellHookRunner{}
+	}
+
 	return &Uninstall{
 		name:       config.Name,
 		repoAlias:  config.RepoAlias,
@@ -24,6 +33,7 @@ func NewUninstall(config UninstallConfig) *Uninstall {
 		stateFile:  config.StateFile,
 		fs:         config.Fs,
 		pluginPath: config.PluginPath,
+		hooks:      hooks,
 	}
 }
 
@@ -34,6 +44,8 @@ type UninstallConfig struct {
 	StateFile  state.File
 	Fs         afero.Fs
 	PluginPath string
+	// Hooks runs the VM's uninstall hooks. Defaults to running them with sh.
+	Hooks HookRunner
 }
 
 type Uninstall struct {
@@ -43,6 +55,12 @@ type Uninstall struct {
 	stateFile  state.File
 	fs         afero.Fs
 	pluginPath string
+	hooks      HookRunner
+}
+
+// Name returns the fully qualified name of the virtual machine.
+func (u Uninstall) Name() string {
+	return u.name
 }
 
 func (u Uninstall) Execute() error {
@@ -53,6 +71,19 @@ func (u Uninstall) Execute() error {
 	}
 
 	vmPath := filepath.Join(u.pluginPath, installInfo.ID)
+	hookEnv := HookEnv{
+		Name:       u.name,
+		ID:         installInfo.ID,
+		PluginPath: u.pluginPath,
+		Version:    installInfo.Commit,
+	}
+
+	if installInfo.PreUninstall != "" {
+		fmt.Printf("Running preUninstall hook for %s...\n", u.name)
+		if err := u.hooks.RunHook(u.pluginPath, hookEnv.Environ(), installInfo.PreUninstall); err != nil {
+			return fmt.Errorf("preUninstall hook for %s failed: %w", u.name, err)
+		}
+	}
 
 	switch _, err := u.fs.Stat(vmPath); err {
 	case nil:
@@ -69,7 +100,67 @@ func (u Uninstall) Execute() error {
 	}
 
 	delete(u.stateFile.InstallationRegistry, u.name)
+	for _, other := range u.stateFile.InstallationRegistry {
+		other.RequiredBy = remove(other.RequiredBy, u.name)
+	}
+
+	if installInfo.PostUninstall != "" {
+		fmt.Printf("Running postUninstall hook for %s...\n", u.name)
+		if err := u.hooks.RunHook(u.pluginPath, hookEnv.Environ(), installInfo.PostUninstall); err != nil {
+			return fmt.Errorf("uninstalled %s but its postUninstall hook failed: %w", u.name, err)
+		}
+	}
 	fmt.Printf("Successfully uninstalled %s.\n", u.name)
 
 	return nil
 }
+
+func (u Uninstall) Plan() ([]Action, error) {
+	installInfo, ok := u.stateFile.InstallationRegistry[u.name]
+	if !ok {
+		fmt.Printf("VM %s is already not installed. Skipping.\n", u.name)
+		return nil, nil
+	}
+
+	vmPath := filepath.Join(u.pluginPath, installInfo.ID)
+	actions := make([]Action, 0, 4)
+
+	if installInfo.PreUninstall != "" {
+		actions = append(actions, Action{
+			Type:        RunScriptAction,
+			Name:        u.name,
+			Command:     installInfo.PreUninstall,
+			Path:        u.pluginPath,
+			Description: fmt.Sprintf("run preUninstall hook %q in %s", installInfo.PreUninstall, u.pluginPath),
+		})
+	}
+
+	if _, err := u.fs.Stat(vmPath); err == nil {
+		actions = append(actions, Action{
+			Type:        DeleteFileAction,
+			Name:        u.name,
+			Path:        vmPath,
+			Description: fmt.Sprintf("delete %s", vmPath),
+		})
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	actions = append(actions, Action{
+		Type:        StateChangeAction,
+		Name:        u.name,
+		Description: fmt.Sprintf("remove %s from the installation registry", installInfo.ID),
+	})
+
+	if installInfo.PostUninstall != "" {
+		actions = append(actions, Action{
+			Type:        RunScriptAction,
+			Name:        u.name,
+			Command:     installInfo.PostUninstall,
+			Path:        u.pluginPath,
+			Description: fmt.Sprintf("run postUninstall hook %q in %s", installInfo.PostUninstall, u.pluginPath),
+		})
+	}
+
+	return actions, nil
+}
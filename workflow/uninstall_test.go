@@ -4,8 +4,10 @@
 package workflow
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/golang/mock/gomock"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -34,13 +36,23 @@ func TestUninstallExecute(t *testing.T) {
 		Commit:     "commit",
 	}
 
+	errWrong := errors.New("something went wrong")
+	env := HookEnv{
+		Name:       name,
+		ID:         "id",
+		PluginPath: "pluginPath",
+		Version:    "commit",
+	}.Environ()
+
 	type mocks struct {
 		stateFile state.File
+		hooks     *MockHookRunner
 	}
 	tests := []struct {
-		name    string
-		setup   func(mocks)
-		wantErr assert.ErrorAssertionFunc
+		name      string
+		setup     func(mocks)
+		wantErr   assert.ErrorAssertionFunc
+		installed bool
 	}{
 		{
 			name: "vm already uninstalled",
@@ -62,28 +74,69 @@ func TestUninstallExecute(t *testing.T) {
 				return assert.Nil(t, err)
 			},
 		},
+		{
+			name: "preUninstall hook fails",
+			setup: func(mocks mocks) {
+				mocks.stateFile.InstallationRegistry[name] = &state.InstallInfo{
+					ID:           vm.GetID(),
+					Commit:       definition.Commit,
+					PreUninstall: "./stop.sh",
+				}
+				mocks.hooks.EXPECT().RunHook("pluginPath", env, "./stop.sh").Return(errWrong)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, errWrong)
+			},
+			installed: true,
+		},
+		{
+			name: "success with hooks",
+			setup: func(mocks mocks) {
+				mocks.stateFile.InstallationRegistry[name] = &state.InstallInfo{
+					ID:            vm.GetID(),
+					Commit:        definition.Commit,
+					PreUninstall:  "./stop.sh",
+					PostUninstall: "./cleanup.sh",
+				}
+				gomock.InOrder(
+					mocks.hooks.EXPECT().RunHook("pluginPath", env, "./stop.sh").Return(nil),
+					mocks.hooks.EXPECT().RunHook("pluginPath", env, "./cleanup.sh").Return(nil),
+				)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.Nil(t, err)
+			},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
 			stateFile, err := state.New("stateFilePath")
 			require.NoError(t, err)
+			hooks := NewMockHookRunner(ctrl)
 
 			test.setup(mocks{
 				stateFile: stateFile,
+				hooks:     hooks,
 			})
 
 			wf := NewUninstall(
 				UninstallConfig{
-					Name:      "organization/repository:vm",
-					Plugin:    "vm",
-					RepoAlias: "organization/repository",
-					StateFile: stateFile,
-					Fs:        afero.NewMemMapFs(),
+					Name:       "organization/repository:vm",
+					Plugin:     "vm",
+					RepoAlias:  "organization/repository",
+					StateFile:  stateFile,
+					Fs:         afero.NewMemMapFs(),
+					PluginPath: "pluginPath",
+					Hooks:      hooks,
 				},
 			)
 
 			test.wantErr(t, wf.Execute())
+			_, ok := stateFile.InstallationRegistry[name]
+			assert.Equal(t, test.installed, ok)
 		})
 	}
 }
@@ -6,6 +6,7 @@ package workflow
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/spf13/afero"
@@ -79,6 +80,7 @@ func (u Update) Execute() error {
 		}
 
 		u.stateFile.Sources[alias].Commit = latestCommit
+		u.stateFile.Sources[alias].LastUpdated = time.Now()
 	}
 
 	if updated == 0 {
@@ -10,6 +10,12 @@ import (
 
 	"github.com/ava-labs/apm/git"
 	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/util"
+)
+
+var (
+	_ Workflow = &Upgrade{}
+	_ Planner  = &Upgrade{}
 )
 
 type UpgradeConfig struct {
@@ -18,23 +24,29 @@ type UpgradeConfig struct {
 	RepoFactory state.RepositoryFactory
 	StateFile   state.File
 
-	TmpPath    string
-	PluginPath string
-	Installer  Installer
-	Git        git.Factory
-	Fs         afero.Fs
+	TmpPath       string
+	PluginPath    string
+	Installer     Installer
+	Compatibility CompatibilityChecker
+	Git           git.Factory
+	Fs            afero.Fs
+	// Dependencies installs the dependencies upgraded definitions added, if
+	// any.
+	Dependencies DependencyInstaller
 }
 
 func NewUpgrade(config UpgradeConfig) *Upgrade {
 	return &Upgrade{
-		executor:    config.Executor,
-		repoFactory: config.RepoFactory,
-		tmpPath:     config.TmpPath,
-		pluginPath:  config.PluginPath,
-		installer:   config.Installer,
-		stateFile:   config.StateFile,
-		git:         config.Git,
-		fs:          config.Fs,
+		executor:      config.Executor,
+		repoFactory:   config.RepoFactory,
+		tmpPath:       config.TmpPath,
+		pluginPath:    config.PluginPath,
+		installer:     config.Installer,
+		compatibility: config.Compatibility,
+		stateFile:     config.StateFile,
+		git:           config.Git,
+		fs:            config.Fs,
+		dependencies:  config.Dependencies,
 	}
 }
 
@@ -47,28 +59,19 @@ type Upgrade struct {
 	tmpPath    string
 	pluginPath string
 
-	installer Installer
-	git       git.Factory
-	fs        afero.Fs
+	installer     Installer
+	compatibility CompatibilityChecker
+	git           git.Factory
+	fs            afero.Fs
+	dependencies  DependencyInstaller
 }
 
 func (u *Upgrade) Execute() error {
 	upgraded := false
 
-	for name := range u.stateFile.InstallationRegistry {
-		wf := NewUpgradeVM(UpgradeVMConfig{
-			Executor:    u.executor,
-			FullVMName:  name,
-			RepoFactory: u.repoFactory,
-			StateFile:   u.stateFile,
-			TmpPath:     u.tmpPath,
-			PluginPath:  u.pluginPath,
-			Installer:   u.installer,
-			Git:         u.git,
-			Fs:          u.fs,
-		})
-
-		if err := u.executor.Execute(wf); err == ErrAlreadyUpdated {
+	// Upgrades can install new dependencies, which are already up to date.
+	for _, name := range util.SortedKeys(u.stateFile.InstallationRegistry) {
+		if err := u.executor.Execute(u.upgradeVM(name)); err == ErrAlreadyUpdated {
 			continue
 		} else if err != nil {
 			return err
@@ -84,3 +87,36 @@ func (u *Upgrade) Execute() error {
 
 	return nil
 }
+
+// Plan plans an upgrade of each out of date VM.
+func (u *Upgrade) Plan() ([]Action, error) {
+	var actions []Action
+	for _, name := range util.SortedKeys(u.stateFile.InstallationRegistry) {
+		planned, err := u.upgradeVM(name).Plan()
+		if err == ErrAlreadyUpdated {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		actions = append(actions, planned...)
+	}
+
+	return actions, nil
+}
+
+func (u *Upgrade) upgradeVM(name string) *UpgradeVM {
+	return NewUpgradeVM(UpgradeVMConfig{
+		Executor:      u.executor,
+		FullVMName:    name,
+		RepoFactory:   u.repoFactory,
+		StateFile:     u.stateFile,
+		TmpPath:       u.tmpPath,
+		PluginPath:    u.pluginPath,
+		Installer:     u.installer,
+		Compatibility: u.compatibility,
+		Git:           u.git,
+		Fs:            u.fs,
+		Dependencies:  u.dependencies,
+	})
+}
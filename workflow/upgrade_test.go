@@ -0,0 +1,127 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/git"
+	"github.com/ava-labs/apm/state"
+	"github.com/ava-labs/apm/types"
+)
+
+func TestUpgradePlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	definition := state.Definition[types.VM]{
+		Definition: types.VM{
+			ID:     "id",
+			URL:    "www.website.com",
+			SHA256: "666f6f626172",
+		},
+		Commit: "new",
+	}
+
+	stateFile, err := state.New("stateFilePath")
+	require.NoError(t, err)
+	stateFile.InstallationRegistry["organization/repository:outdated"] = &state.InstallInfo{ID: "id", Commit: "old"}
+	stateFile.InstallationRegistry["organization/repository:current"] = &state.InstallInfo{ID: "id2", Commit: "new"}
+
+	repository := state.NewMockRepository(ctrl)
+	repository.EXPECT().GetPath().Return("repositoryPath").AnyTimes()
+	repository.EXPECT().GetVM("outdated").Return(definition, nil).Times(2)
+	repository.EXPECT().GetVM("current").Return(definition, nil)
+	repoFactory := state.NewMockRepositoryFactory(ctrl)
+	repoFactory.EXPECT().GetRepository("organization/repository").Return(repository, nil).Times(2)
+	gitFactory := git.NewMockFactory(ctrl)
+	gitFactory.EXPECT().GetLastModified("repositoryPath", "vms/outdated.yaml").Return("new", nil)
+	gitFactory.EXPECT().GetLastModified("repositoryPath", "vms/current.yaml").Return("new", nil)
+
+	// nothing is executed
+	wf := NewUpgrade(UpgradeConfig{
+		Executor:    NewMockExecutor(ctrl),
+		RepoFactory: repoFactory,
+		StateFile:   stateFile,
+		TmpPath:     "tmpPath",
+		PluginPath:  "pluginPath",
+		Installer:   NewMockInstaller(ctrl),
+		Git:         gitFactory,
+		Fs:          afero.NewMemMapFs(),
+	})
+
+	actions, err := wf.Plan()
+	require.NoError(t, err)
+
+	got := make([]ActionType, 0, len(actions))
+	for _, action := range actions {
+		got = append(got, action.Type)
+	}
+	assert.Equal(t, []ActionType{DownloadAction, ReplaceFileAction, StateChangeAction}, got)
+	assert.Equal(t, filepath.Join("pluginPath", "id"), actions[1].Path)
+	assert.Equal(t, "old", stateFile.InstallationRegistry["organization/repository:outdated"].Commit)
+}
+
+func TestUpgradeVMInstallsDependencies(t *testing.T) {
+	const name = "organization/repository:vm"
+	errWrong := errors.New("something went wrong")
+
+	tests := []struct {
+		name    string
+		wantErr error
+	}{
+		{
+			name: "dependencies are installed first",
+		},
+		{
+			name:    "failed dependency stops the upgrade",
+			wantErr: errWrong,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+			stateFile.InstallationRegistry[name] = &state.InstallInfo{ID: "id", Commit: "old"}
+
+			repository := state.NewMockRepository(ctrl)
+			repository.EXPECT().GetPath().Return("repositoryPath").AnyTimes()
+			repository.EXPECT().GetVM("vm").Return(state.Definition[types.VM]{Commit: "new"}, nil)
+			repoFactory := state.NewMockRepositoryFactory(ctrl)
+			repoFactory.EXPECT().GetRepository("organization/repository").Return(repository, nil)
+			gitFactory := git.NewMockFactory(ctrl)
+			gitFactory.EXPECT().GetLastModified("repositoryPath", "vms/vm.yaml").Return("new", nil)
+
+			dependency := NewMockWorkflow(ctrl)
+			executor := NewMockExecutor(ctrl)
+			install := executor.EXPECT().Execute(dependency).Return(test.wantErr)
+			if test.wantErr == nil {
+				executor.EXPECT().Execute(gomock.AssignableToTypeOf(&Install{})).Return(nil).After(install)
+			}
+
+			wf := NewUpgradeVM(UpgradeVMConfig{
+				Executor:    executor,
+				FullVMName:  name,
+				RepoFactory: repoFactory,
+				StateFile:   stateFile,
+				Git:         gitFactory,
+				Fs:          afero.NewMemMapFs(),
+				Dependencies: func(vm string) ([]Workflow, error) {
+					assert.Equal(t, name, vm)
+					return []Workflow{dependency}, nil
+				},
+			})
+
+			assert.ErrorIs(t, wf.Execute(), test.wantErr)
+		})
+	}
+}
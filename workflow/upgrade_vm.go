@@ -15,7 +15,18 @@ import (
 	"github.com/ava-labs/apm/util"
 )
 
-var ErrAlreadyUpdated = errors.New("already up-to-date")
+var (
+	_ Workflow = &UpgradeVM{}
+	_ Planner  = &UpgradeVM{}
+	_ Named    = &UpgradeVM{}
+
+	ErrAlreadyUpdated = errors.New("already up-to-date")
+)
+
+// DependencyInstaller returns the workflows that install the dependencies of
+// the virtual machine with the provided fully qualified name that aren't
+// installed yet, each after its own dependencies.
+type DependencyInstaller func(name string) ([]Workflow, error)
 
 type UpgradeVMConfig struct {
 	Executor Executor
@@ -24,24 +35,30 @@ type UpgradeVMConfig struct {
 	RepoFactory state.RepositoryFactory
 	StateFile   state.File
 
-	TmpPath    string
-	PluginPath string
-	Installer  Installer
-	Fs         afero.Fs
-	Git        git.Factory
+	TmpPath       string
+	PluginPath    string
+	Installer     Installer
+	Compatibility CompatibilityChecker
+	Fs            afero.Fs
+	Git           git.Factory
+	// Dependencies installs the dependencies the upgraded definition added,
+	// if any.
+	Dependencies DependencyInstaller
 }
 
 func NewUpgradeVM(config UpgradeVMConfig) *UpgradeVM {
 	return &UpgradeVM{
-		executor:    config.Executor,
-		fullVMName:  config.FullVMName,
-		repoFactory: config.RepoFactory,
-		stateFile:   config.StateFile,
-		tmpPath:     config.TmpPath,
-		pluginPath:  config.PluginPath,
-		installer:   config.Installer,
-		fs:          config.Fs,
-		git:         config.Git,
+		executor:      config.Executor,
+		fullVMName:    config.FullVMName,
+		repoFactory:   config.RepoFactory,
+		stateFile:     config.StateFile,
+		tmpPath:       config.TmpPath,
+		pluginPath:    config.PluginPath,
+		installer:     config.Installer,
+		compatibility: config.Compatibility,
+		fs:            config.Fs,
+		git:           config.Git,
+		dependencies:  config.Dependencies,
 	}
 }
 
@@ -56,12 +73,94 @@ type UpgradeVM struct {
 	tmpPath    string
 	pluginPath string
 
-	installer Installer
-	fs        afero.Fs
-	git       git.Factory
+	installer     Installer
+	compatibility CompatibilityChecker
+	fs            afero.Fs
+	git           git.Factory
+	dependencies  DependencyInstaller
+}
+
+// Name returns the fully qualified name of the virtual machine.
+func (u *UpgradeVM) Name() string {
+	return u.fullVMName
 }
 
 func (u *UpgradeVM) Execute() error {
+	wf, latest, err := u.install()
+	if err != nil || wf == nil {
+		return err
+	}
+
+	fmt.Printf(
+		"Detected an upgrade for %s from %s to %s\n",
+		u.fullVMName,
+		u.stateFile.InstallationRegistry[u.fullVMName].Commit,
+		latest,
+	)
+
+	// The new definition can require virtual machines that aren't installed
+	// yet, which have to be there before it's rebuilt.
+	installs, err := u.installDependencies()
+	if err != nil {
+		return err
+	}
+	for _, install := range installs {
+		if err := u.executor.Execute(install); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf(
+		"Rebuilding binaries for %s@%s\n",
+		u.fullVMName,
+		latest,
+	)
+	return u.executor.Execute(wf)
+}
+
+// Plan plans the reinstall of the VM if it's out of date, after the install of
+// any dependencies it added.
+func (u *UpgradeVM) Plan() ([]Action, error) {
+	wf, _, err := u.install()
+	if err != nil || wf == nil {
+		return nil, err
+	}
+
+	installs, err := u.installDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []Action
+	for _, install := range append(installs, wf) {
+		planner, ok := install.(Planner)
+		if !ok {
+			continue
+		}
+
+		planned, err := planner.Plan()
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, planned...)
+	}
+
+	return actions, nil
+}
+
+// installDependencies returns the workflows that install the dependencies of
+// the new definition that aren't installed yet.
+func (u *UpgradeVM) installDependencies() ([]Workflow, error) {
+	if u.dependencies == nil {
+		return nil, nil
+	}
+
+	return u.dependencies(u.fullVMName)
+}
+
+// install returns the workflow that reinstalls the VM and the commit it will
+// be installed at. The workflow is nil if the VM can't be upgraded.
+func (u *UpgradeVM) install() (*Install, string, error) {
 	installInfo := u.stateFile.InstallationRegistry[u.fullVMName]
 
 	repoAlias, vmName := util.ParseQualifiedName(u.fullVMName)
@@ -73,50 +172,38 @@ func (u *UpgradeVM) Execute() error {
 			"which is no longer downloaded. You might need to re-add this "+
 			"repository and call update, or uninstall this vm to avoid noisy logs. "+
 			"Skipping...\n", repoAlias, u.fullVMName)
-		return nil
+		return nil, "", nil
 	} else if err != nil {
-		return err
+		return nil, "", err
 	}
 
 	if _, err := repository.GetVM(vmName); err != nil {
 		fmt.Printf("Warning - found a vm while upgrading %s which is no "+
 			"longer registered in a repository. You should uninstall this VM to "+
 			"avoid noisy logs. Skipping...\n", u.fullVMName)
-		return nil
+		return nil, "", nil
 	}
 
 	latest, err := u.git.GetLastModified(repository.GetPath(), fmt.Sprintf("vms/%s.%s", vmName, "yaml"))
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
 	if installInfo.Commit == latest {
-		return ErrAlreadyUpdated
+		return nil, "", ErrAlreadyUpdated
 	}
 
-	fmt.Printf(
-		"Detected an upgrade for %s from %s to %s\n",
-		u.fullVMName,
-		installInfo.Commit,
-		latest,
-	)
-	wf := NewInstall(InstallConfig{
-		Name:         u.fullVMName,
-		Plugin:       vmName,
-		Organization: organization,
-		Repo:         repo,
-		TmpPath:      u.tmpPath,
-		PluginPath:   u.pluginPath,
-		StateFile:    u.stateFile,
-		Repository:   repository,
-		Installer:    u.installer,
-		Fs:           u.fs,
-	})
-
-	fmt.Printf(
-		"Rebuilding binaries for %s@%s\n",
-		u.fullVMName,
-		latest,
-	)
-	return u.executor.Execute(wf)
+	return NewInstall(InstallConfig{
+		Name:          u.fullVMName,
+		Plugin:        vmName,
+		Organization:  organization,
+		Repo:          repo,
+		TmpPath:       u.tmpPath,
+		PluginPath:    u.pluginPath,
+		StateFile:     u.stateFile,
+		Repository:    repository,
+		Installer:     u.installer,
+		Compatibility: u.compatibility,
+		Fs:            u.fs,
+	}), latest, nil
 }
@@ -0,0 +1,180 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/afero"
+
+	"github.com/ava-labs/apm/checksum"
+	"github.com/ava-labs/apm/state"
+)
+
+var (
+	_ Workflow = &Verify{}
+
+	ErrVerificationFailed = errors.New("verification failed")
+)
+
+// ProblemKind is what's wrong with a file in the plugin directory.
+type ProblemKind string
+
+const (
+	// MismatchProblem means a binary in the plugin directory has changed
+	// since it was installed.
+	MismatchProblem ProblemKind = "mismatch"
+	// MissingProblem means an installed binary isn't in the plugin directory.
+	MissingProblem ProblemKind = "missing"
+	// UntrackedProblem means a binary in the plugin directory wasn't
+	// installed by the apm.
+	UntrackedProblem ProblemKind = "untracked"
+	// UnverifiedProblem means no checksum was recorded when the binary was
+	// installed.
+	UnverifiedProblem ProblemKind = "unverified"
+)
+
+// Problem is a discrepancy between the plugin directory and the installation
+// registry.
+type Problem struct {
+	Kind ProblemKind
+	// Name is the fully qualified name of the VM, or empty if the file is
+	// untracked.
+	Name string
+	Path string
+}
+
+type VerifyConfig struct {
+	// Names are the fully qualified names of the VMs to verify. If empty,
+	// every installed VM is verified and untracked files are reported.
+	Names      []string
+	StateFile  state.File
+	Fs         afero.Fs
+	PluginPath string
+}
+
+func NewVerify(config VerifyConfig) *Verify {
+	return &Verify{
+		names:       config.Names,
+		stateFile:   config.StateFile,
+		fs:          config.Fs,
+		pluginPath:  config.PluginPath,
+		checksummer: checksum.NewSHA256(config.Fs),
+	}
+}
+
+// Verify rehashes installed binaries and compares them against the checksums
+// recorded when they were installed.
+type Verify struct {
+	names       []string
+	stateFile   state.File
+	fs          afero.Fs
+	pluginPath  string
+	checksummer checksum.Checksummer
+
+	problems []Problem
+}
+
+func (v *Verify) Execute() error {
+	problems, err := v.verify()
+	if err != nil {
+		return err
+	}
+	v.problems = problems
+
+	failed := false
+	for _, problem := range problems {
+		switch problem.Kind {
+		case MismatchProblem:
+			failed = true
+			fmt.Printf("%s: %s doesn't match the checksum recorded when it was installed.\n", problem.Name, problem.Path)
+		case MissingProblem:
+			failed = true
+			fmt.Printf("%s: %s is missing.\n", problem.Name, problem.Path)
+		case UnverifiedProblem:
+			fmt.Printf("%s: no checksum was recorded for %s. Reinstall it to be able to verify it.\n", problem.Name, problem.Path)
+		case UntrackedProblem:
+			fmt.Printf("%s wasn't installed by the apm.\n", problem.Path)
+		}
+	}
+
+	if failed {
+		return ErrVerificationFailed
+	}
+
+	fmt.Printf("All installed virtual machines match their recorded checksums.\n")
+	return nil
+}
+
+// Problems returns the problems found by the last run.
+func (v *Verify) Problems() []Problem {
+	return v.problems
+}
+
+func (v *Verify) verify() ([]Problem, error) {
+	names := v.names
+	if len(names) == 0 {
+		names = make([]string, 0, len(v.stateFile.InstallationRegistry))
+		for name := range v.stateFile.InstallationRegistry {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	result := make([]Problem, 0)
+	tracked := make(map[string]bool)
+	for _, name := range names {
+		installInfo, ok := v.stateFile.InstallationRegistry[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not installed", name)
+		}
+
+		path := filepath.Join(v.pluginPath, installInfo.ID)
+		tracked[installInfo.ID] = true
+
+		info, err := v.fs.Stat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			result = append(result, Problem{Kind: MissingProblem, Name: name, Path: path})
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		if installInfo.SHA256 == "" {
+			result = append(result, Problem{Kind: UnverifiedProblem, Name: name, Path: path})
+			continue
+		}
+
+		// Only hash the file if the size matches, since hashing is slow.
+		if info.Size() != installInfo.Size || fmt.Sprintf("%x", v.checksummer.Checksum(path)) != installInfo.SHA256 {
+			result = append(result, Problem{Kind: MismatchProblem, Name: name, Path: path})
+		}
+	}
+
+	if len(v.names) > 0 {
+		return result, nil
+	}
+
+	files, err := afero.ReadDir(v.fs, v.pluginPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || tracked[file.Name()] {
+			continue
+		}
+
+		result = append(result, Problem{Kind: UntrackedProblem, Path: filepath.Join(v.pluginPath, file.Name())})
+	}
+
+	return result, nil
+}
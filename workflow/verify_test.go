@@ -0,0 +1,124 @@
+// Copyright (C) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package workflow
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/perms"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/apm/state"
+)
+
+func TestVerifyExecute(t *testing.T) {
+	const (
+		name       = "organization/repository:vm"
+		pluginPath = "pluginPath"
+	)
+
+	binary := []byte("binary")
+	binaryPath := filepath.Join(pluginPath, "id")
+
+	tests := []struct {
+		name    string
+		names   []string
+		setup   func(afero.Fs, state.File)
+		want    []Problem
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "matches",
+			setup: func(fs afero.Fs, stateFile state.File) {
+				require.NoError(t, afero.WriteFile(fs, binaryPath, binary, perms.ReadWrite))
+			},
+			want:    []Problem{},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "tampered",
+			setup: func(fs afero.Fs, stateFile state.File) {
+				require.NoError(t, afero.WriteFile(fs, binaryPath, []byte("other!"), perms.ReadWrite))
+			},
+			want: []Problem{
+				{Kind: MismatchProblem, Name: name, Path: binaryPath},
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrVerificationFailed)
+			},
+		},
+		{
+			name:  "missing",
+			setup: func(fs afero.Fs, stateFile state.File) {},
+			want: []Problem{
+				{Kind: MissingProblem, Name: name, Path: binaryPath},
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, ErrVerificationFailed)
+			},
+		},
+		{
+			name: "no checksum recorded",
+			setup: func(fs afero.Fs, stateFile state.File) {
+				require.NoError(t, afero.WriteFile(fs, binaryPath, binary, perms.ReadWrite))
+				stateFile.InstallationRegistry[name].SHA256 = ""
+			},
+			want: []Problem{
+				{Kind: UnverifiedProblem, Name: name, Path: binaryPath},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "untracked",
+			setup: func(fs afero.Fs, stateFile state.File) {
+				require.NoError(t, afero.WriteFile(fs, binaryPath, binary, perms.ReadWrite))
+				require.NoError(t, afero.WriteFile(fs, filepath.Join(pluginPath, "other"), binary, perms.ReadWrite))
+			},
+			want: []Problem{
+				{Kind: UntrackedProblem, Path: filepath.Join(pluginPath, "other")},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "untracked files are ignored for a single vm",
+			names: []string{name},
+			setup: func(fs afero.Fs, stateFile state.File) {
+				require.NoError(t, afero.WriteFile(fs, binaryPath, binary, perms.ReadWrite))
+				require.NoError(t, afero.WriteFile(fs, filepath.Join(pluginPath, "other"), binary, perms.ReadWrite))
+			},
+			want:    []Problem{},
+			wantErr: assert.NoError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			stateFile, err := state.New("stateFilePath")
+			require.NoError(t, err)
+
+			stateFile.InstallationRegistry[name] = &state.InstallInfo{
+				ID:     "id",
+				SHA256: fmt.Sprintf("%x", sha256.Sum256(binary)),
+				Size:   int64(len(binary)),
+			}
+			test.setup(fs, stateFile)
+
+			wf := NewVerify(VerifyConfig{
+				Names:      test.names,
+				StateFile:  stateFile,
+				Fs:         fs,
+				PluginPath: pluginPath,
+			})
+
+			test.wantErr(t, wf.Execute())
+			assert.Equal(t, test.want, wf.Problems())
+		})
+	}
+}
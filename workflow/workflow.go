@@ -6,3 +6,21 @@ package workflow
 type Workflow interface {
 	Execute() error
 }
+
+// Planner is implemented by workflows that can describe the actions they
+// would take without performing them.
+type Planner interface {
+	// Plan returns the actions this workflow would take, in order. Workflows
+	// composed of other workflows plan their children through their Executor,
+	// unless the children run after the workflow's own actions, in which case
+	// their actions are included in the returned ones.
+	Plan() ([]Action, error)
+}
+
+// Named is implemented by workflows that act on a single virtual machine,
+// subnet or repository.
+type Named interface {
+	// Name returns the fully qualified name of the virtual machine or subnet,
+	// or the alias of the repository.
+	Name() string
+}